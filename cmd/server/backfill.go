@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/nft_market_go/internal/chain"
+	"github.com/nft_market_go/internal/store"
+)
+
+// runBackfill implements the "backfill" subcommand: it replays the events of
+// a marketplace (into orders) or of the ProjectNFT / Project1155 contract
+// (into the token tables) for a historical block range and exits. Progress
+// is saved after every batch, so re-running the command with the same -from
+// block resumes an interrupted backfill.
+//
+// Example:
+//
+//	go run ./cmd/server backfill -from 45000000 -to 45200000
+//	go run ./cmd/server backfill -chain 97 -from 45000000
+//	go run ./cmd/server backfill -marketplace 0xAbc... -to 45200000
+//	go run ./cmd/server backfill -scanner erc1155 -from 45000000
+func runBackfill(cfg *basicConfig, args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	chainSel := fs.String("chain", "", "name or chain-id of the configured chain to backfill (required when several chains are configured)")
+	scannerSel := fs.String("scanner", "marketplace", "events to backfill: marketplace, erc721 (project-nft) or erc1155 (project-1155)")
+	marketSel := fs.String("marketplace", "", "marketplace contract to backfill (required when several marketplaces are configured)")
+	from := fs.Uint64("from", 0, "first block to scan (0 = the contract's configured start-block)")
+	to := fs.Uint64("to", 0, "last block to scan (0 = current head)")
+	batch := fs.Uint64("batch", 0, "max blocks per FilterLogs query (0 = scanner default)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cc, err := selectChain(cfg.Chains, *chainSel)
+	if err != nil {
+		return err
+	}
+
+	var contract string
+	var startBlock uint64
+	switch *scannerSel {
+	case "marketplace":
+		mc, err := selectMarketplace(cc, *marketSel)
+		if err != nil {
+			return err
+		}
+		contract, startBlock = mc.Address, mc.StartBlock
+	case "erc721":
+		if cc.ProjectNFTAddress == "" {
+			return fmt.Errorf("chain %s: project-nft address is required", cc.Name)
+		}
+		contract, startBlock = cc.ProjectNFTAddress, cc.ProjectNFTStartBlock
+	case "erc1155":
+		if cc.Project1155Address == "" {
+			return fmt.Errorf("chain %s: project-1155 address is required", cc.Name)
+		}
+		contract, startBlock = cc.Project1155Address, cc.Project1155StartBlock
+	default:
+		return fmt.Errorf("invalid -scanner %s, want marketplace, erc721 or erc1155", *scannerSel)
+	}
+	if *from == 0 {
+		*from = startBlock
+	}
+	if *from == 0 {
+		return errors.New("-from is required when the contract has no start-block configured")
+	}
+	if *to != 0 && *to < *from {
+		return errors.New("-to must not be lower than -from")
+	}
+
+	// Stop cleanly on Ctrl+C; progress up to the last finished batch is kept.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	initCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rpcPool, err := chain.DialRPCPool(initCtx, cc.rpcEndpoints(), log.Default())
+	if err != nil {
+		return err
+	}
+	defer rpcPool.Close()
+
+	chainID, err := resolveChainID(initCtx, cc, rpcPool)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("mysql", cfg.MySQLDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.PingContext(initCtx); err != nil {
+		return err
+	}
+
+	// Upgrading tables created by an older version may take a while.
+	schemaCtx, cancelSchema := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancelSchema()
+
+	orderStore := store.NewOrderStore(db)
+	if err := orderStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	checkpointStore := store.NewCheckpointStore(db)
+	if err := checkpointStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	reorgStore := store.NewReorgStore(db)
+	if err := reorgStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	failedEventStore := store.NewFailedEventStore(db)
+	if err := failedEventStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	// The token tables are upgraded even for a marketplace backfill, since
+	// AssignLegacyRows below needs their chain_id column.
+	assetStore := store.NewNftAssetStore(db)
+	if err := assetStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	tokenOwnerStore := store.NewTokenOwnerStore(db)
+	if err := tokenOwnerStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	tokenBalanceStore := store.NewTokenBalanceStore(db)
+	if err := tokenBalanceStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	tokenURIStore := store.NewTokenURIStore(db)
+	if err := tokenURIStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+	approvalStore := store.NewApprovalStore(db)
+	if err := approvalStore.InitSchema(schemaCtx); err != nil {
+		return err
+	}
+
+	// Rows stored by a single-chain version belong to the first configured
+	// chain; assign them before writing rows that could collide with them.
+	if cc == &cfg.Chains[0] {
+		if err := store.AssignLegacyRows(schemaCtx, db, chainID, cc.legacyMarketplace()); err != nil {
+			return err
+		}
+	}
+
+	// Logs that fail to apply are retried by the server's dead letter queue.
+	deadLetters := chain.NewDeadLetterQueue(failedEventStore, log.Default())
+	addr := common.HexToAddress(contract)
+	var backfill func(context.Context, uint64, uint64, func(chain.BackfillProgress)) error
+	switch *scannerSel {
+	case "marketplace":
+		scanner, err := chain.NewMarketplaceScanner(rpcPool, db, chainID, addr, orderStore, checkpointStore, reorgStore, log.Default())
+		if err != nil {
+			return err
+		}
+		scanner.SetMaxBatchBlocks(*batch)
+		scanner.SetDeadLetterQueue(deadLetters)
+		backfill = scanner.Backfill
+	case "erc721":
+		scanner, err := chain.NewERC721Scanner(rpcPool, db, chainID, addr, tokenOwnerStore, assetStore, checkpointStore, log.Default())
+		if err != nil {
+			return err
+		}
+		if len(cc.Marketplaces) > 0 {
+			scanner.SetApprovalTracking(approvalStore, orderStore, cc.marketplaceAddresses())
+		}
+		scanner.SetMaxBatchBlocks(*batch)
+		scanner.SetDeadLetterQueue(deadLetters)
+		backfill = scanner.Backfill
+	case "erc1155":
+		// The server's MetadataRefresher picks up the backfilled URIs.
+		scanner, err := chain.NewERC1155Scanner(rpcPool, db, chainID, addr, tokenBalanceStore, tokenURIStore, checkpointStore, log.Default())
+		if err != nil {
+			return err
+		}
+		if len(cc.Marketplaces) > 0 {
+			scanner.SetApprovalTracking(approvalStore, orderStore, cc.marketplaceAddresses())
+		}
+		scanner.SetMaxBatchBlocks(*batch)
+		scanner.SetDeadLetterQueue(deadLetters)
+		backfill = scanner.Backfill
+	}
+
+	started := time.Now()
+	lastReport := time.Time{}
+	err = backfill(ctx, *from, *to, func(p chain.BackfillProgress) {
+		// Report at most every few seconds, plus the final batch.
+		if time.Since(lastReport) < 5*time.Second && p.Current < p.To {
+			return
+		}
+		lastReport = time.Now()
+		done := p.Current - p.From + 1
+		total := p.To - p.From + 1
+		log.Printf("backfill: block %d / %d (%.1f%%), %d events, elapsed %s",
+			p.Current, p.To, float64(done)*100/float64(total), p.Logs, time.Since(started).Round(time.Second))
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("backfill interrupted; re-run with -from %d to resume", *from)
+		}
+		return err
+	}
+
+	log.Printf("backfill finished in %s", time.Since(started).Round(time.Second))
+	return nil
+}
+
+// selectChain picks the configured chain named by sel, which is either the
+// chain name or its chain-id. sel may be empty when only one chain is configured.
+func selectChain(chains []chainConfig, sel string) (*chainConfig, error) {
+	if sel == "" {
+		if len(chains) == 1 {
+			return &chains[0], nil
+		}
+		return nil, errors.New("-chain is required when several chains are configured")
+	}
+	id, _ := strconv.ParseInt(sel, 10, 64)
+	for i := range chains {
+		if chains[i].Name == sel || (id != 0 && chains[i].ChainID == id) {
+			return &chains[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no configured chain matches -chain %s", sel)
+}
+
+// selectMarketplace picks the configured marketplace of cc whose address is
+// sel. sel may be empty when the chain has a single marketplace.
+func selectMarketplace(cc *chainConfig, sel string) (*marketplaceConfig, error) {
+	if len(cc.Marketplaces) == 0 {
+		return nil, fmt.Errorf("chain %s: marketplace address is required", cc.Name)
+	}
+	if sel == "" {
+		if len(cc.Marketplaces) == 1 {
+			return &cc.Marketplaces[0], nil
+		}
+		return nil, errors.New("-marketplace is required when several marketplaces are configured")
+	}
+	if !common.IsHexAddress(sel) {
+		return nil, fmt.Errorf("invalid -marketplace address %s", sel)
+	}
+	for i := range cc.Marketplaces {
+		if common.HexToAddress(cc.Marketplaces[i].Address) == common.HexToAddress(sel) {
+			return &cc.Marketplaces[i], nil
+		}
+	}
+	return nil, fmt.Errorf("chain %s: no configured marketplace matches -marketplace %s", cc.Name, sel)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/nft_market_go/internal/chain"
+	"github.com/nft_market_go/internal/lock"
+)
+
+// chainConfig describes one EVM network the server indexes: its RPC
+// endpoints and the marketplace / NFT contracts deployed on it.
+type chainConfig struct {
+	Name                  string
+	ChainID               int64 // 0 = ask the RPC node
+	RPCURL                string
+	RPCURLs               []string // extra endpoints for failover
+	Confirmations         uint64
+	ExposePending         bool
+	Marketplaces          []marketplaceConfig
+	ProjectNFTAddress     string
+	ProjectNFTStartBlock  uint64 // as marketplaceConfig.StartBlock
+	Project1155Address    string
+	Project1155StartBlock uint64 // as marketplaceConfig.StartBlock
+}
+
+// marketplaceConfig is one NFTMarketplace deployment on a chain. Older
+// deployments stay configured after a redeploy so their live listings keep
+// being indexed.
+type marketplaceConfig struct {
+	Address    string
+	StartBlock uint64 // first block to index when there is no checkpoint, 0 = current head
+}
+
+// marketplaceConfigs merges the legacy single marketplace address with the
+// marketplaces list, dropping duplicates and empty addresses.
+func marketplaceConfigs(primary string, list []yamlMarketplace) []marketplaceConfig {
+	var out []marketplaceConfig
+	seen := make(map[common.Address]bool)
+	add := func(addr string, start uint64) {
+		if addr == "" || seen[common.HexToAddress(addr)] {
+			return
+		}
+		seen[common.HexToAddress(addr)] = true
+		out = append(out, marketplaceConfig{Address: addr, StartBlock: start})
+	}
+	add(primary, 0)
+	for _, m := range list {
+		add(m.Address, m.StartBlock)
+	}
+	return out
+}
+
+// marketplaceAddresses returns the addresses of all configured marketplaces.
+func (c *chainConfig) marketplaceAddresses() []common.Address {
+	out := make([]common.Address, 0, len(c.Marketplaces))
+	for _, m := range c.Marketplaces {
+		out = append(out, common.HexToAddress(m.Address))
+	}
+	return out
+}
+
+// legacyMarketplace returns the first configured marketplace, as stored in
+// orders, or "" when there is none. Orders stored before marketplaces were
+// recorded belong to it, see store.AssignLegacyRows.
+func (c *chainConfig) legacyMarketplace() string {
+	if len(c.Marketplaces) == 0 {
+		return ""
+	}
+	return common.HexToAddress(c.Marketplaces[0].Address).Hex()
+}
+
+// nftAddresses returns the configured ProjectNFT / Project1155 contracts.
+func (c *chainConfig) nftAddresses() []common.Address {
+	var out []common.Address
+	for _, a := range []string{c.ProjectNFTAddress, c.Project1155Address} {
+		if a != "" {
+			out = append(out, common.HexToAddress(a))
+		}
+	}
+	return out
+}
+
+// rpcEndpoints returns the primary RPC URL followed by the backup URLs,
+// without duplicates.
+func (c *chainConfig) rpcEndpoints() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, u := range append([]string{c.RPCURL}, c.RPCURLs...) {
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		out = append(out, u)
+	}
+	return out
+}
+
+// resolveChainID returns the configured chain ID, asking the RPC node when it
+// is not set. Orders, assets and scanner checkpoints are keyed by chain ID.
+func resolveChainID(ctx context.Context, cfg *chainConfig, client chain.ChainClient) (int64, error) {
+	if cfg.ChainID != 0 {
+		return cfg.ChainID, nil
+	}
+	id, err := client.ChainID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return id.Int64(), nil
+}
+
+// chainRuntime is a configured chain once connected: its resolved chain ID,
+// RPC pool, order callback and mint verifiers, pending tx tracker and one
+// scanner per configured marketplace contract.
+type chainRuntime struct {
+	cfg            chainConfig
+	id             int64
+	pool           *chain.RPCPool
+	verifier       *chain.OrderVerifier
+	mints          *chain.MintVerifier
+	tracker        *chain.TxTracker // follows buy / cancel txs reported before they were mined
+	marketScanners []*chain.MarketplaceScanner
+}
+
+// chainRegistry holds the chains served by this process, in config order.
+type chainRegistry struct {
+	list []*chainRuntime
+	byID map[int64]*chainRuntime
+}
+
+var (
+	errChainIDRequired     = errors.New("chain_id is required when several chains are configured")
+	errUnknownChainID      = errors.New("unknown chain_id")
+	errMarketplaceRequired = errors.New("marketplace is required when several marketplaces are configured")
+	errUnknownMarketplace  = errors.New("unknown marketplace")
+)
+
+// dialChains connects to every configured chain and resolves its chain ID.
+// Two entries resolving to the same chain ID are rejected.
+func dialChains(ctx context.Context, configs []chainConfig) (*chainRegistry, error) {
+	r := &chainRegistry{byID: make(map[int64]*chainRuntime)}
+	for _, cc := range configs {
+		pool, err := chain.DialRPCPool(ctx, cc.rpcEndpoints(), log.Default())
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("chain %s: %w", cc.Name, err)
+		}
+		id, err := resolveChainID(ctx, &cc, pool)
+		if err != nil {
+			pool.Close()
+			r.Close()
+			return nil, fmt.Errorf("chain %s: get chain id: %w", cc.Name, err)
+		}
+		if _, dup := r.byID[id]; dup {
+			pool.Close()
+			r.Close()
+			return nil, fmt.Errorf("chain %s: chain id %d is configured twice", cc.Name, id)
+		}
+		verifier, err := chain.NewOrderVerifier(pool)
+		if err != nil {
+			pool.Close()
+			r.Close()
+			return nil, fmt.Errorf("chain %s: %w", cc.Name, err)
+		}
+		mints, err := chain.NewMintVerifier(pool)
+		if err != nil {
+			pool.Close()
+			r.Close()
+			return nil, fmt.Errorf("chain %s: %w", cc.Name, err)
+		}
+		rt := &chainRuntime{cfg: cc, id: id, pool: pool, verifier: verifier, mints: mints}
+		r.list = append(r.list, rt)
+		r.byID[id] = rt
+	}
+	return r, nil
+}
+
+// Close closes the RPC pools of all chains.
+func (r *chainRegistry) Close() {
+	for _, rt := range r.list {
+		rt.pool.Close()
+	}
+}
+
+// lookup returns the chain a request refers to. A zero id is accepted only
+// when a single chain is configured, in which case that chain is used.
+func (r *chainRegistry) lookup(id int64) (*chainRuntime, error) {
+	if id == 0 {
+		if len(r.list) == 1 {
+			return r.list[0], nil
+		}
+		return nil, errChainIDRequired
+	}
+	rt, ok := r.byID[id]
+	if !ok {
+		return nil, errUnknownChainID
+	}
+	return rt, nil
+}
+
+// filter parses an optional chain_id query value used to narrow a listing.
+// An empty value means all chains (0).
+func (r *chainRegistry) filter(raw string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, errUnknownChainID
+	}
+	if _, ok := r.byID[id]; !ok {
+		return 0, errUnknownChainID
+	}
+	return id, nil
+}
+
+// param parses a chain_id query value identifying a single record's chain.
+// It may be omitted when a single chain is configured.
+func (r *chainRegistry) param(raw string) (*chainRuntime, error) {
+	if raw == "" {
+		return r.lookup(0)
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errUnknownChainID
+	}
+	return r.lookup(id)
+}
+
+// marketplace resolves the marketplace contract a request refers to and
+// returns its checksummed address as stored in orders. raw may be empty when
+// the chain has exactly one marketplace configured. Addresses that are not
+// configured for the chain are rejected, so on chains without any configured
+// marketplace every request is.
+func (rt *chainRuntime) marketplace(raw string) (string, error) {
+	if len(rt.cfg.Marketplaces) == 0 {
+		return "", errUnknownMarketplace
+	}
+	if raw == "" {
+		if len(rt.cfg.Marketplaces) == 1 {
+			return common.HexToAddress(rt.cfg.Marketplaces[0].Address).Hex(), nil
+		}
+		return "", errMarketplaceRequired
+	}
+	if !common.IsHexAddress(raw) {
+		return "", errUnknownMarketplace
+	}
+	addr := common.HexToAddress(raw)
+	for _, m := range rt.cfg.marketplaceAddresses() {
+		if m == addr {
+			return addr.Hex(), nil
+		}
+	}
+	return "", errUnknownMarketplace
+}
+
+// listingLockKey returns the order lock key of a listing. Listing IDs are
+// only unique per marketplace contract on a chain, so both are part of the key.
+func listingLockKey(chainID int64, marketplace, listingID string) string {
+	return "listing:" + strconv.FormatInt(chainID, 10) + ":" + strings.ToLower(marketplace) + ":" + listingID
+}
+
+// listingLockWait bounds how long an order request waits for a listing lock
+// held by a concurrent request before giving up with 409.
+const listingLockWait = 3 * time.Second
+
+// acquireListingLock takes the order lock of a listing, waiting up to
+// listingLockWait for the current holder. Waiters are woken as soon as the
+// holder releases it; the error wraps lock.ErrLockNotAcquired on timeout.
+func acquireListingLock(ctx context.Context, locker *lock.RedisLocker, key string) (*lock.RedisLock, error) {
+	ctx, cancel := context.WithTimeout(ctx, listingLockWait)
+	defer cancel()
+	return locker.AcquireWait(ctx, key, 10*time.Second, lock.WaitOptions{Notify: true})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nft_market_go/internal/lock"
+)
+
+// leaderJobs are the background jobs (scanners, reconcilers, trackers, ...)
+// that must run on a single replica at a time. They are started when this
+// replica is elected leader and stopped, through ctx, when it loses the lease.
+type leaderJobs []func(ctx context.Context)
+
+// add registers a job that runs until ctx is canceled.
+func (j *leaderJobs) add(job func(ctx context.Context)) {
+	*j = append(*j, job)
+}
+
+// every registers a job that calls fn every interval.
+func (j *leaderJobs) every(interval time.Duration, fn func(ctx context.Context)) {
+	j.add(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			fn(ctx)
+		}
+	})
+}
+
+// run starts every job and waits until all of them returned.
+func (j leaderJobs) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range j {
+		wg.Add(1)
+		go func(job func(ctx context.Context)) {
+			defer wg.Done()
+			job(ctx)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// leaderOnly guards the endpoints serving state the leader jobs keep in
+// memory (reports, unconfirmed events) or writing what only the leader
+// writes (failed-event replays): other replicas answer 503.
+func leaderOnly(elector *lock.LeaderElector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !elector.IsLeader() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "not the leader replica, see /api/v1/status/leader"})
+			return
+		}
+		c.Next()
+	}
+}
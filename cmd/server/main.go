@@ -3,20 +3,22 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/redis/go-redis/v9"
 	"gopkg.in/yaml.v3"
 
 	"github.com/nft_market_go/internal/chain"
+	"github.com/nft_market_go/internal/contracts"
 	"github.com/nft_market_go/internal/ipfs"
 	"github.com/nft_market_go/internal/lock"
 	"github.com/nft_market_go/internal/store"
@@ -24,11 +26,8 @@ import (
 
 // basicConfig holds minimal runtime configuration for the demo backend.
 type basicConfig struct {
-	RPCURL             string
-	ChainID            int64
-	MarketplaceAddress string
-	ProjectNFTAddress  string
-	Project1155Address string
+	Chains             []chainConfig
+	ABIDir             string // load contract ABIs from here instead of the embedded copies
 	MySQLDSN           string
 	RedisAddr          string
 	RedisPassword      string
@@ -38,22 +37,51 @@ type basicConfig struct {
 	PinataAPIKey       string
 	PinataSecretAPIKey string
 	HTTPAddr           string
+	AdminToken         string // required in X-Admin-Token by /api/v1/admin endpoints, which are disabled when empty
+}
+
+type yamlContracts struct {
+	Marketplace           string            `yaml:"marketplace"`
+	Marketplaces          []yamlMarketplace `yaml:"marketplaces"`
+	ProjectNFT            string            `yaml:"project-nft"`
+	ProjectNFTStartBlock  uint64            `yaml:"project-nft-start-block"`
+	Project1155           string            `yaml:"project-1155"`
+	Project1155StartBlock uint64            `yaml:"project-1155-start-block"`
+}
+
+type yamlMarketplace struct {
+	Address    string `yaml:"address"`
+	StartBlock uint64 `yaml:"start-block"`
+}
+
+type yamlChain struct {
+	Name          string        `yaml:"name"`
+	ChainID       int64         `yaml:"chain-id"`
+	RPCURL        string        `yaml:"rpc-url"`
+	RPCURLs       []string      `yaml:"rpc-urls"`
+	Confirmations uint64        `yaml:"confirmations"`
+	ExposePending bool          `yaml:"expose-pending"`
+	Contracts     yamlContracts `yaml:"contracts"`
 }
 
 type yamlConfig struct {
+	// Chains lists every network to index. When empty, a single chain is
+	// built from the blockchain / contracts sections below.
+	Chains     []yamlChain `yaml:"chains"`
 	Blockchain struct {
-		RPCURL  string `yaml:"rpc-url"`
-		ChainID int64  `yaml:"chain-id"`
+		RPCURL        string   `yaml:"rpc-url"`
+		RPCURLs       []string `yaml:"rpc-urls"`
+		ChainID       int64    `yaml:"chain-id"`
+		Confirmations uint64   `yaml:"confirmations"`
+		ExposePending bool     `yaml:"expose-pending"`
 	} `yaml:"blockchain"`
 	BSC struct {
 		RPCURL string `yaml:"rpc-url"`
 	} `yaml:"bsc"`
-	Contracts struct {
-		Marketplace string `yaml:"marketplace"`
-		ProjectNFT  string `yaml:"project-nft"`
-		Project1155 string `yaml:"project-1155"`
-	} `yaml:"contracts"`
-	MySQL struct {
+	Contracts yamlContracts `yaml:"contracts"`
+	// ABIDir overrides the contract ABIs embedded in the binary.
+	ABIDir string `yaml:"abi-dir"`
+	MySQL  struct {
 		DSN string `yaml:"dsn"`
 	} `yaml:"mysql"`
 	Redis struct {
@@ -68,14 +96,20 @@ type yamlConfig struct {
 		SecretAPIKey string `yaml:"secret-api-key"`
 	} `yaml:"ipfs"`
 	Server struct {
-		Addr string `yaml:"addr"`
+		Addr       string `yaml:"addr"`
+		AdminToken string `yaml:"admin-token"`
 	} `yaml:"server"`
 }
 
 // loadConfig reads config from config.yaml (if present) and environment variables.
-// Environment variables override YAML values when both are set.
+// Environment variables override YAML values when both are set. The chain
+// related variables (BSC_TESTNET_RPC_URL, NFT_MARKETPLACE_ADDRESS, ...) only
+// apply to the single-chain setup, i.e. when config.yaml has no chains list.
 func loadConfig() (*basicConfig, error) {
 	cfg := &basicConfig{}
+	single := chainConfig{Name: "default"}
+	var marketplace string
+	var marketplaces []yamlMarketplace
 
 	// 1) Load from config.yaml if it exists.
 	if data, err := os.ReadFile("config.yaml"); err == nil {
@@ -84,16 +118,39 @@ func loadConfig() (*basicConfig, error) {
 			return nil, err
 		}
 
+		for _, ch := range yc.Chains {
+			cfg.Chains = append(cfg.Chains, chainConfig{
+				Name:                  ch.Name,
+				ChainID:               ch.ChainID,
+				RPCURL:                ch.RPCURL,
+				RPCURLs:               ch.RPCURLs,
+				Confirmations:         ch.Confirmations,
+				ExposePending:         ch.ExposePending,
+				Marketplaces:          marketplaceConfigs(ch.Contracts.Marketplace, ch.Contracts.Marketplaces),
+				ProjectNFTAddress:     ch.Contracts.ProjectNFT,
+				ProjectNFTStartBlock:  ch.Contracts.ProjectNFTStartBlock,
+				Project1155Address:    ch.Contracts.Project1155,
+				Project1155StartBlock: ch.Contracts.Project1155StartBlock,
+			})
+		}
+
 		// Prefer the more generic blockchain section if present, fall back to legacy bsc.
 		if yc.Blockchain.RPCURL != "" {
-			cfg.RPCURL = yc.Blockchain.RPCURL
+			single.RPCURL = yc.Blockchain.RPCURL
 		} else {
-			cfg.RPCURL = yc.BSC.RPCURL
+			single.RPCURL = yc.BSC.RPCURL
 		}
-		cfg.ChainID = yc.Blockchain.ChainID
-		cfg.MarketplaceAddress = yc.Contracts.Marketplace
-		cfg.ProjectNFTAddress = yc.Contracts.ProjectNFT
-		cfg.Project1155Address = yc.Contracts.Project1155
+		single.RPCURLs = yc.Blockchain.RPCURLs
+		single.ChainID = yc.Blockchain.ChainID
+		single.Confirmations = yc.Blockchain.Confirmations
+		single.ExposePending = yc.Blockchain.ExposePending
+		marketplace = yc.Contracts.Marketplace
+		marketplaces = yc.Contracts.Marketplaces
+		single.ProjectNFTAddress = yc.Contracts.ProjectNFT
+		single.ProjectNFTStartBlock = yc.Contracts.ProjectNFTStartBlock
+		single.Project1155Address = yc.Contracts.Project1155
+		single.Project1155StartBlock = yc.Contracts.Project1155StartBlock
+		cfg.ABIDir = yc.ABIDir
 		cfg.MySQLDSN = yc.MySQL.DSN
 		cfg.RedisAddr = yc.Redis.Addr
 		cfg.RedisPassword = yc.Redis.Password
@@ -103,20 +160,42 @@ func loadConfig() (*basicConfig, error) {
 		cfg.PinataAPIKey = yc.IPFS.APIKey
 		cfg.PinataSecretAPIKey = yc.IPFS.SecretAPIKey
 		cfg.HTTPAddr = yc.Server.Addr
+		cfg.AdminToken = yc.Server.AdminToken
 	}
 
 	// 2) Override with environment variables when set.
 	if v := os.Getenv("BSC_TESTNET_RPC_URL"); v != "" {
-		cfg.RPCURL = v
+		single.RPCURL = v
+	}
+	if v := os.Getenv("RPC_BACKUP_URLS"); v != "" {
+		single.RPCURLs = nil
+		for _, u := range strings.Split(v, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				single.RPCURLs = append(single.RPCURLs, u)
+			}
+		}
+	}
+	if v := os.Getenv("CHAIN_CONFIRMATIONS"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+			single.Confirmations = n
+		}
+	}
+	if v := os.Getenv("CHAIN_EXPOSE_PENDING"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			single.ExposePending = b
+		}
 	}
 	if v := os.Getenv("NFT_MARKETPLACE_ADDRESS"); v != "" {
-		cfg.MarketplaceAddress = v
+		marketplace = v
 	}
 	if v := os.Getenv("PROJECT_NFT_ADDRESS"); v != "" {
-		cfg.ProjectNFTAddress = v
+		single.ProjectNFTAddress = v
 	}
 	if v := os.Getenv("PROJECT_1155_ADDRESS"); v != "" {
-		cfg.Project1155Address = v
+		single.Project1155Address = v
+	}
+	if v := os.Getenv("CONTRACT_ABI_DIR"); v != "" {
+		cfg.ABIDir = v
 	}
 	if v := os.Getenv("MYSQL_DSN"); v != "" {
 		cfg.MySQLDSN = v
@@ -147,9 +226,25 @@ func loadConfig() (*basicConfig, error) {
 	if v := os.Getenv("HTTP_ADDR"); v != "" {
 		cfg.HTTPAddr = v
 	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		cfg.AdminToken = v
+	}
 
-	if cfg.RPCURL == "" {
-		return nil, ErrMissingRPCURL
+	if len(cfg.Chains) == 0 {
+		if single.RPCURL == "" {
+			return nil, ErrMissingRPCURL
+		}
+		single.Marketplaces = marketplaceConfigs(marketplace, marketplaces)
+		cfg.Chains = []chainConfig{single}
+	}
+	for i := range cfg.Chains {
+		ch := &cfg.Chains[i]
+		if ch.Name == "" {
+			ch.Name = "chains[" + strconv.Itoa(i) + "]"
+		}
+		if ch.RPCURL == "" {
+			return nil, &configError{"chain " + ch.Name + ": rpc-url is required"}
+		}
 	}
 
 	return cfg, nil
@@ -191,7 +286,7 @@ const swaggerJSON = `{
     },
     "/api/v1/assets": {
       "get": {
-        "summary": "List NFT assets by owner",
+        "summary": "List NFT assets by owner (ERC1155 holdings are returned with the holder's balance as amount)",
         "parameters": [
           {
             "name": "owner",
@@ -199,6 +294,14 @@ const swaggerJSON = `{
             "required": true,
             "type": "string",
             "description": "Owner wallet address"
+          },
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Only return records of this chain (default: all chains)"
           }
         ],
         "responses": {
@@ -261,7 +364,7 @@ const swaggerJSON = `{
     },
     "/api/v1/assets/{id}/mint-info": {
       "post": {
-        "summary": "Update minted NFT info (token_id, nft_address, amount) for an asset",
+        "summary": "Record the token minted for an asset, derived from the mint transaction receipt",
         "consumes": ["application/json"],
         "parameters": [
           {
@@ -278,33 +381,57 @@ const swaggerJSON = `{
             "schema": {
               "type": "object",
               "properties": {
-                "token_id": {
+                "chain_id": {
                   "type": "integer",
-                  "format": "int64"
+                  "format": "int64",
+                  "description": "Chain ID; required when several chains are configured"
+                },
+                "tx_hash": {
+                  "type": "string",
+                  "description": "Mint transaction hash"
+                },
+                "token_id": {
+                  "type": "string",
+                  "description": "Optional decimal uint256; required when the transaction minted several tokens to the owner"
                 },
                 "nft_address": {
-                  "type": "string"
+                  "type": "string",
+                  "description": "Optional; required when the chain has no NFT contract configured"
                 },
                 "amount": {
-                  "type": "integer",
-                  "format": "int64"
+                  "type": "string",
+                  "description": "Optional decimal uint256; checked against the minted amount"
                 }
               },
-              "required": ["token_id", "nft_address"]
+              "required": ["tx_hash"]
             }
           }
         ],
         "responses": {
           "200": {
             "description": "OK"
+          },
+          "409": {
+            "description": "The transaction reverted or did not mint a matching token to the asset owner"
+          },
+          "425": {
+            "description": "The transaction is not mined yet, retry later"
           }
         }
       }
     },
     "/api/v1/assets/by-nft": {
       "get": {
-        "summary": "Get NFT asset by on-chain nft_address and token_id",
+        "summary": "Get NFT asset by on-chain chain_id, nft_address and token_id",
         "parameters": [
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Chain the record lives on; required when several chains are configured"
+          },
           {
             "name": "nft_address",
             "in": "query",
@@ -315,8 +442,8 @@ const swaggerJSON = `{
             "name": "token_id",
             "in": "query",
             "required": true,
-            "type": "integer",
-            "format": "int64"
+            "type": "string",
+            "description": "Decimal uint256"
           }
         ],
         "responses": {
@@ -332,6 +459,16 @@ const swaggerJSON = `{
     "/api/v1/orders": {
       "get": {
         "summary": "List recent marketplace orders",
+        "parameters": [
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Only return records of this chain (default: all chains)"
+          }
+        ],
         "responses": {
           "200": {
             "description": "OK"
@@ -349,9 +486,18 @@ const swaggerJSON = `{
             "schema": {
               "type": "object",
               "properties": {
-                "listing_id": {
+                "chain_id": {
                   "type": "integer",
-                  "format": "int64"
+                  "format": "int64",
+                  "description": "Chain ID; required when several chains are configured"
+                },
+                "marketplace": {
+                  "type": "string",
+                  "description": "Marketplace contract address; required when the chain has several marketplaces"
+                },
+                "listing_id": {
+                  "type": "string",
+                  "description": "Decimal uint256 string (JSON integers are also accepted)"
                 },
                 "seller": {
                   "type": "string"
@@ -360,12 +506,12 @@ const swaggerJSON = `{
                   "type": "string"
                 },
                 "token_id": {
-                  "type": "integer",
-                  "format": "int64"
+                  "type": "string",
+                  "description": "Decimal uint256 string (JSON integers are also accepted)"
                 },
                 "amount": {
-                  "type": "integer",
-                  "format": "int64"
+                  "type": "string",
+                  "description": "Decimal uint256 string (JSON integers are also accepted)"
                 },
                 "nft_name": {
                   "type": "string",
@@ -380,28 +526,252 @@ const swaggerJSON = `{
                   "description": "Price in wei, decimal string"
                 },
                 "tx_hash": {
-                  "type": "string"
+                  "type": "string",
+                  "description": "List transaction; its receipt is used to verify the listing, otherwise listings(listingId) is read"
                 }
               },
               "required": ["listing_id", "seller", "nft_address", "token_id", "price"]
             }
           }
         ],
+        "responses": {
+          "200": {
+            "description": "Verified and applied"
+          },
+          "202": {
+            "description": "Not verifiable yet; queued callback returned, see /api/v1/orders/callbacks/{id}"
+          },
+          "409": {
+            "description": "Contradicted by the chain, listing already ended or changed since, or the listing stayed locked by a concurrent request for 3s"
+          }
+        }
+      }
+    },
+    "/api/v1/status/rpc": {
+      "get": {
+        "summary": "Health of the RPC endpoints of every configured chain (latency, error rate, cooldown)",
+        "responses": {
+          "200": {
+            "description": "OK"
+          }
+        }
+      }
+    },
+    "/api/v1/status/reconcile": {
+      "get": {
+        "summary": "Latest listings() reconciliation report of every marketplace (drift between orders and on-chain listings)",
+        "parameters": [
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Only return records of this chain (default: all chains)"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "503": {
+            "description": "Not the leader replica"
+          }
+        }
+      }
+    },
+    "/api/v1/status/leader": {
+      "get": {
+        "summary": "Leader election state: whether this replica runs the scanners / reconcilers, its term number, and the current lease holder",
+        "responses": {
+          "200": {
+            "description": "OK"
+          }
+        }
+      }
+    },
+    "/api/v1/status/listing-gaps": {
+      "get": {
+        "summary": "Latest listing ID gap check of every marketplace (missing, filled and unresolved listing IDs below nextListingId)",
+        "parameters": [
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Only return records of this chain (default: all chains)"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "503": {
+            "description": "Not the leader replica"
+          }
+        }
+      }
+    },
+    "/api/v1/admin/failed-events": {
+      "get": {
+        "summary": "List contract logs the scanners failed to handle (dead letter queue), newest first",
+        "parameters": [
+          {
+            "name": "X-Admin-Token",
+            "in": "header",
+            "required": true,
+            "type": "string",
+            "description": "Value of server.admin-token / ADMIN_TOKEN; the admin endpoints are not served when it is not set"
+          },
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Only return records of this chain (default: all chains)"
+          },
+          {
+            "name": "status",
+            "in": "query",
+            "required": false,
+            "type": "string",
+            "enum": ["PENDING", "RESOLVED", "DEAD", "DISCARDED"]
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "description": "1-500, default 50"
+          },
+          {
+            "name": "offset",
+            "in": "query",
+            "required": false,
+            "type": "integer"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "401": {
+            "description": "Missing or wrong admin token"
+          }
+        }
+      }
+    },
+    "/api/v1/admin/failed-events/{id}/replay": {
+      "post": {
+        "summary": "Handle a PENDING or DEAD failed event again right away",
+        "parameters": [
+          {
+            "name": "X-Admin-Token",
+            "in": "header",
+            "required": true,
+            "type": "string",
+            "description": "Value of server.admin-token / ADMIN_TOKEN; the admin endpoints are not served when it is not set"
+          },
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "type": "integer",
+            "format": "int64"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Handled (RESOLVED), or discarded because its block was reorged away (DISCARDED)"
+          },
+          "401": {
+            "description": "Missing or wrong admin token"
+          },
+          "404": {
+            "description": "Not found"
+          },
+          "409": {
+            "description": "Already RESOLVED or DISCARDED"
+          },
+          "422": {
+            "description": "The handler failed again; the event and error are returned"
+          },
+          "503": {
+            "description": "Not the leader replica"
+          }
+        }
+      }
+    },
+    "/api/v1/orders/pending": {
+      "get": {
+        "summary": "List unconfirmed marketplace events (status PENDING), when expose-pending is enabled",
+        "parameters": [
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Only return records of this chain (default: all chains)"
+          }
+        ],
         "responses": {
           "200": {
             "description": "OK"
+          },
+          "503": {
+            "description": "Not the leader replica"
           }
         }
       }
     },
     "/api/v1/orders/{listingId}": {
       "get": {
-        "summary": "Get marketplace order by listingId",
+        "summary": "Get marketplace order by chain_id, marketplace and listingId",
         "parameters": [
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Chain the record lives on; required when several chains are configured"
+          },
+          {
+            "name": "marketplace",
+            "in": "query",
+            "required": false,
+            "type": "string",
+            "description": "Marketplace contract address; required when the chain has several marketplaces"
+          },
           {
             "name": "listingId",
             "in": "path",
             "required": true,
+            "type": "string",
+            "description": "Decimal uint256"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "404": {
+            "description": "Not found"
+          }
+        }
+      }
+    },
+    "/api/v1/orders/callbacks/{id}": {
+      "get": {
+        "summary": "Verification state of a queued order callback (PENDING, CONFIRMED, REJECTED, EXPIRED)",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
             "type": "integer",
             "format": "int64"
           }
@@ -416,6 +786,40 @@ const swaggerJSON = `{
         }
       }
     },
+    "/api/v1/orders/{listingId}/events": {
+      "get": {
+        "summary": "Order history (listing, cancel, sale, approval changes), oldest first",
+        "parameters": [
+          {
+            "name": "chain_id",
+            "in": "query",
+            "required": false,
+            "type": "integer",
+            "format": "int64",
+            "description": "Chain the listing lives on; required when several chains are configured"
+          },
+          {
+            "name": "marketplace",
+            "in": "query",
+            "required": false,
+            "type": "string",
+            "description": "Marketplace contract address; required when the chain has several marketplaces"
+          },
+          {
+            "name": "listingId",
+            "in": "path",
+            "required": true,
+            "type": "string",
+            "description": "Decimal uint256"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK"
+          }
+        }
+      }
+    },
     "/api/v1/orders/{listingId}/status": {
       "post": {
         "summary": "Update order status after cancel or buy (frontend callback)",
@@ -425,8 +829,8 @@ const swaggerJSON = `{
             "name": "listingId",
             "in": "path",
             "required": true,
-            "type": "integer",
-            "format": "int64"
+            "type": "string",
+            "description": "Decimal uint256"
           },
           {
             "name": "body",
@@ -435,6 +839,15 @@ const swaggerJSON = `{
             "schema": {
               "type": "object",
               "properties": {
+                "chain_id": {
+                  "type": "integer",
+                  "format": "int64",
+                  "description": "Chain ID; required when several chains are configured"
+                },
+                "marketplace": {
+                  "type": "string",
+                  "description": "Marketplace contract address; required when the chain has several marketplaces"
+                },
                 "status": {
                   "type": "string",
                   "description": "CANCELED or SUCCESS"
@@ -442,15 +855,25 @@ const swaggerJSON = `{
                 "buyer": {
                   "type": "string",
                   "description": "Buyer address, required when status is SUCCESS"
+                },
+                "tx_hash": {
+                  "type": "string",
+                  "description": "Cancel / buy transaction; its receipt is used to verify the status"
                 }
               },
-              "required": ["status"]
+              "required": ["status", "tx_hash"]
             }
           }
         ],
         "responses": {
           "200": {
-            "description": "OK"
+            "description": "Verified and applied"
+          },
+          "202": {
+            "description": "Not verifiable yet; queued callback returned, see /api/v1/orders/callbacks/{id}"
+          },
+          "409": {
+            "description": "Contradicted by the chain, order already finalized, or the listing stayed locked by a concurrent request for 3s"
           }
         }
       }
@@ -486,20 +909,34 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
+	if cfg.ABIDir != "" {
+		contracts.SetABIDir(cfg.ABIDir)
+		log.Printf("loading contract ABIs from %s", cfg.ABIDir)
+	}
 
 	if cfg.MySQLDSN == "" {
 		log.Fatal("MYSQL_DSN is required, example: user:password@tcp(127.0.0.1:3306)/nft_market?parseTime=true&charset=utf8mb4")
 	}
 
+	// Subcommand: go run ./cmd/server backfill -from <block> [-to <block>]
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		if err := runBackfill(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("backfill failed: %v", err)
+		}
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Establish a basic connection to BSC testnet (or other EVM RPC).
-	ethClient, err := ethclient.DialContext(ctx, cfg.RPCURL)
+	// Connect to every configured EVM chain (BSC testnet, ...). On each chain
+	// calls are spread over all its endpoints and fail over when one of them
+	// misbehaves.
+	chains, err := dialChains(ctx, cfg.Chains)
 	if err != nil {
 		log.Fatalf("failed to connect to rpc: %v", err)
 	}
-	defer ethClient.Close()
+	defer chains.Close()
 
 	// Connect to MySQL using database/sql.
 	db, err := sql.Open("mysql", cfg.MySQLDSN)
@@ -534,53 +971,243 @@ func main() {
 
 	log.Printf("connected to mysql")
 
+	// Creating the tables, or upgrading those created by an older version,
+	// may take a while on large tables.
+	schemaCtx, cancelSchema := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelSchema()
+
 	// Initialize basic schema for marketplace orders and NFT assets.
 	orderStore := store.NewOrderStore(db)
-	if err := orderStore.InitSchema(ctx); err != nil {
+	if err := orderStore.InitSchema(schemaCtx); err != nil {
 		log.Fatalf("failed to init orders schema: %v", err)
 	}
 
 	assetStore := store.NewNftAssetStore(db)
-	if err := assetStore.InitSchema(ctx); err != nil {
+	if err := assetStore.InitSchema(schemaCtx); err != nil {
 		log.Fatalf("failed to init nft_assets schema: %v", err)
 	}
 
-	// IPFS (Pinata) client for uploading files.
-	ipfsClient := ipfs.NewPinataClient(
-		cfg.PinataAPIURL,
-		cfg.PinataGatewayURL,
-		cfg.PinataAPIKey,
+	checkpointStore := store.NewCheckpointStore(db)
+	if err := checkpointStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init scanner_checkpoints schema: %v", err)
+	}
+
+	reorgStore := store.NewReorgStore(db)
+	if err := reorgStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init reorg tracking schema: %v", err)
+	}
+
+	tokenOwnerStore := store.NewTokenOwnerStore(db)
+	if err := tokenOwnerStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init nft_token_owners schema: %v", err)
+	}
+
+	// nft_token_balances is also read by GET /assets, so it is created even
+	// when the ERC1155 scanner is disabled.
+	tokenBalanceStore := store.NewTokenBalanceStore(db)
+	if err := tokenBalanceStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init nft_token_balances schema: %v", err)
+	}
+
+	tokenURIStore := store.NewTokenURIStore(db)
+	if err := tokenURIStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init nft_token_uris schema: %v", err)
+	}
+
+	approvalStore := store.NewApprovalStore(db)
+	if err := approvalStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init approval schema: %v", err)
+	}
+
+	callbackStore := store.NewCallbackStore(db)
+	if err := callbackStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init order_callbacks schema: %v", err)
+	}
+
+	pendingTxStore := store.NewPendingTxStore(db)
+	if err := pendingTxStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init pending_txs schema: %v", err)
+	}
+
+	failedEventStore := store.NewFailedEventStore(db)
+	if err := failedEventStore.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init failed_events schema: %v", err)
+	}
+
+	leaderFence := store.NewLeaderFence(db, "indexer")
+	if err := leaderFence.InitSchema(schemaCtx); err != nil {
+		log.Fatalf("failed to init leader_fences schema: %v", err)
+	}
+
+	// Rows stored by a single-chain version belong to the first configured chain.
+	legacy := chains.list[0]
+	if err := store.AssignLegacyRows(schemaCtx, db, legacy.id, legacy.cfg.legacyMarketplace()); err != nil {
+		log.Fatalf("failed to assign legacy rows to chain %d: %v", legacy.id, err)
+	}
+
+	// Background jobs that index the chains and write orders. They only run
+	// on the elected leader replica, see leaderJobs.
+	var jobs leaderJobs
+
+	// Logs the scanners fail to handle are kept in failed_events and retried
+	// with backoff instead of being lost once the scanner moves on.
+	deadLetters := chain.NewDeadLetterQueue(failedEventStore, log.Default())
+	jobs.add(deadLetters.Run)
+
+	// Order callbacks from the frontend are verified against the chain; those
+	// that cannot be verified yet are queued and confirmed in the background.
+	orderCallbackSvc := &orderCallbacks{
+		db:        db,
+		chains:    chains,
+		orders:    orderStore,
+		assets:    assetStore,
+		callbacks: callbackStore,
+		locker:    orderLocker,
+	}
+	jobs.add(orderCallbackSvc.runConfirmer)
+
+	// IPFS (Pinata) client for uploading files.
+	ipfsClient := ipfs.NewPinataClient(
+		cfg.PinataAPIURL,
+		cfg.PinataGatewayURL,
+		cfg.PinataAPIKey,
 		cfg.PinataSecretAPIKey,
 	)
 
-	// Start marketplace event scanner (Listed / Cancelled / Sold) to sync orders table.
-	if cfg.MarketplaceAddress == "" {
-		log.Printf("NFT_MARKETPLACE_ADDRESS not set, marketplace scanner disabled")
-	} else {
-		marketAddr := common.HexToAddress(cfg.MarketplaceAddress)
-		scanner, err := chain.NewMarketplaceScanner(ethClient, marketAddr, orderStore, log.Default())
-		if err != nil {
-			log.Printf("failed to init marketplace scanner: %v", err)
-		} else {
-			go scanner.Run(context.Background())
+	// Copies URI events onto nft_assets and fetches their metadata through the
+	// IPFS gateway. Created with the first ERC1155 scanner and shared by all chains.
+	var refresher *chain.MetadataRefresher
+
+	// Start one set of scanners per configured chain.
+	for _, rt := range chains.list {
+		cc := rt.cfg
+
+		// Follow buy / cancel transactions reported by the frontend before
+		// they were mined (LOCKED -> SETTLING -> SUCCESS / CANCELED, back to
+		// LISTED when the transaction fails).
+		rt.tracker = chain.NewTxTracker(rt.pool, db, rt.id, pendingTxStore, orderStore, rt.verifier, log.Default())
+		rt.tracker.SetConfirmations(cc.Confirmations)
+		jobs.add(rt.tracker.Run)
+
+		// Start one marketplace event scanner (Listed / Cancelled / Sold) per
+		// marketplace contract to sync the orders table.
+		if len(cc.Marketplaces) == 0 {
+			log.Printf("chain %s: marketplace address not set, marketplace scanner disabled", cc.Name)
+		}
+		for _, mc := range cc.Marketplaces {
+			marketAddr := common.HexToAddress(mc.Address)
+			scanner, err := chain.NewMarketplaceScanner(rt.pool, db, rt.id, marketAddr, orderStore, checkpointStore, reorgStore, log.Default())
+			if err != nil {
+				log.Printf("chain %s: failed to init marketplace scanner for %s: %v", cc.Name, mc.Address, err)
+				continue
+			}
+			rt.marketScanners = append(rt.marketScanners, scanner)
+			scanner.SetConfirmations(cc.Confirmations, cc.ExposePending)
+			scanner.SetStartBlock(mc.StartBlock)
+			scanner.SetDeadLetterQueue(deadLetters)
+			if rt.pool.HasWebsocket() {
+				scanner.EnableSubscription()
+			}
+			jobs.add(scanner.Run)
 			// Periodic reconciliation job: rescan recent blocks to repair backend
 			// state in case some events were missed (e.g. RPC errors, process restarts).
-			go func() {
-				ticker := time.NewTicker(1 * time.Minute)
-				defer ticker.Stop()
-				for range ticker.C {
-					reconCtx, cancelRecon := context.WithTimeout(context.Background(), 30*time.Second)
-					if err := scanner.ResyncRecent(reconCtx, 300); err != nil {
-						log.Printf("marketplace reconcile recent events error: %v", err)
-					}
-					cancelRecon()
+			jobs.every(1*time.Minute, func(ctx context.Context) {
+				reconCtx, cancelRecon := context.WithTimeout(ctx, 30*time.Second)
+				if err := scanner.ResyncRecent(reconCtx, 300); err != nil {
+					log.Printf("chain %s: marketplace %s reconcile recent events error: %v", cc.Name, scanner.Contract().Hex(), err)
+				}
+				cancelRecon()
+			})
+			// Compare open orders with the listings() view and repair drift
+			// the event based jobs above cannot see (e.g. events missed long ago).
+			jobs.every(10*time.Minute, func(ctx context.Context) {
+				reconCtx, cancelRecon := context.WithTimeout(ctx, 5*time.Minute)
+				if _, err := scanner.ReconcileListings(reconCtx); err != nil {
+					log.Printf("chain %s: marketplace %s reconcile listings error: %v", cc.Name, scanner.Contract().Hex(), err)
 				}
-			}()
-			log.Printf("marketplace scanner started for contract %s", cfg.MarketplaceAddress)
+				cancelRecon()
+			})
+			// Listing IDs are sequential: fill IDs below nextListingId() whose
+			// Listed event was lost.
+			jobs.every(5*time.Minute, func(ctx context.Context) {
+				gapCtx, cancelGap := context.WithTimeout(ctx, 5*time.Minute)
+				if _, err := scanner.FillListingGaps(gapCtx); err != nil {
+					log.Printf("chain %s: marketplace %s fill listing gaps error: %v", cc.Name, scanner.Contract().Hex(), err)
+				}
+				cancelGap()
+			})
+			log.Printf("chain %s: marketplace scanner configured for contract %s (start block=%d, confirmations=%d, subscription=%t)", cc.Name, mc.Address, mc.StartBlock, cc.Confirmations, rt.pool.HasWebsocket())
 		}
+
+		// Start ProjectNFT Transfer scanner to keep token ownership and nft_assets.owner in sync.
+		if cc.ProjectNFTAddress == "" {
+			log.Printf("chain %s: project-nft address not set, erc721 scanner disabled", cc.Name)
+		} else {
+			nftAddr := common.HexToAddress(cc.ProjectNFTAddress)
+			scanner, err := chain.NewERC721Scanner(rt.pool, db, rt.id, nftAddr, tokenOwnerStore, assetStore, checkpointStore, log.Default())
+			if err != nil {
+				log.Printf("chain %s: failed to init erc721 scanner: %v", cc.Name, err)
+			} else {
+				if len(cc.Marketplaces) > 0 {
+					scanner.SetApprovalTracking(approvalStore, orderStore, cc.marketplaceAddresses())
+				}
+				scanner.SetStartBlock(cc.ProjectNFTStartBlock)
+				scanner.SetConfirmations(cc.Confirmations)
+				scanner.SetDeadLetterQueue(deadLetters)
+				if rt.pool.HasWebsocket() {
+					scanner.EnableSubscription()
+				}
+				jobs.add(scanner.Run)
+				log.Printf("chain %s: erc721 scanner configured for contract %s (start block=%d)", cc.Name, cc.ProjectNFTAddress, cc.ProjectNFTStartBlock)
+			}
+		}
+
+		// Start Project1155 TransferSingle / TransferBatch scanner to keep per-holder balances.
+		if cc.Project1155Address == "" {
+			log.Printf("chain %s: project-1155 address not set, erc1155 scanner disabled", cc.Name)
+		} else {
+			addr1155 := common.HexToAddress(cc.Project1155Address)
+			scanner, err := chain.NewERC1155Scanner(rt.pool, db, rt.id, addr1155, tokenBalanceStore, tokenURIStore, checkpointStore, log.Default())
+			if err != nil {
+				log.Printf("chain %s: failed to init erc1155 scanner: %v", cc.Name, err)
+			} else {
+				if refresher == nil {
+					refresher = chain.NewMetadataRefresher(tokenURIStore, assetStore, ipfsClient, log.Default())
+					jobs.add(refresher.Run)
+				}
+				scanner.SetMetadataRefresher(refresher)
+				if len(cc.Marketplaces) > 0 {
+					scanner.SetApprovalTracking(approvalStore, orderStore, cc.marketplaceAddresses())
+				}
+				scanner.SetStartBlock(cc.Project1155StartBlock)
+				scanner.SetConfirmations(cc.Confirmations)
+				scanner.SetDeadLetterQueue(deadLetters)
+				if rt.pool.HasWebsocket() {
+					scanner.EnableSubscription()
+				}
+				jobs.add(scanner.Run)
+				log.Printf("chain %s: erc1155 scanner configured for contract %s (start block=%d)", cc.Name, cc.Project1155Address, cc.Project1155StartBlock)
+			}
+		}
+
+		log.Printf("chain %s: connected to chain id %d via rpc %s (%d endpoints)", cc.Name, rt.id, cc.RPCURL, len(rt.pool.Status()))
 	}
 
-	log.Printf("connected to rpc %s", cfg.RPCURL)
+	// Only one replica runs the jobs above; the others serve HTTP only and
+	// take over within seconds when the leader's lease runs out. Each term
+	// takes a fencing token from MySQL; order and checkpoint writes of the
+	// jobs fail once a later term has taken one.
+	elector := lock.NewLeaderElector(lock.NewRedisLocker(rdb, "nft_market:leader:"), "indexer", 10*time.Second, log.Default())
+	go elector.Run(context.Background(), func(ctx context.Context, term int64) {
+		token, err := leaderFence.Next(ctx)
+		if err != nil {
+			log.Printf("leader (term %d): take fencing token error: %v", term, err)
+			return
+		}
+		log.Printf("leader (term %d, fencing token %d): starting %d background jobs", term, token, len(jobs))
+		jobs.run(store.WithFence(ctx, leaderFence, token))
+		log.Printf("leader (term %d, fencing token %d): background jobs stopped", term, token)
+	})
 
 	// TODO: in later steps, initialize contract bindings, event subscribers,
 	// and services that expose marketplace/NFT read APIs.
@@ -595,12 +1222,95 @@ func main() {
 	// RESTful API v1.
 	api := router.Group("/api/v1")
 
+	// Health of the RPC endpoints of every chain (latency / error rate moving averages).
+	api.GET("/status/rpc", func(c *gin.Context) {
+		type chainRPCStatus struct {
+			ChainID   int64                     `json:"chain_id"`
+			Name      string                    `json:"name"`
+			Endpoints []chain.RPCEndpointStatus `json:"endpoints"`
+		}
+		out := make([]chainRPCStatus, 0, len(chains.list))
+		for _, rt := range chains.list {
+			out = append(out, chainRPCStatus{ChainID: rt.id, Name: rt.cfg.Name, Endpoints: rt.pool.Status()})
+		}
+		c.JSON(http.StatusOK, out)
+	})
+
+	// Leader election state: whether this replica runs the background jobs,
+	// and which replica holds the lease.
+	api.GET("/status/leader", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+		defer cancel()
+
+		st, err := elector.Status(ctx)
+		if err != nil {
+			log.Printf("leader status error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		c.JSON(http.StatusOK, st)
+	})
+
+	// Latest listings() reconciliation report of every marketplace scanner
+	// (only on the leader replica, which runs the reconcile job; 503 elsewhere).
+	// Optional chain_id query param narrows the list to one chain.
+	api.GET("/status/reconcile", leaderOnly(elector), func(c *gin.Context) {
+		chainID, err := chains.filter(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reports := []*chain.ListingReconcileReport{}
+		for _, rt := range chains.list {
+			if chainID != 0 && rt.id != chainID {
+				continue
+			}
+			for _, scanner := range rt.marketScanners {
+				if r := scanner.LastReconcile(); r != nil {
+					reports = append(reports, r)
+				}
+			}
+		}
+		c.JSON(http.StatusOK, reports)
+	})
+
+	// Latest listing ID gap check of every marketplace scanner (leader only,
+	// 503 elsewhere). Optional chain_id query param narrows the list to one chain.
+	api.GET("/status/listing-gaps", leaderOnly(elector), func(c *gin.Context) {
+		chainID, err := chains.filter(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reports := []*chain.ListingGapReport{}
+		for _, rt := range chains.list {
+			if chainID != 0 && rt.id != chainID {
+				continue
+			}
+			for _, scanner := range rt.marketScanners {
+				if r := scanner.LastGapCheck(); r != nil {
+					reports = append(reports, r)
+				}
+			}
+		}
+		c.JSON(http.StatusOK, reports)
+	})
+
 	// Orders (read-only, from MySQL mirror of on-chain marketplace).
+	// Optional chain_id query param narrows the list to one chain.
 	api.GET("/orders", func(c *gin.Context) {
+		chainID, err := chains.filter(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 		defer cancel()
 
-		orders, err := orderStore.ListRecent(ctx, 50)
+		orders, err := orderStore.ListRecent(ctx, chainID, 50)
 		if err != nil {
 			log.Printf("ListRecent error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
@@ -609,18 +1319,49 @@ func main() {
 		c.JSON(http.StatusOK, orders)
 	})
 
+	// Unconfirmed marketplace events (status PENDING), not yet applied to orders.
+	// Empty unless blockchain.expose-pending / CHAIN_EXPOSE_PENDING is enabled.
+	// Only the leader replica scans for them; 503 elsewhere.
+	api.GET("/orders/pending", leaderOnly(elector), func(c *gin.Context) {
+		chainID, err := chains.filter(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		events := []chain.PendingEvent{}
+		for _, rt := range chains.list {
+			if chainID != 0 && rt.id != chainID {
+				continue
+			}
+			for _, scanner := range rt.marketScanners {
+				events = append(events, scanner.PendingEvents()...)
+			}
+		}
+		c.JSON(http.StatusOK, events)
+	})
+
 	api.GET("/orders/:listingId", func(c *gin.Context) {
-		idStr := c.Param("listingId")
-		id, err := strconv.ParseInt(idStr, 10, 64)
+		id, err := parseUint256(c.Param("listingId"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid listingId"})
 			return
 		}
+		rt, err := chains.param(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		marketplace, err := rt.marketplace(c.Query("marketplace"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 		defer cancel()
 
-		order, err := orderStore.GetByID(ctx, id)
+		order, err := orderStore.GetByID(ctx, rt.id, marketplace, id)
 		if err != nil {
 			log.Printf("GetByID error: %v", err)
 			if err == sql.ErrNoRows {
@@ -634,40 +1375,112 @@ func main() {
 		c.JSON(http.StatusOK, order)
 	})
 
+	// A callback queued by POST /orders or POST /orders/:listingId/status
+	// (202 response) because the chain could not confirm it yet.
+	api.GET("/orders/callbacks/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+		defer cancel()
+
+		cb, err := callbackStore.GetByID(ctx, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			} else {
+				log.Printf("get order callback error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, cb)
+	})
+
+	// Order history (order_events), oldest first. Chain events whose block was
+	// reorged away are kept with removed = true.
+	api.GET("/orders/:listingId/events", func(c *gin.Context) {
+		id, err := parseUint256(c.Param("listingId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid listingId"})
+			return
+		}
+		rt, err := chains.param(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		marketplace, err := rt.marketplace(c.Query("marketplace"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+		defer cancel()
+
+		events, err := orderStore.ListEvents(ctx, rt.id, marketplace, id)
+		if err != nil {
+			log.Printf("ListEvents error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		if events == nil {
+			events = []*store.OrderEvent{}
+		}
+
+		c.JSON(http.StatusOK, events)
+	})
+
 	// Create or update an order record after frontend successfully lists on-chain.
 	// This is a fallback to RPC event scanning: frontend passes listingId and related fields.
+	// The listing is checked against the chain first (the list tx receipt, or
+	// listings(listingId) without tx_hash); callbacks the chain cannot confirm
+	// yet are queued and answered with 202.
 	api.POST("/orders", func(c *gin.Context) {
-		var req struct {
-			ListingID  int64  `json:"listing_id"`
-			Seller     string `json:"seller"`
-			NFTAddress string `json:"nft_address"`
-			TokenID    int64  `json:"token_id"`
-			Amount     int64  `json:"amount"`
-			NFTName    string `json:"nft_name"`
-			URL        string `json:"url"`
-			Price      string `json:"price"`   // decimal string in wei
-			TxHash     string `json:"tx_hash"` // optional tx hash of list transaction
-		}
+		var req createOrderRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json body"})
 			return
 		}
-		if req.ListingID <= 0 || req.Seller == "" || req.NFTAddress == "" || req.TokenID <= 0 || req.Price == "" {
+		if req.ListingID == "" || req.Seller == "" || req.NFTAddress == "" || req.TokenID == "" || req.Price == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "listing_id, seller, nft_address, token_id, price are required"})
 			return
 		}
-		if req.Amount <= 0 {
-			req.Amount = 1
+		if req.Amount == "" || req.Amount == "0" {
+			req.Amount = "1"
+		}
+		rt, err := chains.lookup(req.ChainID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		marketplace, err := rt.marketplace(req.Marketplace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		claim, err := req.claim(marketplace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
 
+		if !orderCallbackSvc.verify(ctx, c, rt, claim, &req) {
+			return
+		}
+
 		// Acquire per-listing lock to prevent concurrent create/update on the same listing.
-		lockKey := "listing:" + strconv.FormatInt(req.ListingID, 10)
-		orderLock, err := orderLocker.Acquire(ctx, lockKey, 10*time.Second)
+		lockKey := listingLockKey(rt.id, marketplace, string(req.ListingID))
+		orderLock, err := acquireListingLock(ctx, orderLocker, lockKey)
 		if err != nil {
-			if err == lock.ErrLockNotAcquired {
+			if errors.Is(err, lock.ErrLockNotAcquired) {
 				c.JSON(http.StatusConflict, gin.H{"error": "order is being processed, please retry"})
 			} else {
 				log.Printf("acquire order lock error: %v", err)
@@ -682,74 +1495,16 @@ func main() {
 			}
 		}()
 
-		// 如果前端没有传 nft_name 或 url，尝试从 nft_assets 中按 nft_address + token_id 读取。
-		if (req.NFTName == "" || req.URL == "") && req.NFTAddress != "" && req.TokenID > 0 {
-			if asset, err := assetStore.GetByNFT(ctx, req.NFTAddress, req.TokenID); err == nil {
-				if req.NFTName == "" {
-					req.NFTName = asset.Name
-				}
-				if req.URL == "" {
-					req.URL = asset.URL
-				}
-			}
-		}
-
-		tx, err := db.BeginTx(ctx, nil)
+		// Keep the lock while the transaction runs; ctx is canceled (and the
+		// transaction rolled back) if it is lost anyway.
+		orderOut, err := orderCallbackSvc.createOrder(orderLock.KeepAlive(ctx), rt, marketplace, &req, claim)
 		if err != nil {
-			log.Printf("begin tx for create order error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db begin tx failed"})
-			return
-		}
-		committed := false
-		defer func() {
-			if !committed {
-				if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
-					log.Printf("rollback tx for create order error: %v", err)
-				}
+			if err == errOrderFinalized || err == errOrderSuperseded {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
 			}
-		}()
-
-		order := &store.Order{
-			ListingID:  req.ListingID,
-			Seller:     req.Seller,
-			Buyer:      "",
-			NFTName:    req.NFTName,
-			NFTAddress: req.NFTAddress,
-			URL:        req.URL,
-			TokenID:    req.TokenID,
-			Amount:     req.Amount,
-			Price:      req.Price,
-			Status:     store.OrderStatusListed,
-			TxHash:     req.TxHash,
-			Deleted:    0,
-		}
-
-		if err := orderStore.UpsertTx(ctx, tx, order); err != nil {
-			log.Printf("Create order error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db upsert failed"})
-			return
-		}
-
-		// 上架后，这个 NFT 由订单管理，不再作为“可用素材”展示：
-		// 根据 nft_address + token_id 做逻辑删除（deleted = 1），取消挂单时再恢复。
-		if err := assetStore.SoftDeleteByNFTTx(ctx, tx, req.NFTAddress, req.TokenID); err != nil {
-			log.Printf("SoftDeleteByNFTTx error (nft_address=%s, token_id=%d): %v", req.NFTAddress, req.TokenID, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db asset update failed"})
-			return
-		}
-
-		if err := tx.Commit(); err != nil {
-			log.Printf("commit tx for create order error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db commit failed"})
-			return
-		}
-		committed = true
-
-		// Read back full record including order_id / timestamps.
-		orderOut, err := orderStore.GetByID(ctx, req.ListingID)
-		if err != nil {
-			log.Printf("GetByID after create order error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			log.Printf("create order error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 			return
 		}
 
@@ -758,45 +1513,54 @@ func main() {
 
 	// Update order status after frontend confirms cancel / buy on-chain.
 	// Example payloads:
-	//  - cancel: { "status": "CANCELED" }
-	//  - buy:    { "status": "SUCCESS", "buyer": "0xBuyer..." }
+	//  - cancel: { "status": "CANCELED", "tx_hash": "0x..." }
+	//  - buy:    { "status": "SUCCESS", "buyer": "0xBuyer...", "tx_hash": "0x..." }
+	// Verified against the chain like POST /orders.
 	api.POST("/orders/:listingId/status", func(c *gin.Context) {
-		idStr := c.Param("listingId")
-		id, err := strconv.ParseInt(idStr, 10, 64)
+		id, err := parseUint256(c.Param("listingId"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid listingId"})
 			return
 		}
 
-		var req struct {
-			Status string `json:"status"`
-			Buyer  string `json:"buyer"`
-		}
+		var req orderStatusRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json body"})
 			return
 		}
-
-		var newStatus store.OrderStatus
-		switch req.Status {
-		case string(store.OrderStatusCanceled):
-			newStatus = store.OrderStatusCanceled
-		case string(store.OrderStatusSuccess):
-			newStatus = store.OrderStatusSuccess
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be CANCELED or SUCCESS"})
+		if _, err := req.newStatus(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rt, err := chains.lookup(req.ChainID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		marketplace, err := rt.marketplace(req.Marketplace)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		claim, err := req.claim(marketplace, id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
 
+		if !orderCallbackSvc.verify(ctx, c, rt, claim, &req) {
+			return
+		}
+
 		// Acquire per-listing lock to serialize status updates and avoid
 		// concurrent buyers updating the same order.
-		lockKey := "listing:" + strconv.FormatInt(id, 10)
-		statusLock, err := orderLocker.Acquire(ctx, lockKey, 10*time.Second)
+		lockKey := listingLockKey(rt.id, marketplace, id)
+		statusLock, err := acquireListingLock(ctx, orderLocker, lockKey)
 		if err != nil {
-			if err == lock.ErrLockNotAcquired {
+			if errors.Is(err, lock.ErrLockNotAcquired) {
 				c.JSON(http.StatusConflict, gin.H{"error": "order is being processed, please retry"})
 			} else {
 				log.Printf("acquire order status lock error: %v", err)
@@ -810,88 +1574,14 @@ func main() {
 			}
 		}()
 
-		tx, err := db.BeginTx(ctx, nil)
+		orderOut, err := orderCallbackSvc.updateStatus(statusLock.KeepAlive(ctx), rt, marketplace, id, &req)
 		if err != nil {
-			log.Printf("begin tx for order status error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db begin tx failed"})
-			return
-		}
-		committed := false
-		defer func() {
-			if !committed {
-				if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
-					log.Printf("rollback tx for order status error: %v", err)
-				}
-			}
-		}()
-
-		order, err := orderStore.GetByIDForUpdateTx(ctx, tx, id)
-		if err != nil {
-			if err != sql.ErrNoRows {
-				log.Printf("GetByIDForUpdate in status update error: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
-				return
-			}
-			// If no existing row, create a minimal one.
-			order = &store.Order{
-				ListingID: id,
-			}
-		}
-
-		// Once an order is finalized (SUCCESS or CANCELED), do not allow
-		// switching to a different terminal state.
-		if order.Status == store.OrderStatusSuccess || order.Status == store.OrderStatusCanceled {
-			if order.Status != newStatus {
-				c.JSON(http.StatusConflict, gin.H{"error": "order already finalized"})
+			if err == errOrderFinalized {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 				return
 			}
-			// Same status: treat as idempotent and continue.
-		}
-
-		order.Status = newStatus
-		if newStatus == store.OrderStatusSuccess && req.Buyer != "" {
-			order.Buyer = req.Buyer
-		}
-
-		if err := orderStore.UpsertTx(ctx, tx, order); err != nil {
-			log.Printf("Update order status error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db upsert failed"})
-			return
-		}
-
-		// 根据状态更新 nft_assets 视图：
-		// - CANCELED：恢复卖家的素材（deleted=0）
-		// - SUCCESS：把 owner 改成买家地址，并确保 deleted=0
-		if order.NFTAddress != "" && order.TokenID > 0 {
-			switch newStatus {
-			case store.OrderStatusCanceled:
-				if err := assetStore.RestoreByNFTTx(ctx, tx, order.NFTAddress, order.TokenID); err != nil {
-					log.Printf("RestoreByNFTTx error (nft_address=%s, token_id=%d): %v", order.NFTAddress, order.TokenID, err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "db asset update failed"})
-					return
-				}
-			case store.OrderStatusSuccess:
-				if order.Buyer != "" {
-					if err := assetStore.UpdateOwnerByNFTTx(ctx, tx, order.NFTAddress, order.TokenID, order.Buyer); err != nil {
-						log.Printf("UpdateOwnerByNFTTx error (nft_address=%s, token_id=%d, buyer=%s): %v", order.NFTAddress, order.TokenID, order.Buyer, err)
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "db asset update failed"})
-						return
-					}
-				}
-			}
-		}
-
-		if err := tx.Commit(); err != nil {
-			log.Printf("commit tx for order status error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db commit failed"})
-			return
-		}
-		committed = true
-
-		orderOut, err := orderStore.GetByID(ctx, id)
-		if err != nil {
-			log.Printf("GetByID after status update error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			log.Printf("update order status error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 			return
 		}
 
@@ -949,8 +1639,6 @@ func main() {
 			Owner:   owner,
 			CID:     uploadRes.CID,
 			URL:     uploadRes.URL,
-			TokenID: 0,
-			Amount:  0,
 			Deleted: 0,
 		}
 
@@ -965,7 +1653,11 @@ func main() {
 		c.JSON(http.StatusOK, asset)
 	})
 
-	// Update minted NFT info (token_id, nft_address, amount) after on-chain mint.
+	// Update minted NFT info (chain_id, token_id, nft_address, amount) after on-chain mint.
+	// The token is taken from the mint transaction's receipt: the ERC721
+	// Transfer / ERC1155 TransferSingle from the zero address to the asset
+	// owner. token_id / nft_address / amount in the body are optional and
+	// only used to pick and check that log.
 	api.POST("/assets/:id/mint-info", func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
@@ -975,32 +1667,88 @@ func main() {
 		}
 
 		var req struct {
-			TokenID    int64  `json:"token_id"`
-			NFTAddress string `json:"nft_address"`
-			Amount     int64  `json:"amount"`
+			ChainID    int64         `json:"chain_id"` // optional when a single chain is configured
+			TxHash     string        `json:"tx_hash"`  // mint transaction
+			TokenID    uint256String `json:"token_id"`
+			NFTAddress string        `json:"nft_address"`
+			Amount     uint256String `json:"amount"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json body"})
 			return
 		}
-		if req.TokenID <= 0 || req.NFTAddress == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "token_id and nft_address are required"})
+		txHash := common.FromHex(req.TxHash)
+		if len(txHash) != common.HashLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tx_hash of the mint transaction is required"})
 			return
 		}
-		if req.Amount <= 0 {
-			req.Amount = 1
+		rt, err := chains.lookup(req.ChainID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		nfts := rt.cfg.nftAddresses()
+		if req.NFTAddress != "" {
+			if !common.IsHexAddress(req.NFTAddress) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid nft_address"})
+				return
+			}
+			addr := common.HexToAddress(req.NFTAddress)
+			if len(nfts) > 0 && !chain.ContainsAddress(nfts, addr) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown nft_address"})
+				return
+			}
+			nfts = []common.Address{addr}
+		}
+		if len(nfts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nft_address is required"})
+			return
 		}
 
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
 
-		if err := assetStore.UpdateMintInfo(ctx, id, req.TokenID, req.NFTAddress, req.Amount); err != nil {
+		asset, err := assetStore.GetByID(ctx, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			} else {
+				log.Printf("GetByID in mint-info error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			}
+			return
+		}
+		if !common.IsHexAddress(asset.Owner) {
+			c.JSON(http.StatusConflict, gin.H{"error": "asset owner is not an address"})
+			return
+		}
+
+		minted, err := rt.mints.Minted(ctx, common.BytesToHash(txHash), common.HexToAddress(asset.Owner), nfts)
+		if err != nil {
+			switch {
+			case errors.Is(err, chain.ErrClaimMismatch):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, chain.ErrClaimUnverified):
+				c.JSON(http.StatusTooEarly, gin.H{"error": err.Error()})
+			default:
+				log.Printf("verify mint error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			}
+			return
+		}
+		token, err := pickMintedToken(minted, req.TokenID.Big(), req.Amount.Big())
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := assetStore.UpdateMintInfo(ctx, id, rt.id, token.TokenID.String(), token.NFTAddress.Hex(), token.Amount.String()); err != nil {
 			log.Printf("UpdateMintInfo error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
 			return
 		}
 
-		asset, err := assetStore.GetByID(ctx, id)
+		asset, err = assetStore.GetByID(ctx, id)
 		if err != nil {
 			log.Printf("GetByID after UpdateMintInfo error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
@@ -1010,7 +1758,7 @@ func main() {
 		c.JSON(http.StatusOK, asset)
 	})
 
-	// Get asset by on-chain NFT (nft_address + token_id).
+	// Get asset by on-chain NFT (chain_id + nft_address + token_id).
 	api.GET("/assets/by-nft", func(c *gin.Context) {
 		nftAddr := c.Query("nft_address")
 		tokenIDStr := c.Query("token_id")
@@ -1018,16 +1766,21 @@ func main() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "nft_address and token_id are required"})
 			return
 		}
-		tokenID, err := strconv.ParseInt(tokenIDStr, 10, 64)
+		tokenID, err := parseUint256(tokenIDStr)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token_id"})
 			return
 		}
+		rt, err := chains.param(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 		defer cancel()
 
-		asset, err := assetStore.GetByNFT(ctx, nftAddr, tokenID)
+		asset, err := assetStore.GetByNFT(ctx, rt.id, nftAddr, tokenID)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
@@ -1072,11 +1825,16 @@ func main() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "owner query param is required"})
 			return
 		}
+		chainID, err := chains.filter(c.Query("chain_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 		defer cancel()
 
-		assets, err := assetStore.ListByOwner(ctx, owner, 50)
+		assets, err := assetStore.ListByOwner(ctx, chainID, owner, 50)
 		if err != nil {
 			log.Printf("ListByOwner error: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
@@ -1085,6 +1843,85 @@ func main() {
 		c.JSON(http.StatusOK, assets)
 	})
 
+	// Operator endpoints, guarded by server.admin-token / ADMIN_TOKEN. They are
+	// not registered at all when no token is set.
+	if cfg.AdminToken == "" {
+		log.Printf("admin token not set, /api/v1/admin endpoints are disabled")
+	} else {
+		admin := api.Group("/admin", adminAuth(cfg.AdminToken))
+
+		// Contract logs the scanners failed to handle (dead letter queue), newest
+		// first. Optional chain_id / status filters, limit (default 50) and offset.
+		admin.GET("/failed-events", func(c *gin.Context) {
+			chainID, err := chains.filter(c.Query("chain_id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			status := store.FailedEventStatus(strings.ToUpper(c.Query("status")))
+			switch status {
+			case "", store.FailedEventStatusPending, store.FailedEventStatusResolved, store.FailedEventStatusDead, store.FailedEventStatusDiscarded:
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+				return
+			}
+			limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+			if err != nil || limit <= 0 || limit > 500 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+				return
+			}
+			offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+			if err != nil || offset < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+			defer cancel()
+
+			events, err := failedEventStore.List(ctx, chainID, status, limit, offset)
+			if err != nil {
+				log.Printf("list failed events error: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+				return
+			}
+			if events == nil {
+				events = []*store.FailedEvent{}
+			}
+			c.JSON(http.StatusOK, events)
+		})
+
+		// Handle a PENDING or DEAD failed event again right away. 200 with the
+		// RESOLVED / DISCARDED event, 422 with the error when the handler failed again.
+		// Only the leader replays, so a replay never races the leader's own
+		// dead-letter retries and scanners.
+		admin.POST("/failed-events/:id/replay", leaderOnly(elector), func(c *gin.Context) {
+			id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 45*time.Second)
+			defer cancel()
+
+			ev, err := deadLetters.Replay(ctx, id)
+			switch {
+			case err == nil:
+				c.JSON(http.StatusOK, ev)
+			case err == sql.ErrNoRows:
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			case errors.Is(err, chain.ErrNotReplayable):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "event": ev})
+			case ev != nil:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "event": ev})
+			default:
+				log.Printf("replay failed event %d error: %v", id, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			}
+		})
+	}
+
 	// Swagger: serve a minimal Swagger UI page backed by a static JSON spec.
 	router.GET("/swagger/doc.json", func(c *gin.Context) {
 		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(swaggerJSON))
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/nft_market_go/internal/chain"
+)
+
+// pickMintedToken selects the token a mint-info callback refers to among the
+// tokens its transaction minted to the asset owner. tokenID and amount are
+// the optional values sent by the client (nil = not sent); when sent they
+// must match the chain.
+func pickMintedToken(minted []chain.MintedToken, tokenID, amount *big.Int) (*chain.MintedToken, error) {
+	var matches []chain.MintedToken
+	for _, m := range minted {
+		if tokenID != nil && m.TokenID.Cmp(tokenID) != 0 {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	switch {
+	case len(matches) == 0 && tokenID != nil:
+		return nil, fmt.Errorf("transaction did not mint token %s to the asset owner", tokenID)
+	case len(matches) == 0:
+		return nil, fmt.Errorf("transaction did not mint a token to the asset owner")
+	case len(matches) > 1:
+		return nil, fmt.Errorf("transaction minted %d tokens to the asset owner, token_id is required", len(matches))
+	}
+
+	token := &matches[0]
+	if amount != nil && amount.Sign() > 0 && token.Amount.Cmp(amount) != 0 {
+		return nil, fmt.Errorf("transaction minted amount %s, not %s", token.Amount, amount)
+	}
+	return token, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/nft_market_go/internal/chain"
+)
+
+func TestPickMintedToken(t *testing.T) {
+	nft := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	minted := func(ids ...int64) []chain.MintedToken {
+		var out []chain.MintedToken
+		for _, id := range ids {
+			out = append(out, chain.MintedToken{NFTAddress: nft, TokenID: big.NewInt(id), Amount: big.NewInt(id * 10)})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		minted  []chain.MintedToken
+		tokenID *big.Int
+		amount  *big.Int
+		want    int64 // token ID picked, -1 for an error
+	}{
+		{"single mint", minted(7), nil, nil, 7},
+		{"single mint with matching token", minted(7), big.NewInt(7), nil, 7},
+		{"single mint with other token", minted(7), big.NewInt(8), nil, -1},
+		{"nothing minted", nil, nil, nil, -1},
+		{"several mints need token_id", minted(7, 8), nil, nil, -1},
+		{"several mints with token_id", minted(7, 8), big.NewInt(8), nil, 8},
+		{"matching amount", minted(7), nil, big.NewInt(70), 7},
+		{"other amount", minted(7), nil, big.NewInt(1), -1},
+		{"zero amount is not sent", minted(7), nil, big.NewInt(0), 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickMintedToken(tt.minted, tt.tokenID, tt.amount)
+			if tt.want < 0 {
+				if err == nil {
+					t.Fatalf("pickMintedToken picked token %s, want an error", got.TokenID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pickMintedToken error: %v", err)
+			}
+			if got.TokenID.Int64() != tt.want {
+				t.Fatalf("pickMintedToken picked token %s, want %d", got.TokenID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"github.com/nft_market_go/internal/chain"
+	"github.com/nft_market_go/internal/lock"
+	"github.com/nft_market_go/internal/store"
+)
+
+const (
+	// callbackConfirmInterval is how often queued callbacks are re-verified.
+	callbackConfirmInterval = 15 * time.Second
+	// callbackTTL is how long a callback may stay unverifiable before it expires.
+	callbackTTL = 30 * time.Minute
+)
+
+var (
+	errOrderFinalized  = errors.New("order already finalized")
+	errOrderSuperseded = errors.New("listing has moved on since this event")
+)
+
+// createOrderRequest is the body of POST /orders.
+type createOrderRequest struct {
+	ChainID     int64         `json:"chain_id"`    // optional when a single chain is configured
+	Marketplace string        `json:"marketplace"` // optional when the chain has a single marketplace
+	ListingID   uint256String `json:"listing_id"`
+	Seller      string        `json:"seller"`
+	NFTAddress  string        `json:"nft_address"`
+	TokenID     uint256String `json:"token_id"`
+	Amount      uint256String `json:"amount"`
+	NFTName     string        `json:"nft_name"`
+	URL         string        `json:"url"`
+	Price       string        `json:"price"`   // decimal string in wei
+	TxHash      string        `json:"tx_hash"` // optional tx hash of list transaction
+}
+
+// claim returns what the request asserts about the chain.
+func (r *createOrderRequest) claim(marketplace string) (*chain.OrderClaim, error) {
+	price, ok := new(big.Int).SetString(r.Price, 10)
+	if !ok || price.Sign() < 0 {
+		return nil, errors.New("price must be a decimal string in wei")
+	}
+	if !common.IsHexAddress(r.Seller) || !common.IsHexAddress(r.NFTAddress) {
+		return nil, errors.New("seller and nft_address must be addresses")
+	}
+	if r.ListingID == "" || r.TokenID == "" || r.Amount == "" {
+		return nil, errors.New("listing_id, token_id and amount are required")
+	}
+	c := &chain.OrderClaim{
+		Event:       store.OrderEventListed,
+		Marketplace: common.HexToAddress(marketplace),
+		ListingID:   r.ListingID.Big(),
+		Seller:      common.HexToAddress(r.Seller),
+		NFTAddress:  common.HexToAddress(r.NFTAddress),
+		TokenID:     r.TokenID.Big(),
+		Amount:      r.Amount.Big(),
+		Price:       price,
+	}
+	return c, setClaimTxHash(c, r.TxHash)
+}
+
+// orderStatusRequest is the body of POST /orders/:listingId/status.
+type orderStatusRequest struct {
+	ChainID     int64  `json:"chain_id"`    // optional when a single chain is configured
+	Marketplace string `json:"marketplace"` // optional when the chain has a single marketplace
+	Status      string `json:"status"`
+	Buyer       string `json:"buyer"`
+	TxHash      string `json:"tx_hash"` // cancel / buy transaction, required
+}
+
+// newStatus returns the order status the request asks for.
+func (r *orderStatusRequest) newStatus() (store.OrderStatus, error) {
+	switch r.Status {
+	case string(store.OrderStatusCanceled):
+		// Only the receipt tells a cancel from a sale: both leave the
+		// listing inactive.
+		if r.TxHash == "" {
+			return "", errors.New("tx_hash is required")
+		}
+		return store.OrderStatusCanceled, nil
+	case string(store.OrderStatusSuccess):
+		if !common.IsHexAddress(r.Buyer) || r.TxHash == "" {
+			return "", errors.New("buyer and tx_hash are required when status is SUCCESS")
+		}
+		return store.OrderStatusSuccess, nil
+	default:
+		return "", errors.New("status must be CANCELED or SUCCESS")
+	}
+}
+
+// claim returns what the request asserts about the chain.
+func (r *orderStatusRequest) claim(marketplace, listingID string) (*chain.OrderClaim, error) {
+	id, ok := new(big.Int).SetString(listingID, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid listing id %q", listingID)
+	}
+	c := &chain.OrderClaim{
+		Event:       store.OrderEventCancelled,
+		Marketplace: common.HexToAddress(marketplace),
+		ListingID:   id,
+	}
+	if r.Status == string(store.OrderStatusSuccess) {
+		c.Event = store.OrderEventSold
+		c.Buyer = common.HexToAddress(r.Buyer)
+	}
+	return c, setClaimTxHash(c, r.TxHash)
+}
+
+func setClaimTxHash(c *chain.OrderClaim, txHash string) error {
+	if txHash == "" {
+		return nil
+	}
+	b := common.FromHex(txHash)
+	if len(b) != common.HashLength {
+		return errors.New("tx_hash must be a 32-byte hex string")
+	}
+	c.TxHash = common.BytesToHash(b)
+	return nil
+}
+
+// orderCallbacks applies frontend order callbacks once the chain confirms
+// them. Callbacks the chain cannot confirm yet are queued in order_callbacks
+// and re-verified in the background by runConfirmer.
+type orderCallbacks struct {
+	db        *sql.DB
+	chains    *chainRegistry
+	orders    *store.OrderStore
+	assets    *store.NftAssetStore
+	callbacks *store.CallbackStore
+	locker    *lock.RedisLocker
+}
+
+// verify checks claim against the chain. When the chain confirms it, verify
+// returns true and the handler goes on applying the callback. Otherwise it
+// writes the response itself: 409 when the chain contradicts the claim, 202
+// with the queued callback when it cannot be verified yet.
+func (s *orderCallbacks) verify(ctx context.Context, c *gin.Context, rt *chainRuntime, claim *chain.OrderClaim, body any) bool {
+	err := rt.verifier.Verify(ctx, claim)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, chain.ErrClaimMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, chain.ErrClaimUnverified):
+		s.track(ctx, rt, claim)
+		cb, err := s.queue(ctx, rt, claim, body, err)
+		if err != nil {
+			log.Printf("queue order callback error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return false
+		}
+		c.JSON(http.StatusAccepted, cb)
+	default:
+		log.Printf("verify order callback error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+	return false
+}
+
+// track hands the buy / cancel transaction of an unverified claim to the
+// chain's tx tracker. The tracker only locks the order once the transaction
+// is on chain, sent to the marketplace by the claimed account, and checks the
+// receipt against the claim like the verifier, so an untrue claim fails the
+// transaction instead of taking the listing off the market.
+// Failures are only logged: the queued callback is confirmed either way.
+func (s *orderCallbacks) track(ctx context.Context, rt *chainRuntime, claim *chain.OrderClaim) {
+	if claim.TxHash == (common.Hash{}) || claim.Event == store.OrderEventListed || rt.tracker == nil {
+		return
+	}
+	p := &store.PendingTx{
+		TxHash:      claim.TxHash.Hex(),
+		Marketplace: claim.Marketplace.Hex(),
+		ListingID:   claim.ListingID.String(),
+		Type:        claim.Event,
+	}
+	if claim.Event == store.OrderEventSold {
+		p.Actor = claim.Buyer.Hex()
+	}
+	if err := rt.tracker.Track(ctx, p); err != nil {
+		log.Printf("track %s error: %v", p.TxHash, err)
+	}
+}
+
+// queue stores a callback that could not be verified yet.
+func (s *orderCallbacks) queue(ctx context.Context, rt *chainRuntime, claim *chain.OrderClaim, body any, reason error) (*store.OrderCallback, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	cb := &store.OrderCallback{
+		ChainID:     rt.id,
+		Marketplace: claim.Marketplace.Hex(),
+		ListingID:   claim.ListingID.String(),
+		Type:        claim.Event,
+		Payload:     payload,
+		LastError:   reason.Error(),
+	}
+	if claim.TxHash != (common.Hash{}) {
+		cb.TxHash = claim.TxHash.Hex()
+	}
+	if err := s.callbacks.Insert(ctx, cb); err != nil {
+		return nil, err
+	}
+	return cb, nil
+}
+
+// createOrder writes the listing of req, verified as claim, and hides the
+// listed asset. The caller holds the listing lock. A replayed callback must
+// not reopen the listing: it returns errOrderFinalized when the order has
+// ended and errOrderSuperseded when anything happened to it after the
+// Listed event.
+func (s *orderCallbacks) createOrder(ctx context.Context, rt *chainRuntime, marketplace string, req *createOrderRequest, claim *chain.OrderClaim) (*store.Order, error) {
+	// 如果前端没有传 nft_name 或 url，尝试从 nft_assets 中按 nft_address + token_id 读取。
+	if (req.NFTName == "" || req.URL == "") && req.NFTAddress != "" && req.TokenID != "" {
+		if asset, err := s.assets.GetByNFT(ctx, rt.id, req.NFTAddress, string(req.TokenID)); err == nil {
+			if req.NFTName == "" {
+				req.NFTName = asset.Name
+			}
+			if req.URL == "" {
+				req.URL = asset.URL
+			}
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				log.Printf("rollback tx for create order error: %v", err)
+			}
+		}
+	}()
+
+	order := &store.Order{
+		ChainID:     rt.id,
+		Marketplace: marketplace,
+		ListingID:   string(req.ListingID),
+		Seller:      req.Seller,
+		Buyer:       "",
+		NFTName:     req.NFTName,
+		NFTAddress:  req.NFTAddress,
+		URL:         req.URL,
+		TokenID:     string(req.TokenID),
+		Amount:      string(req.Amount),
+		Price:       req.Price,
+		Status:      store.OrderStatusListed,
+		TxHash:      req.TxHash,
+		Deleted:     0,
+	}
+	// Keep the chain positions the scanner may already have recorded.
+	existing, err := s.orders.GetByIDForUpdateTx(ctx, tx, rt.id, marketplace, order.ListingID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("get order for update: %w", err)
+	}
+	if existing != nil {
+		switch {
+		case existing.Status == store.OrderStatusSuccess || existing.Status == store.OrderStatusCanceled:
+			return nil, errOrderFinalized
+		case existing.Status != store.OrderStatusInit && existing.Status != store.OrderStatusListed,
+			laterEventApplied(existing, claim):
+			return nil, errOrderSuperseded
+		}
+		order.KeepChainEvents(existing)
+	}
+
+	if err := s.orders.UpsertTx(ctx, tx, order); err != nil {
+		return nil, fmt.Errorf("upsert order: %w", err)
+	}
+	if err := appendCallbackEvent(ctx, s.orders, tx, order, store.OrderEventListed, req.Seller, req.TxHash, req); err != nil {
+		return nil, fmt.Errorf("append order event: %w", err)
+	}
+
+	// 上架后，这个 NFT 由订单管理，不再作为“可用素材”展示：
+	// 根据 chain_id + nft_address + token_id 做逻辑删除（deleted = 1），取消挂单时再恢复。
+	if err := s.assets.SoftDeleteByNFTTx(ctx, tx, rt.id, order.NFTAddress, order.TokenID); err != nil {
+		return nil, fmt.Errorf("soft delete asset (nft_address=%s, token_id=%s): %w", order.NFTAddress, order.TokenID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	committed = true
+
+	// Read back full record including order_id / timestamps.
+	return s.orders.GetByID(ctx, rt.id, marketplace, order.ListingID)
+}
+
+// laterEventApplied reports whether the scanner applied an event after the
+// Listed log of claim to o, comparing positions like the scanner does before
+// applying a log. Without the claim's position (no receipt was checked) the
+// Listed event recorded on o stands in for it.
+func laterEventApplied(o *store.Order, claim *chain.OrderClaim) bool {
+	if o.BlockNumber == 0 {
+		return false
+	}
+	block, index := claim.BlockNumber, claim.LogIndex
+	if block == 0 {
+		if o.Listed == nil {
+			return true
+		}
+		block, index = o.Listed.BlockNumber, o.Listed.LogIndex
+	}
+	if o.BlockNumber != block {
+		return o.BlockNumber > block
+	}
+	return o.LogIndex > index
+}
+
+// updateStatus moves a listing to CANCELED or SUCCESS and updates the asset
+// view accordingly. The caller holds the listing lock. It returns
+// errOrderFinalized when the order already ended the other way.
+func (s *orderCallbacks) updateStatus(ctx context.Context, rt *chainRuntime, marketplace, listingID string, req *orderStatusRequest) (*store.Order, error) {
+	newStatus, err := req.newStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				log.Printf("rollback tx for order status error: %v", err)
+			}
+		}
+	}()
+
+	order, err := s.orders.GetByIDForUpdateTx(ctx, tx, rt.id, marketplace, listingID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("get order for update: %w", err)
+		}
+		// If no existing row, create a minimal one.
+		order = &store.Order{
+			ChainID:     rt.id,
+			Marketplace: marketplace,
+			ListingID:   listingID,
+		}
+	}
+
+	// Once an order is finalized (SUCCESS or CANCELED), do not allow
+	// switching to a different terminal state.
+	if order.Status == store.OrderStatusSuccess || order.Status == store.OrderStatusCanceled {
+		if order.Status != newStatus {
+			return nil, errOrderFinalized
+		}
+		// Same status: treat as idempotent and continue.
+	}
+
+	order.Status = newStatus
+	if newStatus == store.OrderStatusSuccess {
+		order.Buyer = req.Buyer
+	}
+	if req.TxHash != "" {
+		order.TxHash = req.TxHash
+	}
+
+	if err := s.orders.UpsertTx(ctx, tx, order); err != nil {
+		return nil, fmt.Errorf("upsert order: %w", err)
+	}
+	eventType, actor := store.OrderEventCancelled, order.Seller
+	if newStatus == store.OrderStatusSuccess {
+		eventType, actor = store.OrderEventSold, order.Buyer
+	}
+	if err := appendCallbackEvent(ctx, s.orders, tx, order, eventType, actor, req.TxHash, req); err != nil {
+		return nil, fmt.Errorf("append order event: %w", err)
+	}
+
+	// 根据状态更新 nft_assets 视图：
+	// - CANCELED：恢复卖家的素材（deleted=0）
+	// - SUCCESS：把 owner 改成买家地址，并确保 deleted=0
+	if order.NFTAddress != "" && order.TokenID != "" {
+		switch newStatus {
+		case store.OrderStatusCanceled:
+			if err := s.assets.RestoreByNFTTx(ctx, tx, rt.id, order.NFTAddress, order.TokenID); err != nil {
+				return nil, fmt.Errorf("restore asset (nft_address=%s, token_id=%s): %w", order.NFTAddress, order.TokenID, err)
+			}
+		case store.OrderStatusSuccess:
+			if err := s.assets.UpdateOwnerByNFTTx(ctx, tx, rt.id, order.NFTAddress, order.TokenID, order.Buyer); err != nil {
+				return nil, fmt.Errorf("update asset owner (nft_address=%s, token_id=%s, buyer=%s): %w", order.NFTAddress, order.TokenID, order.Buyer, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	committed = true
+
+	return s.orders.GetByID(ctx, rt.id, marketplace, listingID)
+}
+
+// runConfirmer periodically re-verifies queued callbacks: confirmed ones are
+// applied, contradicted ones rejected, and ones still unverifiable after
+// callbackTTL expired.
+func (s *orderCallbacks) runConfirmer(ctx context.Context) {
+	ticker := time.NewTicker(callbackConfirmInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		listCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		pending, err := s.callbacks.ListPending(listCtx, 100)
+		cancel()
+		if err != nil {
+			log.Printf("list pending order callbacks error: %v", err)
+			continue
+		}
+		for _, cb := range pending {
+			s.confirm(ctx, cb)
+		}
+	}
+}
+
+// confirm verifies one queued callback and resolves it if possible.
+func (s *orderCallbacks) confirm(ctx context.Context, cb *store.OrderCallback) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rt, err := s.chains.lookup(cb.ChainID)
+	if err != nil {
+		s.resolve(ctx, cb, store.CallbackStatusRejected, err)
+		return
+	}
+
+	var apply func(ctx context.Context) error
+	var claim *chain.OrderClaim
+	switch cb.Type {
+	case store.OrderEventListed:
+		var req createOrderRequest
+		if err := json.Unmarshal(cb.Payload, &req); err != nil {
+			s.resolve(ctx, cb, store.CallbackStatusRejected, err)
+			return
+		}
+		claim, err = req.claim(cb.Marketplace)
+		apply = func(ctx context.Context) error {
+			_, err := s.createOrder(ctx, rt, cb.Marketplace, &req, claim)
+			return err
+		}
+	default:
+		var req orderStatusRequest
+		if err := json.Unmarshal(cb.Payload, &req); err != nil {
+			s.resolve(ctx, cb, store.CallbackStatusRejected, err)
+			return
+		}
+		claim, err = req.claim(cb.Marketplace, cb.ListingID)
+		apply = func(ctx context.Context) error {
+			_, err := s.updateStatus(ctx, rt, cb.Marketplace, cb.ListingID, &req)
+			return err
+		}
+	}
+	if err != nil {
+		s.resolve(ctx, cb, store.CallbackStatusRejected, err)
+		return
+	}
+
+	if err := rt.verifier.Verify(ctx, claim); err != nil {
+		switch {
+		case errors.Is(err, chain.ErrClaimMismatch):
+			s.resolve(ctx, cb, store.CallbackStatusRejected, err)
+		case time.Since(cb.CreatedAt) > callbackTTL:
+			s.resolve(ctx, cb, store.CallbackStatusExpired, err)
+		default:
+			if err := s.callbacks.Retry(ctx, cb.ID, err.Error()); err != nil {
+				log.Printf("update order callback %d error: %v", cb.ID, err)
+			}
+		}
+		return
+	}
+
+	orderLock, err := s.locker.Acquire(ctx, listingLockKey(rt.id, cb.Marketplace, cb.ListingID), 10*time.Second)
+	if err != nil {
+		// Busy listing or Redis hiccup: try again on the next round.
+		return
+	}
+	defer func() {
+		if err := orderLock.Release(context.Background()); err != nil {
+			log.Printf("release order lock error: %v", err)
+		}
+	}()
+
+	if err := apply(orderLock.KeepAlive(ctx)); err != nil {
+		if errors.Is(err, errOrderFinalized) || errors.Is(err, errOrderSuperseded) {
+			s.resolve(ctx, cb, store.CallbackStatusRejected, err)
+			return
+		}
+		log.Printf("apply order callback %d error: %v", cb.ID, err)
+		return
+	}
+	s.resolve(ctx, cb, store.CallbackStatusConfirmed, nil)
+}
+
+func (s *orderCallbacks) resolve(ctx context.Context, cb *store.OrderCallback, status store.CallbackStatus, reason error) {
+	msg := ""
+	if reason != nil {
+		msg = reason.Error()
+	}
+	if err := s.callbacks.Resolve(ctx, cb.ID, status, msg); err != nil {
+		log.Printf("update order callback %d error: %v", cb.ID, err)
+		return
+	}
+	log.Printf("order callback %d (%s listing %s on %s) %s %s", cb.ID, cb.Type, cb.ListingID, cb.Marketplace, status, msg)
+}
+
+// appendCallbackEvent records a frontend callback in order_events, in the
+// transaction that applied it to order. body is stored as the payload.
+func appendCallbackEvent(ctx context.Context, orders *store.OrderStore, tx *sql.Tx, order *store.Order, eventType, actor, txHash string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return orders.AppendEventTx(ctx, tx, &store.OrderEvent{
+		ChainID:     order.ChainID,
+		Marketplace: order.Marketplace,
+		ListingID:   order.ListingID,
+		Type:        eventType,
+		Source:      store.OrderEventSourceCallback,
+		Status:      order.Status,
+		Actor:       actor,
+		TxHash:      txHash,
+		Payload:     payload,
+	})
+}
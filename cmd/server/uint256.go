@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// uint256String is a uint256 (listing ID, token ID, amount) in a request
+// body, kept as a canonical decimal string. Clients should send it as a
+// decimal string, since JSON numbers above 2^53 lose precision in
+// JavaScript; plain JSON integers from older clients are still accepted.
+// Responses always use strings. An empty string means "not set".
+type uint256String string
+
+// UnmarshalJSON accepts a decimal string or a JSON integer.
+func (u *uint256String) UnmarshalJSON(b []byte) error {
+	raw := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return err
+		}
+		if raw == "" {
+			*u = ""
+			return nil
+		}
+	}
+	v, err := parseUint256(raw)
+	if err != nil {
+		return err
+	}
+	*u = uint256String(v)
+	return nil
+}
+
+// Big returns u as a big.Int, nil when u is empty.
+func (u uint256String) Big() *big.Int {
+	v, ok := new(big.Int).SetString(string(u), 10)
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// parseUint256 validates a decimal uint256 (e.g. a path or query parameter)
+// and returns it without leading zeros, so it can be used in lock keys.
+func parseUint256(s string) (string, error) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return "", fmt.Errorf("%q is not a decimal uint256", s)
+	}
+	return v.String(), nil
+}
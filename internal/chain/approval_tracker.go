@@ -0,0 +1,255 @@
+package chain
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/store"
+)
+
+// approvalTracker indexes ApprovalForAll / Approval events of an NFT contract
+// and keeps the fillability of open listings in sync: when a seller revokes
+// a marketplace's approval their LISTED orders on that marketplace become
+// UNFILLABLE, and when it is granted again they go back to LISTED.
+//
+// Approvals granted before indexing started are unknown. To avoid flagging
+// such listings by mistake, a revoked single-token approval only makes an
+// order UNFILLABLE when the seller's operator approval is known to be revoked.
+type approvalTracker struct {
+	db           *sql.DB
+	chainID      int64
+	approvals    *store.ApprovalStore
+	orders       *store.OrderStore
+	marketplaces []common.Address
+	logger       *log.Logger
+	name         string
+}
+
+// applyOperatorApproval handles ApprovalForAll(owner, operator, approved).
+func (t *approvalTracker) applyOperatorApproval(ctx context.Context, lg types.Log, owner, operator common.Address, approved bool) error {
+	nftAddress := lg.Address.Hex()
+
+	return t.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		current, err := t.approvals.GetOperatorForUpdateTx(ctx, tx, t.chainID, nftAddress, owner.Hex(), operator.Hex())
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if current != nil && !isAfter(lg.BlockNumber, lg.Index, current.BlockNumber, current.LogIndex) {
+			return nil
+		}
+
+		if err := t.approvals.UpsertOperatorTx(ctx, tx, &store.OperatorApproval{
+			ChainID:     t.chainID,
+			NFTAddress:  nftAddress,
+			Owner:       owner.Hex(),
+			Operator:    operator.Hex(),
+			Approved:    approved,
+			BlockNumber: lg.BlockNumber,
+			LogIndex:    lg.Index,
+		}); err != nil {
+			return err
+		}
+
+		if !t.isMarketplace(operator) {
+			return nil
+		}
+
+		orders, err := t.orders.ListOpenBySellerForUpdateTx(ctx, tx, t.chainID, operator.Hex(), owner.Hex(), nftAddress)
+		if err != nil {
+			return err
+		}
+		for _, o := range orders {
+			fillable := approved
+			if !fillable {
+				// An ERC721 listing stays fillable through a single-token approval.
+				if fillable, err = t.tokenApproved(ctx, tx, operator, nftAddress, o.TokenID); err != nil {
+					return err
+				}
+			}
+			if err := t.setFillable(ctx, tx, lg, o, fillable); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// applyTokenApproval handles ERC721 Approval(owner, approved, tokenId).
+func (t *approvalTracker) applyTokenApproval(ctx context.Context, lg types.Log, owner, approved common.Address, tokenID string) error {
+	nftAddress := lg.Address.Hex()
+
+	return t.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		changed, prev, err := t.saveTokenApprovalTx(ctx, tx, lg, owner, approved, tokenID)
+		if err != nil || !changed {
+			return err
+		}
+
+		for _, m := range t.marketplaces {
+			var fillable bool
+			switch {
+			case approved == m:
+				fillable = true
+			case prev != nil && prev.Approved != m.Hex():
+				// This marketplace was not approved for the token before either.
+				continue
+			default:
+				revoked, err := t.operatorRevoked(ctx, tx, m, nftAddress, owner.Hex())
+				if err != nil {
+					return err
+				}
+				if !revoked {
+					continue
+				}
+			}
+
+			orders, err := t.orders.ListOpenBySellerForUpdateTx(ctx, tx, t.chainID, m.Hex(), owner.Hex(), nftAddress)
+			if err != nil {
+				return err
+			}
+			for _, o := range orders {
+				if o.TokenID != tokenID {
+					continue
+				}
+				if err := t.setFillable(ctx, tx, lg, o, fillable); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// clearTokenApprovalTx resets the single-token approval of an ERC721 token
+// after a Transfer, which clears it on-chain even when no Approval event is
+// emitted.
+func (t *approvalTracker) clearTokenApprovalTx(ctx context.Context, tx *sql.Tx, lg types.Log, newOwner common.Address, tokenID string) error {
+	_, _, err := t.saveTokenApprovalTx(ctx, tx, lg, newOwner, common.Address{}, tokenID)
+	return err
+}
+
+// saveTokenApprovalTx stores a single-token approval if lg is newer than the
+// stored one and returns the previous row.
+func (t *approvalTracker) saveTokenApprovalTx(ctx context.Context, tx *sql.Tx, lg types.Log, owner, approved common.Address, tokenID string) (bool, *store.TokenApproval, error) {
+	nftAddress := lg.Address.Hex()
+
+	prev, err := t.approvals.GetTokenForUpdateTx(ctx, tx, t.chainID, nftAddress, tokenID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, nil, err
+	}
+	if prev != nil && !isAfter(lg.BlockNumber, lg.Index, prev.BlockNumber, prev.LogIndex) {
+		return false, prev, nil
+	}
+
+	if err := t.approvals.UpsertTokenTx(ctx, tx, &store.TokenApproval{
+		ChainID:     t.chainID,
+		NFTAddress:  nftAddress,
+		TokenID:     tokenID,
+		Owner:       owner.Hex(),
+		Approved:    approved.Hex(),
+		BlockNumber: lg.BlockNumber,
+		LogIndex:    lg.Index,
+	}); err != nil {
+		return false, prev, err
+	}
+	return true, prev, nil
+}
+
+// tokenApproved reports whether marketplace holds a single-token approval.
+func (t *approvalTracker) tokenApproved(ctx context.Context, tx *sql.Tx, marketplace common.Address, nftAddress string, tokenID string) (bool, error) {
+	a, err := t.approvals.GetTokenForUpdateTx(ctx, tx, t.chainID, nftAddress, tokenID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return a.Approved == marketplace.Hex(), nil
+}
+
+// operatorRevoked reports whether the owner's operator approval for
+// marketplace is known to be revoked (false when it was never indexed).
+func (t *approvalTracker) operatorRevoked(ctx context.Context, tx *sql.Tx, marketplace common.Address, nftAddress, owner string) (bool, error) {
+	a, err := t.approvals.GetOperatorForUpdateTx(ctx, tx, t.chainID, nftAddress, owner, marketplace.Hex())
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !a.Approved, nil
+}
+
+func (t *approvalTracker) isMarketplace(addr common.Address) bool {
+	for _, m := range t.marketplaces {
+		if m == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// setFillable moves o between LISTED and UNFILLABLE and records the change,
+// caused by the approval log lg, in order_events.
+func (t *approvalTracker) setFillable(ctx context.Context, tx *sql.Tx, lg types.Log, o *store.Order, fillable bool) error {
+	status, eventType := store.OrderStatusUnfillable, store.OrderEventApprovalRevoked
+	if fillable {
+		status, eventType = store.OrderStatusListed, store.OrderEventApprovalRestored
+	}
+	if o.Status == status {
+		return nil
+	}
+
+	t.logger.Printf("%s: listing %s on %s of %s is now %s", t.name, o.ListingID, o.Marketplace, o.Seller, status)
+	if err := t.orders.UpdateStatusTx(ctx, tx, t.chainID, o.Marketplace, o.ListingID, status); err != nil {
+		return err
+	}
+	return t.orders.AppendEventTx(ctx, tx, &store.OrderEvent{
+		ChainID:     t.chainID,
+		Marketplace: o.Marketplace,
+		ListingID:   o.ListingID,
+		Type:        eventType,
+		Source:      store.OrderEventSourceChain,
+		Status:      status,
+		Actor:       o.Seller,
+		TxHash:      lg.TxHash.Hex(),
+		Chain: &store.ChainEvent{
+			TxHash:      lg.TxHash.Hex(),
+			BlockNumber: lg.BlockNumber,
+			LogIndex:    lg.Index,
+			BlockHash:   lg.BlockHash.Hex(),
+		},
+	})
+}
+
+func (t *approvalTracker) inTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				t.logger.Printf("%s: rollback tx error: %v", t.name, err)
+			}
+		}
+	}()
+
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
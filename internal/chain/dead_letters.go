@@ -0,0 +1,261 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/store"
+)
+
+// ErrNotReplayable is returned by DeadLetterQueue.Replay for events that
+// were already resolved or discarded.
+var ErrNotReplayable = errors.New("failed event is not replayable")
+
+// ErrNoEventHandler is returned by DeadLetterQueue.Replay when no scanner of
+// this process handles the event's contract.
+var ErrNoEventHandler = errors.New("no scanner handles this contract")
+
+// DeadLetterQueue keeps contract logs whose handler failed (DB timeout,
+// decode error, ...) in the failed_events table, so they are not lost once
+// the scanner moves past their block. Run retries them with exponential
+// backoff; after maxAttempts failed retries an event is DEAD and only
+// replayed on request (Replay).
+//
+// Scanners register their handler with SetDeadLetterQueue. Before a retry the
+// log's block hash is compared with the canonical chain, and logs whose block
+// was reorged away are DISCARDED instead of being applied.
+type DeadLetterQueue struct {
+	events       *store.FailedEventStore
+	logger       *log.Logger
+	pollInterval time.Duration
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	maxAttempts  int
+
+	mu       sync.Mutex
+	handlers map[deadLetterKey]deadLetterHandler
+}
+
+type deadLetterKey struct {
+	chainID  int64
+	contract common.Address
+}
+
+type deadLetterHandler struct {
+	name   string
+	client ChainClient
+	handle func(context.Context, types.Log) error
+}
+
+// NewDeadLetterQueue creates a queue shared by the scanners of every chain.
+func NewDeadLetterQueue(events *store.FailedEventStore, logger *log.Logger) *DeadLetterQueue {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &DeadLetterQueue{
+		events:       events,
+		logger:       logger,
+		pollInterval: 15 * time.Second,
+		baseDelay:    30 * time.Second,
+		maxDelay:     time.Hour,
+		maxAttempts:  10,
+		handlers:     make(map[deadLetterKey]deadLetterHandler),
+	}
+}
+
+// register makes the queue retry the failed logs of contract on chainID with
+// handle, and returns the callback the scanner's logBatcher reports handler
+// errors to.
+func (q *DeadLetterQueue) register(chainID int64, contract common.Address, name string, client ChainClient, handle func(context.Context, types.Log) error) func(context.Context, types.Log, error) {
+	q.mu.Lock()
+	q.handlers[deadLetterKey{chainID, contract}] = deadLetterHandler{name: name, client: client, handle: handle}
+	q.mu.Unlock()
+
+	return func(ctx context.Context, lg types.Log, err error) {
+		q.record(ctx, chainID, name, lg, err)
+	}
+}
+
+// record stores lg after its handler failed with handleErr. Removed logs are
+// not stored (their rollback is redone by the next reorg check), nor are
+// logs that failed because the scan was canceled or this replica's leader
+// term was superseded (the new leader scans them itself).
+func (q *DeadLetterQueue) record(ctx context.Context, chainID int64, name string, lg types.Log, handleErr error) {
+	if lg.Removed || ctx.Err() != nil || errors.Is(handleErr, store.ErrFenced) {
+		return
+	}
+	topics := make([]string, 0, len(lg.Topics))
+	for _, t := range lg.Topics {
+		topics = append(topics, t.Hex())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := q.events.Record(ctx, &store.FailedEvent{
+		ChainID:     chainID,
+		Contract:    lg.Address.Hex(),
+		Handler:     name,
+		BlockNumber: lg.BlockNumber,
+		BlockHash:   lg.BlockHash.Hex(),
+		TxHash:      lg.TxHash.Hex(),
+		TxIndex:     lg.TxIndex,
+		LogIndex:    lg.Index,
+		Topics:      topics,
+		Data:        hexutil.Encode(lg.Data),
+		LastError:   handleErr.Error(),
+	}, q.baseDelay)
+	if err != nil {
+		q.logger.Printf("dead letter queue: record %s log %s/%d error: %v", name, lg.TxHash.Hex(), lg.Index, err)
+	}
+}
+
+// Run retries due events until ctx is canceled.
+func (q *DeadLetterQueue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := q.retryDue(ctx); err != nil {
+			q.logger.Printf("dead letter queue: retry error: %v", err)
+		}
+	}
+}
+
+func (q *DeadLetterQueue) retryDue(ctx context.Context) error {
+	listCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	due, err := q.events.ListDue(listCtx, 100)
+	cancel()
+	if err != nil {
+		return err
+	}
+	for _, ev := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		q.retry(ctx, ev)
+	}
+	return nil
+}
+
+// Replay handles a PENDING or DEAD event right away, regardless of its next
+// retry time, and returns it with its new status. A handler error is
+// returned as well; the event then keeps its status and is retried as usual.
+func (q *DeadLetterQueue) Replay(ctx context.Context, id int64) (*store.FailedEvent, error) {
+	ev, err := q.events.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ev.Status != store.FailedEventStatusPending && ev.Status != store.FailedEventStatusDead {
+		return ev, ErrNotReplayable
+	}
+	retryErr := q.retry(ctx, ev)
+	if cur, err := q.events.GetByID(ctx, id); err == nil {
+		ev = cur
+	}
+	return ev, retryErr
+}
+
+// retry handles ev once more and updates its status; the handler's error is
+// returned.
+func (q *DeadLetterQueue) retry(ctx context.Context, ev *store.FailedEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	q.mu.Lock()
+	h, ok := q.handlers[deadLetterKey{ev.ChainID, common.HexToAddress(ev.Contract)}]
+	q.mu.Unlock()
+	if !ok {
+		// The contract may be handled by another process with a different
+		// configuration; look again much later.
+		if err := q.events.Retry(ctx, ev.ID, ErrNoEventHandler.Error(), q.maxDelay); err != nil {
+			q.logger.Printf("dead letter queue: update failed event %d error: %v", ev.ID, err)
+		}
+		return ErrNoEventHandler
+	}
+
+	err := q.apply(ctx, h, ev)
+	switch {
+	case err == nil:
+		q.logger.Printf("dead letter queue: %s log %s/%d of block %d handled after %d attempts", ev.Handler, ev.TxHash, ev.LogIndex, ev.BlockNumber, ev.Attempts+1)
+		q.resolve(ctx, ev, store.FailedEventStatusResolved, ev.LastError)
+		return nil
+	case errors.Is(err, errBlockReorged):
+		q.logger.Printf("dead letter queue: %s log %s/%d discarded: %v", ev.Handler, ev.TxHash, ev.LogIndex, err)
+		q.resolve(ctx, ev, store.FailedEventStatusDiscarded, err.Error())
+		return nil
+	case ev.Status == store.FailedEventStatusPending && ev.Attempts+1 >= q.maxAttempts:
+		q.logger.Printf("dead letter queue: %s log %s/%d gave up after %d attempts: %v", ev.Handler, ev.TxHash, ev.LogIndex, ev.Attempts+1, err)
+		q.resolve(ctx, ev, store.FailedEventStatusDead, err.Error())
+		return err
+	}
+
+	if uerr := q.events.Retry(ctx, ev.ID, err.Error(), q.backoff(ev.Attempts+1)); uerr != nil {
+		q.logger.Printf("dead letter queue: update failed event %d error: %v", ev.ID, uerr)
+	}
+	return err
+}
+
+func (q *DeadLetterQueue) resolve(ctx context.Context, ev *store.FailedEvent, status store.FailedEventStatus, reason string) {
+	if err := q.events.Resolve(ctx, ev.ID, status, reason); err != nil {
+		q.logger.Printf("dead letter queue: update failed event %d error: %v", ev.ID, err)
+	}
+}
+
+var errBlockReorged = errors.New("block is no longer canonical")
+
+// apply checks that ev's block is still canonical and hands the stored log to
+// the scanner's handler.
+func (q *DeadLetterQueue) apply(ctx context.Context, h deadLetterHandler, ev *store.FailedEvent) error {
+	hash, err := canonicalBlockHash(ctx, h.client, ev.BlockNumber)
+	if err != nil {
+		return fmt.Errorf("get hash of block %d: %w", ev.BlockNumber, err)
+	}
+	if hash != common.HexToHash(ev.BlockHash) {
+		return fmt.Errorf("%w: block %d is now %s, not %s", errBlockReorged, ev.BlockNumber, hash.Hex(), ev.BlockHash)
+	}
+
+	data, err := hexutil.Decode(ev.Data)
+	if err != nil {
+		return fmt.Errorf("decode data: %w", err)
+	}
+	lg := types.Log{
+		Address:     common.HexToAddress(ev.Contract),
+		Data:        data,
+		BlockNumber: ev.BlockNumber,
+		TxHash:      common.HexToHash(ev.TxHash),
+		TxIndex:     ev.TxIndex,
+		BlockHash:   common.HexToHash(ev.BlockHash),
+		Index:       ev.LogIndex,
+	}
+	for _, t := range ev.Topics {
+		lg.Topics = append(lg.Topics, common.HexToHash(t))
+	}
+	return h.handle(ctx, lg)
+}
+
+// backoff returns the delay before retry number attempts+1: baseDelay doubled
+// per attempt, at most maxDelay.
+func (q *DeadLetterQueue) backoff(attempts int) time.Duration {
+	d := q.baseDelay
+	for i := 0; i < attempts && d < q.maxDelay; i++ {
+		d *= 2
+	}
+	if d > q.maxDelay {
+		d = q.maxDelay
+	}
+	return d
+}
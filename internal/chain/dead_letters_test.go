@@ -0,0 +1,29 @@
+package chain
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterBackoff(t *testing.T) {
+	q := NewDeadLetterQueue(nil, log.New(io.Discard, "", 0))
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{10, time.Hour},
+		{1000, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := q.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/contracts"
+	"github.com/nft_market_go/internal/store"
+)
+
+// ERC1155Scanner indexes TransferSingle / TransferBatch events of the
+// Project1155 contract into the nft_token_balances table, keeping one balance
+// per (token, holder). Mints (from = 0x0) only credit the receiver and burns
+// (to = 0x0) only debit the sender.
+//
+// URI events are recorded in nft_token_uris; the MetadataRefresher then
+// copies them onto the matching nft_assets rows and fetches the metadata.
+//
+// With SetApprovalTracking it also indexes ApprovalForAll events and flags
+// listings the marketplace can no longer fill.
+type ERC1155Scanner struct {
+	db        *sql.DB
+	chainID   int64
+	abi       abi.ABI
+	events    *contracts.Project1155Filterer
+	balances  *store.TokenBalanceStore
+	uris      *store.TokenURIStore
+	refresher *MetadataRefresher
+	approvals *approvalTracker
+	indexer   *logIndexer
+	logger    *log.Logger
+}
+
+// balanceKey identifies one balance row touched by a transfer log.
+type balanceKey struct {
+	tokenID string // decimal
+	holder  common.Address
+}
+
+// decimalLess orders canonical decimal strings (no sign, no leading zeros)
+// numerically.
+func decimalLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// NewERC1155Scanner creates a scanner using the Project1155 ABI from the contracts package.
+// checkpoints may be nil, in which case the scanner always starts from the
+// configured start block or the current head.
+func NewERC1155Scanner(client ChainClient, db *sql.DB, chainID int64, contractAddr common.Address, balances *store.TokenBalanceStore, uris *store.TokenURIStore, checkpoints *store.CheckpointStore, logger *log.Logger) (*ERC1155Scanner, error) {
+	parsedABI, err := contracts.Project1155ABI()
+	if err != nil {
+		return nil, err
+	}
+	events, err := contracts.NewProject1155Filterer(contractAddr, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	s := &ERC1155Scanner{
+		db:       db,
+		chainID:  chainID,
+		abi:      parsedABI,
+		events:   events,
+		balances: balances,
+		uris:     uris,
+		logger:   logger,
+	}
+	s.indexer = newLogIndexer(client, chainID, contractAddr, checkpoints, logger, "erc1155 scanner")
+	s.indexer.topics = [][]common.Hash{{
+		parsedABI.Events["TransferSingle"].ID,
+		parsedABI.Events["TransferBatch"].ID,
+		parsedABI.Events["URI"].ID,
+	}}
+	s.indexer.handle = s.handleLog
+	return s, nil
+}
+
+// SetConfirmations makes the scanner apply only events in blocks at least n
+// blocks below the head.
+func (s *ERC1155Scanner) SetConfirmations(n uint64) {
+	s.indexer.confirmations = n
+}
+
+// SetStartBlock makes a scanner without a saved checkpoint start at block
+// (typically the contract's deployment block) instead of the current head,
+// so transfers made before the server first ran are indexed too.
+func (s *ERC1155Scanner) SetStartBlock(block uint64) {
+	s.indexer.startAt = block
+}
+
+// SetMaxBatchBlocks overrides the maximum number of blocks per FilterLogs query.
+func (s *ERC1155Scanner) SetMaxBatchBlocks(n uint64) {
+	if n > 0 {
+		s.indexer.batcher.maxBatchBlocks = n
+	}
+}
+
+// SetMetadataRefresher makes the scanner trigger r after indexing a URI event.
+func (s *ERC1155Scanner) SetMetadataRefresher(r *MetadataRefresher) {
+	s.refresher = r
+}
+
+// SetApprovalTracking enables indexing of ApprovalForAll events. Open
+// listings on each of marketplaces are marked UNFILLABLE when the seller
+// revokes that marketplace's approval and LISTED again when it is re-granted.
+// Call before Run.
+func (s *ERC1155Scanner) SetApprovalTracking(approvals *store.ApprovalStore, orders *store.OrderStore, marketplaces []common.Address) {
+	s.approvals = &approvalTracker{
+		db:           s.db,
+		chainID:      s.chainID,
+		approvals:    approvals,
+		orders:       orders,
+		marketplaces: marketplaces,
+		logger:       s.logger,
+		name:         "erc1155 scanner",
+	}
+	s.indexer.topics[0] = append(s.indexer.topics[0], s.abi.Events["ApprovalForAll"].ID)
+}
+
+// SetDeadLetterQueue makes the scanner store logs it fails to handle in q,
+// which retries them in the background. Call before Run.
+func (s *ERC1155Scanner) SetDeadLetterQueue(q *DeadLetterQueue) {
+	s.indexer.setDeadLetterQueue(q)
+}
+
+// EnableSubscription makes Run follow new heads and logs over a websocket
+// subscription, falling back to polling while it is down.
+func (s *ERC1155Scanner) EnableSubscription() {
+	s.indexer.subscribe = true
+}
+
+// Run starts the scanning loop. It should be run in its own goroutine.
+func (s *ERC1155Scanner) Run(ctx context.Context) {
+	s.indexer.run(ctx)
+}
+
+// Backfill replays the contract's events in the historical range [from, to]
+// (to = 0 means the newest confirmed block), resuming an interrupted run
+// with the same from block. Transfers are counted once per log, whatever
+// order they are applied in (see applyTransfers). progress may be nil.
+func (s *ERC1155Scanner) Backfill(ctx context.Context, from, to uint64, progress func(BackfillProgress)) error {
+	return s.indexer.backfill(ctx, from, to, progress)
+}
+
+func (s *ERC1155Scanner) handleLog(ctx context.Context, lg types.Log) error {
+	if len(lg.Topics) == 0 {
+		return nil
+	}
+
+	switch lg.Topics[0] {
+	case s.abi.Events["TransferSingle"].ID:
+		return s.handleTransferSingle(ctx, lg)
+	case s.abi.Events["TransferBatch"].ID:
+		return s.handleTransferBatch(ctx, lg)
+	case s.abi.Events["URI"].ID:
+		return s.handleURI(ctx, lg)
+	case s.abi.Events["ApprovalForAll"].ID:
+		return s.handleApprovalForAll(ctx, lg)
+	default:
+		return nil
+	}
+}
+
+func (s *ERC1155Scanner) handleTransferSingle(ctx context.Context, lg types.Log) error {
+	// Indexed topics: [0] event sig, [1] operator, [2] from, [3] to
+	if len(lg.Topics) < 4 {
+		return fmt.Errorf("unexpected TransferSingle topics length: %d", len(lg.Topics))
+	}
+
+	ev, err := s.events.ParseTransferSingle(lg)
+	if err != nil {
+		return err
+	}
+	return s.applyTransfers(ctx, lg, ev.From, ev.To, []*big.Int{ev.Id}, []*big.Int{ev.Value})
+}
+
+func (s *ERC1155Scanner) handleTransferBatch(ctx context.Context, lg types.Log) error {
+	// Indexed topics: [0] event sig, [1] operator, [2] from, [3] to
+	if len(lg.Topics) < 4 {
+		return fmt.Errorf("unexpected TransferBatch topics length: %d", len(lg.Topics))
+	}
+
+	ev, err := s.events.ParseTransferBatch(lg)
+	if err != nil {
+		return err
+	}
+	if len(ev.Ids) != len(ev.Values) {
+		return fmt.Errorf("TransferBatch ids/values length mismatch: %d/%d", len(ev.Ids), len(ev.Values))
+	}
+	return s.applyTransfers(ctx, lg, ev.From, ev.To, ev.Ids, ev.Values)
+}
+
+// applyTransfers debits from and credits to for every (id, value) pair of a
+// single log, in one transaction. The log is recorded in
+// nft_token_transfer_logs in the same transaction, so a replayed log is
+// skipped instead of being counted twice. Deltas are added regardless of the
+// position of the logs applied before: a log retried from the dead letter
+// queue or backfilled after newer ones still counts.
+func (s *ERC1155Scanner) applyTransfers(ctx context.Context, lg types.Log, from, to common.Address, ids, values []*big.Int) error {
+	// Net the deltas first: a batch may repeat an id, and each row is
+	// written once per log.
+	deltas := make(map[balanceKey]*big.Int)
+	add := func(k balanceKey, v *big.Int) {
+		if d, ok := deltas[k]; ok {
+			d.Add(d, v)
+			return
+		}
+		deltas[k] = new(big.Int).Set(v)
+	}
+	for i, id := range ids {
+		tokenID := id.String()
+		if from != (common.Address{}) {
+			add(balanceKey{tokenID, from}, new(big.Int).Neg(values[i]))
+		}
+		if to != (common.Address{}) {
+			add(balanceKey{tokenID, to}, values[i])
+		}
+	}
+
+	// Lock rows in a stable order so concurrent writers cannot deadlock.
+	keys := make([]balanceKey, 0, len(deltas))
+	for k := range deltas {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tokenID != keys[j].tokenID {
+			return decimalLess(keys[i].tokenID, keys[j].tokenID)
+		}
+		return bytes.Compare(keys[i].holder.Bytes(), keys[j].holder.Bytes()) < 0
+	})
+
+	nftAddress := lg.Address.Hex()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				s.logger.Printf("erc1155 scanner: rollback tx error: %v", err)
+			}
+		}
+	}()
+
+	applied, err := s.balances.MarkAppliedTx(ctx, tx, s.chainID, nftAddress, lg.BlockNumber, lg.Index, lg.TxHash.Hex())
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return nil
+	}
+
+	for _, k := range keys {
+		holder := k.holder.Hex()
+		current, err := s.balances.GetForUpdateTx(ctx, tx, s.chainID, nftAddress, k.tokenID, holder)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		balance := new(big.Int)
+		blockNumber, logIndex := lg.BlockNumber, lg.Index
+		if current != nil {
+			if _, ok := balance.SetString(current.Balance, 10); !ok {
+				return fmt.Errorf("invalid stored balance %q for %s/%s/%s", current.Balance, nftAddress, k.tokenID, holder)
+			}
+			if !isAfter(blockNumber, logIndex, current.BlockNumber, current.LogIndex) {
+				blockNumber, logIndex = current.BlockNumber, current.LogIndex
+			}
+		}
+
+		// A negative result means a credit before this log has not been
+		// applied yet (or was never indexed, when the scan started after
+		// it); it is kept so that the credit still nets out when it comes.
+		balance.Add(balance, deltas[k])
+
+		if err := s.balances.UpsertTx(ctx, tx, &store.TokenBalance{
+			ChainID:     s.chainID,
+			NFTAddress:  nftAddress,
+			TokenID:     k.tokenID,
+			Holder:      holder,
+			Balance:     balance.String(),
+			BlockNumber: blockNumber,
+			LogIndex:    logIndex,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func (s *ERC1155Scanner) handleURI(ctx context.Context, lg types.Log) error {
+	// Indexed topics: [0] event sig, [1] id
+	if len(lg.Topics) < 2 {
+		return fmt.Errorf("unexpected URI topics length: %d", len(lg.Topics))
+	}
+
+	ev, err := s.events.ParseURI(lg)
+	if err != nil {
+		return err
+	}
+
+	nftAddress := lg.Address.Hex()
+	tokenID := ev.Id.String()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				s.logger.Printf("erc1155 scanner: rollback tx error: %v", err)
+			}
+		}
+	}()
+
+	current, err := s.uris.GetForUpdateTx(ctx, tx, s.chainID, nftAddress, tokenID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	// Unlike balances, a URI is replaced rather than accumulated: an older
+	// log handled late (e.g. from the dead letter queue) is superseded by the
+	// stored one and has nothing left to apply.
+	if current != nil && !isAfter(lg.BlockNumber, lg.Index, current.BlockNumber, current.LogIndex) {
+		return nil
+	}
+
+	if err := s.uris.UpsertTx(ctx, tx, &store.TokenURI{
+		ChainID:     s.chainID,
+		NFTAddress:  nftAddress,
+		TokenID:     tokenID,
+		URI:         ev.Value,
+		BlockNumber: lg.BlockNumber,
+		LogIndex:    lg.Index,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+
+	if s.refresher != nil {
+		s.refresher.Trigger()
+	}
+	return nil
+}
+
+func (s *ERC1155Scanner) handleApprovalForAll(ctx context.Context, lg types.Log) error {
+	if s.approvals == nil {
+		return nil
+	}
+	// ApprovalForAll(address indexed _owner, address indexed _operator, bool _approved)
+	if len(lg.Topics) < 3 {
+		return fmt.Errorf("unexpected ApprovalForAll topics length: %d", len(lg.Topics))
+	}
+	ev, err := s.events.ParseApprovalForAll(lg)
+	if err != nil {
+		return err
+	}
+	return s.approvals.applyOperatorApproval(ctx, lg, ev.Owner, ev.Operator, ev.Approved)
+}
@@ -0,0 +1,256 @@
+package chain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/contracts"
+	"github.com/nft_market_go/internal/store"
+)
+
+// ERC721Scanner indexes Transfer events of the ProjectNFT contract into the
+// nft_token_owners table and keeps nft_assets.owner in sync, so transfers
+// that happen outside our marketplace are reflected as well.
+//
+// A mint (from = 0x0) simply records the first owner. A burn (to = 0x0)
+// records the zero address as owner and hides the asset.
+//
+// With SetApprovalTracking it also indexes ApprovalForAll / Approval events
+// and flags listings the marketplace can no longer fill.
+type ERC721Scanner struct {
+	db        *sql.DB
+	chainID   int64
+	nft       common.Address
+	abi       abi.ABI
+	events    *contracts.ProjectNFTFilterer
+	owners    *store.TokenOwnerStore
+	assets    *store.NftAssetStore
+	approvals *approvalTracker
+	indexer   *logIndexer
+	logger    *log.Logger
+}
+
+// NewERC721Scanner creates a scanner using the ProjectNFT ABI from the contracts package.
+// checkpoints may be nil, in which case the scanner always starts from the
+// configured start block or the current head.
+func NewERC721Scanner(client ChainClient, db *sql.DB, chainID int64, nftAddr common.Address, owners *store.TokenOwnerStore, assets *store.NftAssetStore, checkpoints *store.CheckpointStore, logger *log.Logger) (*ERC721Scanner, error) {
+	parsedABI, err := contracts.ProjectNFTABI()
+	if err != nil {
+		return nil, err
+	}
+	events, err := contracts.NewProjectNFTFilterer(nftAddr, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	s := &ERC721Scanner{
+		db:      db,
+		chainID: chainID,
+		nft:     nftAddr,
+		abi:     parsedABI,
+		events:  events,
+		owners:  owners,
+		assets:  assets,
+		logger:  logger,
+	}
+	s.indexer = newLogIndexer(client, chainID, nftAddr, checkpoints, logger, "erc721 scanner")
+	s.indexer.topics = [][]common.Hash{{parsedABI.Events["Transfer"].ID}}
+	s.indexer.handle = s.handleLog
+	return s, nil
+}
+
+// SetConfirmations makes the scanner apply only events in blocks at least n
+// blocks below the head.
+func (s *ERC721Scanner) SetConfirmations(n uint64) {
+	s.indexer.confirmations = n
+}
+
+// SetStartBlock makes a scanner without a saved checkpoint start at block
+// (typically the contract's deployment block) instead of the current head,
+// so transfers made before the server first ran are indexed too.
+func (s *ERC721Scanner) SetStartBlock(block uint64) {
+	s.indexer.startAt = block
+}
+
+// SetMaxBatchBlocks overrides the maximum number of blocks per FilterLogs query.
+func (s *ERC721Scanner) SetMaxBatchBlocks(n uint64) {
+	if n > 0 {
+		s.indexer.batcher.maxBatchBlocks = n
+	}
+}
+
+// SetApprovalTracking enables indexing of ApprovalForAll / Approval events.
+// Open listings on each of marketplaces are marked UNFILLABLE when the seller
+// revokes that marketplace's approval and LISTED again when it is re-granted.
+// Call before Run.
+func (s *ERC721Scanner) SetApprovalTracking(approvals *store.ApprovalStore, orders *store.OrderStore, marketplaces []common.Address) {
+	s.approvals = &approvalTracker{
+		db:           s.db,
+		chainID:      s.chainID,
+		approvals:    approvals,
+		orders:       orders,
+		marketplaces: marketplaces,
+		logger:       s.logger,
+		name:         "erc721 scanner",
+	}
+	s.indexer.topics[0] = append(s.indexer.topics[0],
+		s.abi.Events["ApprovalForAll"].ID,
+		s.abi.Events["Approval"].ID,
+	)
+}
+
+// SetDeadLetterQueue makes the scanner store logs it fails to handle in q,
+// which retries them in the background. Call before Run.
+func (s *ERC721Scanner) SetDeadLetterQueue(q *DeadLetterQueue) {
+	s.indexer.setDeadLetterQueue(q)
+}
+
+// EnableSubscription makes Run follow new heads and logs over a websocket
+// subscription, falling back to polling while it is down.
+func (s *ERC721Scanner) EnableSubscription() {
+	s.indexer.subscribe = true
+}
+
+// Run starts the scanning loop. It should be run in its own goroutine.
+func (s *ERC721Scanner) Run(ctx context.Context) {
+	s.indexer.run(ctx)
+}
+
+// Backfill replays the contract's events in the historical range [from, to]
+// (to = 0 means the newest confirmed block), resuming an interrupted run
+// with the same from block. Transfers older than the one already applied to
+// a token do not roll its owner back. progress may be nil.
+func (s *ERC721Scanner) Backfill(ctx context.Context, from, to uint64, progress func(BackfillProgress)) error {
+	return s.indexer.backfill(ctx, from, to, progress)
+}
+
+func (s *ERC721Scanner) handleLog(ctx context.Context, lg types.Log) error {
+	if len(lg.Topics) == 0 {
+		return nil
+	}
+
+	switch lg.Topics[0] {
+	case s.abi.Events["Transfer"].ID:
+		return s.handleTransfer(ctx, lg)
+	case s.abi.Events["ApprovalForAll"].ID:
+		return s.handleApprovalForAll(ctx, lg)
+	case s.abi.Events["Approval"].ID:
+		return s.handleApproval(ctx, lg)
+	default:
+		return nil
+	}
+}
+
+func (s *ERC721Scanner) handleTransfer(ctx context.Context, lg types.Log) error {
+	// Transfer(address indexed _from, address indexed _to, uint256 indexed _tokenId)
+	if len(lg.Topics) < 4 {
+		return fmt.Errorf("unexpected Transfer topics length: %d", len(lg.Topics))
+	}
+
+	ev, err := s.events.ParseTransfer(lg)
+	if err != nil {
+		return err
+	}
+	to := ev.To
+	tokenID := ev.TokenId.String()
+	nftAddress := lg.Address.Hex()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				s.logger.Printf("erc721 scanner: rollback tx error: %v", err)
+			}
+		}
+	}()
+
+	current, err := s.owners.GetForUpdateTx(ctx, tx, s.chainID, nftAddress, tokenID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	// Skip transfers that are not newer than what we already applied (replays).
+	if current != nil && !isAfter(lg.BlockNumber, lg.Index, current.BlockNumber, current.LogIndex) {
+		return nil
+	}
+
+	if err := s.owners.UpsertTx(ctx, tx, &store.TokenOwner{
+		ChainID:     s.chainID,
+		NFTAddress:  nftAddress,
+		TokenID:     tokenID,
+		Owner:       to.Hex(),
+		BlockNumber: lg.BlockNumber,
+		LogIndex:    lg.Index,
+	}); err != nil {
+		return err
+	}
+
+	if to == (common.Address{}) {
+		// Burn: the token no longer exists, hide it from every asset list.
+		if err := s.assets.SoftDeleteByNFTTx(ctx, tx, s.chainID, nftAddress, tokenID); err != nil {
+			return err
+		}
+	} else if err := s.assets.UpdateOwnerByNFTTx(ctx, tx, s.chainID, nftAddress, tokenID, to.Hex()); err != nil {
+		return err
+	}
+
+	if s.approvals != nil {
+		if err := s.approvals.clearTokenApprovalTx(ctx, tx, lg, to, tokenID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func (s *ERC721Scanner) handleApprovalForAll(ctx context.Context, lg types.Log) error {
+	if s.approvals == nil {
+		return nil
+	}
+	// ApprovalForAll(address indexed _owner, address indexed _operator, bool _approved)
+	if len(lg.Topics) < 3 {
+		return fmt.Errorf("unexpected ApprovalForAll topics length: %d", len(lg.Topics))
+	}
+	ev, err := s.events.ParseApprovalForAll(lg)
+	if err != nil {
+		return err
+	}
+	return s.approvals.applyOperatorApproval(ctx, lg, ev.Owner, ev.Operator, ev.Approved)
+}
+
+func (s *ERC721Scanner) handleApproval(ctx context.Context, lg types.Log) error {
+	if s.approvals == nil {
+		return nil
+	}
+	// Approval(address indexed _owner, address indexed _approved, uint256 indexed _tokenId)
+	if len(lg.Topics) < 4 {
+		return fmt.Errorf("unexpected Approval topics length: %d", len(lg.Topics))
+	}
+
+	ev, err := s.events.ParseApproval(lg)
+	if err != nil {
+		return err
+	}
+	return s.approvals.applyTokenApproval(ctx, lg, ev.Owner, ev.Approved, ev.TokenId.String())
+}
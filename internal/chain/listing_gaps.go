@@ -0,0 +1,334 @@
+package chain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/nft_market_go/internal/contracts"
+	"github.com/nft_market_go/internal/store"
+)
+
+// Listing IDs are checked gapWindow at a time, and the logs of at most
+// gapLogBatch missing IDs are fetched with one filter.
+const (
+	gapWindow   = 1000
+	gapLogBatch = 100
+)
+
+// ListingGapReport is the outcome of one FillListingGaps run.
+type ListingGapReport struct {
+	ChainID        int64     `json:"chain_id"`
+	Marketplace    string    `json:"marketplace"`
+	Block          uint64    `json:"block"`            // scanner checkpoint nextListingId() was read at
+	NextListingID  string    `json:"next_listing_id"`  // nextListingId() at Block
+	From           string    `json:"from"`             // first listing ID checked; lower IDs were checked by earlier runs
+	Missing        int       `json:"missing"`          // IDs without an applied Listed event
+	FilledFromLog  int       `json:"filled_from_log"`  // repaired by applying their marketplace logs
+	FilledFromView int       `json:"filled_from_view"` // inserted from listings() (no log found)
+	Unresolved     []string  `json:"unresolved"`       // still missing after this run, not checked again
+	Error          string    `json:"error,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+}
+
+// FillListingGaps looks for listing IDs below nextListingId() that have no
+// order, or only a row created from a Cancelled / Sold event. Listing IDs are
+// sequential, so each of them is a Listed event the scanner lost.
+// nextListingId() is read at the scanner checkpoint, so listings the scanner
+// has simply not reached yet are not taken for gaps.
+//
+// The Listed / Cancelled / Sold logs of the missing IDs are fetched with
+// targeted log queries (listing ID topics) over the blocks between the Listed
+// events of the nearest lower and higher listing IDs the scanner did apply,
+// and applied as the scanner would. Without a lower neighbour the query
+// starts at the start block, and is skipped when no start block is set.
+// Cancelled / Sold logs after that window are left to ReconcileListings.
+// IDs still missing afterwards whose listing is active are inserted from
+// listings() as LISTED; inactive ones cannot be told apart (cancelled or
+// sold) and are reported as unresolved.
+//
+// Progress is kept in scanner_listing_gaps: later runs, also after a
+// restart, only check IDs above those checked before, unresolved ones
+// included. The report is also kept for LastGapCheck.
+func (s *MarketplaceScanner) FillListingGaps(ctx context.Context) (*ListingGapReport, error) {
+	report := &ListingGapReport{
+		ChainID:     s.chainID,
+		Marketplace: s.contract.Hex(),
+		Unresolved:  []string{},
+		StartedAt:   time.Now().UTC(),
+	}
+	err := s.fillListingGaps(ctx, report)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	s.lastGapCheck = report
+	s.mu.Unlock()
+	return report, err
+}
+
+// LastGapCheck returns the report of the latest FillListingGaps run, or nil
+// before the first one.
+func (s *MarketplaceScanner) LastGapCheck() *ListingGapReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastGapCheck
+}
+
+// fillListingGaps checks the listing IDs from the saved progress up to
+// nextListingId(), saving progress after every window.
+func (s *MarketplaceScanner) fillListingGaps(ctx context.Context, report *ListingGapReport) error {
+	if s.checkpoints == nil {
+		return fmt.Errorf("listing gap check needs a checkpoint store")
+	}
+	checkpoint, err := s.checkpoints.Get(ctx, s.chainID, s.contract.Hex())
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no scanner checkpoint yet")
+	}
+	if err != nil {
+		return err
+	}
+	report.Block = checkpoint
+
+	from, err := s.checkpoints.GetListingGaps(ctx, s.chainID, s.contract.Hex())
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	report.From = strconv.FormatUint(from, 10)
+
+	caller, err := contracts.NewNFTMarketplaceCaller(s.contract, s.client)
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(checkpoint)}
+	nextID, err := caller.NextListingId(opts)
+	if err != nil {
+		return fmt.Errorf("call nextListingId: %w", err)
+	}
+	report.NextListingID = nextID.String()
+	// Listing IDs are assigned one by one, so they never leave the uint64 range.
+	if !nextID.IsUint64() {
+		return fmt.Errorf("nextListingId %s out of range", nextID)
+	}
+	next := nextID.Uint64()
+
+	for start := from; start < next; start += gapWindow {
+		end := start + gapWindow
+		if end > next {
+			end = next
+		}
+		missing, err := s.missingListingIDs(ctx, start, end)
+		if err != nil {
+			return err
+		}
+		report.Missing += len(missing)
+
+		for len(missing) > 0 {
+			n := len(missing)
+			if n > gapLogBatch {
+				n = gapLogBatch
+			}
+			if err := s.fillGapBatch(ctx, caller, opts, missing[:n], checkpoint, report); err != nil {
+				return err
+			}
+			missing = missing[n:]
+		}
+		if err := s.checkpoints.SaveListingGaps(ctx, s.chainID, s.contract.Hex(), end); err != nil {
+			return err
+		}
+	}
+	if report.Missing > 0 {
+		s.logger.Printf("marketplace scanner: %s listing gaps below %s: %d missing, %d filled from logs, %d from listings(), %d unresolved %v",
+			s.contract.Hex(), report.NextListingID, report.Missing, report.FilledFromLog, report.FilledFromView, len(report.Unresolved), report.Unresolved)
+	}
+	return nil
+}
+
+// missingListingIDs returns the IDs in [from, to) without an applied Listed event.
+func (s *MarketplaceScanner) missingListingIDs(ctx context.Context, from, to uint64) ([]uint64, error) {
+	present, err := s.orderStore.ListListingIDs(ctx, s.chainID, s.contract.Hex(), strconv.FormatUint(from, 10), strconv.FormatUint(to, 10))
+	if err != nil {
+		return nil, err
+	}
+	var missing []uint64
+	i := 0
+	for id := from; id < to; id++ {
+		if i < len(present) && present[i] == strconv.FormatUint(id, 10) {
+			i++
+			continue
+		}
+		missing = append(missing, id)
+	}
+	return missing, nil
+}
+
+// gapBlocks returns the block range that holds the Listed events of ids
+// (sorted), bounded by the Listed blocks of the nearest applied listing IDs
+// around them, the start block and checkpoint. ok is false when there is no
+// lower bound.
+func (s *MarketplaceScanner) gapBlocks(ctx context.Context, ids []uint64, checkpoint uint64) (from, to uint64, ok bool, err error) {
+	from, to = s.startAt, checkpoint
+
+	lower, err := s.orderStore.ListedBlockBefore(ctx, s.chainID, s.contract.Hex(), strconv.FormatUint(ids[0], 10))
+	switch {
+	case err == nil:
+		from = max(from, lower)
+	case err != sql.ErrNoRows:
+		return 0, 0, false, err
+	}
+	upper, err := s.orderStore.ListedBlockAfter(ctx, s.chainID, s.contract.Hex(), strconv.FormatUint(ids[len(ids)-1], 10))
+	switch {
+	case err == nil:
+		to = min(to, upper)
+	case err != sql.ErrNoRows:
+		return 0, 0, false, err
+	}
+	return from, to, from > 0 && from <= to, nil
+}
+
+// fillGapBatch repairs the missing IDs ids, first from their logs, then from
+// listings().
+func (s *MarketplaceScanner) fillGapBatch(ctx context.Context, caller *contracts.NFTMarketplaceCaller, opts *bind.CallOpts, ids []uint64, checkpoint uint64, report *ListingGapReport) error {
+	from, to, ok, err := s.gapBlocks(ctx, ids, checkpoint)
+	if err != nil {
+		return err
+	}
+	if ok {
+		idTopics := make([]common.Hash, 0, len(ids))
+		for _, id := range ids {
+			idTopics = append(idTopics, common.BigToHash(new(big.Int).SetUint64(id)))
+		}
+		topics := append(s.eventTopics(), idTopics)
+		if err := s.batcher.scan(ctx, "listing gaps", []common.Address{s.contract}, topics, from, to, s.handleLog, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range ids {
+		id := strconv.FormatUint(n, 10)
+		o, err := s.orderStore.GetByID(ctx, s.chainID, s.contract.Hex(), id)
+		if err == sql.ErrNoRows {
+			o, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+		if o != nil && o.Seller != "" {
+			report.FilledFromLog++
+			continue
+		}
+
+		listing, err := caller.Listings(opts, new(big.Int).SetUint64(n))
+		if err != nil {
+			return fmt.Errorf("call listings(%s): %w", id, err)
+		}
+		switch {
+		case listing.Seller == (common.Address{}):
+			// IDs the contract never assigned (e.g. 0 when counting from 1).
+			report.Missing--
+		case o == nil && !listing.Active:
+			report.Unresolved = append(report.Unresolved, id)
+		default:
+			if err := s.insertListing(ctx, id, listing); err != nil {
+				return fmt.Errorf("insert listing %s: %w", id, err)
+			}
+			report.FilledFromView++
+		}
+	}
+	return nil
+}
+
+// insertListing stores a listing read from listings(): a new row is a
+// LISTED order, a row created from a Cancelled / Sold event only gets the
+// listing fields and keeps its status. Rows whose Listed event was applied in
+// the meantime are left alone. New rows have no chain position, so any later
+// marketplace log still applies to them.
+func (s *MarketplaceScanner) insertListing(ctx context.Context, id string, listing contracts.NFTMarketplaceListing) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				s.logger.Printf("marketplace scanner: rollback tx error: %v", err)
+			}
+		}
+	}()
+
+	existing, err := s.orderStore.GetByIDForUpdateTx(ctx, tx, s.chainID, s.contract.Hex(), id)
+	if err == sql.ErrNoRows {
+		existing, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Seller != "" {
+		return nil
+	}
+
+	order := existing
+	if order == nil {
+		order = &store.Order{
+			ChainID:     s.chainID,
+			Marketplace: s.contract.Hex(),
+			ListingID:   id,
+			Status:      store.OrderStatusListed,
+		}
+	}
+	order.Seller = listing.Seller.Hex()
+	order.NFTAddress = listing.Nft.Hex()
+	order.TokenID = listing.TokenId.String()
+	order.Amount = listing.Amount.String()
+	order.Price = listing.Price.String()
+	if err := s.orderStore.UpsertTx(ctx, tx, order); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"seller":      order.Seller,
+		"nft_address": order.NFTAddress,
+		"token_id":    order.TokenID,
+		"amount":      order.Amount,
+		"price":       order.Price,
+	})
+	if err != nil {
+		return err
+	}
+	eventType := store.OrderEventListed
+	if existing != nil {
+		eventType = store.OrderEventReconciled
+	}
+	if err := s.orderStore.AppendEventTx(ctx, tx, &store.OrderEvent{
+		ChainID:     s.chainID,
+		Marketplace: s.contract.Hex(),
+		ListingID:   id,
+		Type:        eventType,
+		Source:      store.OrderEventSourceReconciler,
+		Status:      order.Status,
+		Actor:       order.Seller,
+		Payload:     payload,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,271 @@
+package chain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/nft_market_go/internal/contracts"
+	"github.com/nft_market_go/internal/store"
+)
+
+// ListingDiscrepancy is a difference between an open order row and the
+// listing the marketplace's listings(listingId) view returns for it.
+type ListingDiscrepancy struct {
+	ListingID string `json:"listing_id"`
+	Field     string `json:"field"` // exists, active, seller, nft_address, token_id, amount or price
+	DB        string `json:"db"`
+	Chain     string `json:"chain"`
+	Repaired  bool   `json:"repaired"`
+	Error     string `json:"error,omitempty"` // why the repair failed
+}
+
+// ListingReconcileReport is the outcome of one ReconcileListings run.
+type ListingReconcileReport struct {
+	ChainID       int64                `json:"chain_id"`
+	Marketplace   string               `json:"marketplace"`
+	Block         uint64               `json:"block"`           // block the listings were read at
+	NextListingID string               `json:"next_listing_id"` // nextListingId() at Block
+	Checked       int                  `json:"checked"`
+	Discrepancies []ListingDiscrepancy `json:"discrepancies"`
+	Error         string               `json:"error,omitempty"`
+	StartedAt     time.Time            `json:"started_at"`
+	FinishedAt    time.Time            `json:"finished_at"`
+}
+
+// reconcileStatuses are the order statuses whose listing should still be
+// active on-chain. LOCKED / SETTLING are left to the TxTracker.
+var reconcileStatuses = []store.OrderStatus{
+	store.OrderStatusListed,
+	store.OrderStatusUnfillable,
+	store.OrderStatusFailed,
+}
+
+// ReconcileListings compares every LISTED / UNFILLABLE / FAILED order of the
+// marketplace with listings(listingId), read at the newest confirmed block:
+//
+//   - listing IDs at or above nextListingId(), or without a seller on-chain,
+//     do not exist and are only reported;
+//   - an inactive listing means a Cancelled / Sold log was missed: the logs of
+//     that listing after the order's last applied event are fetched and
+//     applied as the scanner would;
+//   - seller / nft / token ID / amount / price that differ are copied from the
+//     chain, and a FAILED order whose listing is still active returns to
+//     LISTED, with a Reconciled order event.
+//
+// The report is also kept for LastReconcile.
+func (s *MarketplaceScanner) ReconcileListings(ctx context.Context) (*ListingReconcileReport, error) {
+	report := &ListingReconcileReport{
+		ChainID:       s.chainID,
+		Marketplace:   s.contract.Hex(),
+		Discrepancies: []ListingDiscrepancy{},
+		StartedAt:     time.Now().UTC(),
+	}
+	err := s.reconcileListings(ctx, report)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	s.lastReconcile = report
+	s.mu.Unlock()
+	return report, err
+}
+
+// LastReconcile returns the report of the latest ReconcileListings run, or
+// nil before the first one.
+func (s *MarketplaceScanner) LastReconcile() *ListingReconcileReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastReconcile
+}
+
+func (s *MarketplaceScanner) reconcileListings(ctx context.Context, report *ListingReconcileReport) error {
+	confirmed, _, err := s.confirmedHead(ctx)
+	if err != nil {
+		return err
+	}
+	report.Block = confirmed
+
+	caller, err := contracts.NewNFTMarketplaceCaller(s.contract, s.client)
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(confirmed)}
+	next, err := caller.NextListingId(opts)
+	if err != nil {
+		return fmt.Errorf("call nextListingId: %w", err)
+	}
+	report.NextListingID = next.String()
+
+	after := "" // first page
+	for {
+		orders, err := s.orderStore.ListByStatusAfter(ctx, s.chainID, s.contract.Hex(), reconcileStatuses, after, 100)
+		if err != nil {
+			return err
+		}
+		for _, o := range orders {
+			after = o.ListingID
+			report.Checked++
+			found, err := s.reconcileListing(ctx, caller, opts, o, next, confirmed)
+			if err != nil {
+				return fmt.Errorf("listing %s: %w", o.ListingID, err)
+			}
+			for _, d := range found {
+				s.logger.Printf("marketplace scanner: listing %s on %s: %s is %q in db but %q on-chain (repaired=%t)", d.ListingID, s.contract.Hex(), d.Field, d.DB, d.Chain, d.Repaired)
+			}
+			report.Discrepancies = append(report.Discrepancies, found...)
+		}
+		if len(orders) < 100 {
+			return nil
+		}
+	}
+}
+
+// reconcileListing checks one order against the chain and returns what differed.
+func (s *MarketplaceScanner) reconcileListing(ctx context.Context, caller *contracts.NFTMarketplaceCaller, opts *bind.CallOpts, o *store.Order, next *big.Int, confirmed uint64) ([]ListingDiscrepancy, error) {
+	id, ok := new(big.Int).SetString(o.ListingID, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid listing id %q", o.ListingID)
+	}
+	missing := []ListingDiscrepancy{{ListingID: o.ListingID, Field: "exists", DB: string(o.Status), Chain: "missing"}}
+	if id.Cmp(next) >= 0 {
+		return missing, nil
+	}
+	listing, err := caller.Listings(opts, id)
+	if err != nil {
+		return nil, fmt.Errorf("call listings: %w", err)
+	}
+	if listing.Seller == (common.Address{}) {
+		return missing, nil
+	}
+
+	if !listing.Active {
+		d := ListingDiscrepancy{ListingID: o.ListingID, Field: "active", DB: string(o.Status), Chain: "inactive"}
+		if err := s.applyMissedEnd(ctx, o, id, confirmed); err != nil {
+			d.Error = err.Error()
+		} else if cur, err := s.orderStore.GetByID(ctx, s.chainID, s.contract.Hex(), o.ListingID); err == nil {
+			d.Repaired = cur.Status == store.OrderStatusCanceled || cur.Status == store.OrderStatusSuccess
+			if !d.Repaired {
+				d.Error = "no Cancelled / Sold log found"
+			}
+		}
+		return []ListingDiscrepancy{d}, nil
+	}
+
+	var found []ListingDiscrepancy
+	diff := func(field, db, chain string) {
+		if db != chain {
+			found = append(found, ListingDiscrepancy{ListingID: o.ListingID, Field: field, DB: db, Chain: chain})
+		}
+	}
+	diff("seller", o.Seller, listing.Seller.Hex())
+	diff("nft_address", o.NFTAddress, listing.Nft.Hex())
+	diff("token_id", o.TokenID, listing.TokenId.String())
+	diff("amount", o.Amount, listing.Amount.String())
+	diff("price", o.Price, listing.Price.String())
+	if o.Status == store.OrderStatusFailed {
+		found = append(found, ListingDiscrepancy{ListingID: o.ListingID, Field: "active", DB: string(o.Status), Chain: "active"})
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	err = s.repairListing(ctx, o, listing, found)
+	for i := range found {
+		found[i].Repaired = err == nil
+		if err != nil {
+			found[i].Error = err.Error()
+		}
+	}
+	return found, nil
+}
+
+// applyMissedEnd fetches the Cancelled / Sold logs of o's listing between its
+// last applied event and confirmed, and applies them.
+func (s *MarketplaceScanner) applyMissedEnd(ctx context.Context, o *store.Order, id *big.Int, confirmed uint64) error {
+	from := o.BlockNumber
+	if from == 0 {
+		from = s.startAt
+	}
+	if from == 0 {
+		// Scanning from genesis is not worth it; Backfill covers this case.
+		return fmt.Errorf("block of listing %s is unknown and no start block is set", o.ListingID)
+	}
+	topics := [][]common.Hash{
+		{s.abi.Events["Cancelled"].ID, s.abi.Events["Sold"].ID},
+		{common.BigToHash(id)},
+	}
+	return s.batcher.scan(ctx, "listing reconcile", []common.Address{s.contract}, topics, from, confirmed, s.handleLog, nil)
+}
+
+// repairListing copies the listing fields from the chain onto o, reopens a
+// FAILED order, and records a Reconciled event listing the differences.
+func (s *MarketplaceScanner) repairListing(ctx context.Context, o *store.Order, listing contracts.NFTMarketplaceListing, found []ListingDiscrepancy) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				s.logger.Printf("marketplace scanner: rollback tx error: %v", err)
+			}
+		}
+	}()
+
+	// The order may have moved on (event applied, tx tracked) since it was listed.
+	cur, err := s.orderStore.GetByIDForUpdateTx(ctx, tx, s.chainID, s.contract.Hex(), o.ListingID)
+	if err != nil {
+		return err
+	}
+	if cur.Status != o.Status {
+		return nil
+	}
+
+	cur.Seller = listing.Seller.Hex()
+	cur.NFTAddress = listing.Nft.Hex()
+	cur.TokenID = listing.TokenId.String()
+	cur.Amount = listing.Amount.String()
+	cur.Price = listing.Price.String()
+	if cur.Status == store.OrderStatusFailed {
+		cur.Status = store.OrderStatusListed
+	}
+	if err := s.orderStore.UpsertTx(ctx, tx, cur); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]any{"discrepancies": found})
+	if err != nil {
+		return err
+	}
+	if err := s.orderStore.AppendEventTx(ctx, tx, &store.OrderEvent{
+		ChainID:     s.chainID,
+		Marketplace: s.contract.Hex(),
+		ListingID:   o.ListingID,
+		Type:        store.OrderEventReconciled,
+		Source:      store.OrderEventSourceReconciler,
+		Status:      cur.Status,
+		Actor:       cur.Seller,
+		Payload:     payload,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,114 @@
+package chain
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// logBatcher fetches contract logs over a block range using small FilterLogs
+// queries. When the RPC complains about limits it halves the batch size and
+// retries the same block, which keeps public endpoints usable.
+type logBatcher struct {
+	client         ChainClient
+	logger         *log.Logger
+	name           string // log prefix, e.g. "marketplace scanner"
+	maxBatchBlocks uint64
+	lastLimitLog   time.Time
+	// failed, when set, is given every log whose handler returned an error
+	// (see DeadLetterQueue).
+	failed func(ctx context.Context, lg types.Log, err error)
+}
+
+func newLogBatcher(client ChainClient, logger *log.Logger, name string) *logBatcher {
+	return &logBatcher{
+		client:         client,
+		logger:         logger,
+		name:           name,
+		maxBatchBlocks: 100, // small block range per query to avoid RPC "limit exceeded"
+	}
+}
+
+// scan fetches logs of addresses matching topics in [from, to] and passes
+// them to handle in block order. Handler errors are logged, passed to
+// b.failed and do not stop the scan. After each batch, done (if non-nil) is called with the last
+// block of the batch and the number of logs handled. Other FilterLogs
+// errors are logged and returned; label tags log lines with the calling job.
+func (b *logBatcher) scan(ctx context.Context, label string, addresses []common.Address, topics [][]common.Hash, from, to uint64, handle func(context.Context, types.Log) error, done func(batchTo uint64, logs int)) error {
+	batchSize := b.maxBatchBlocks
+	for from <= to {
+		batchTo := from + batchSize - 1
+		if batchTo > to {
+			batchTo = to
+		}
+
+		query := ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(batchTo),
+			Addresses: addresses,
+			Topics:    topics,
+		}
+
+		logs, err := b.client.FilterLogs(ctx, query)
+		if err != nil {
+			if isRangeLimitError(err) {
+				// Throttle logging for noisy RPC limit errors.
+				now := time.Now()
+				if now.Sub(b.lastLimitLog) > 5*time.Second {
+					b.logger.Printf("%s: %s FilterLogs limit exceeded (from %d to %d)", b.name, label, from, batchTo)
+					b.lastLimitLog = now
+				}
+
+				// If RPC complains about limits, reduce batch size and retry from the same block.
+				if batchSize > 1 {
+					batchSize = batchSize / 2
+					if batchSize < 1 {
+						batchSize = 1
+					}
+					continue
+				}
+				// Already at batch size 1 and still hitting limits: skip this block range.
+				now = time.Now()
+				if now.Sub(b.lastLimitLog) > 5*time.Second {
+					b.logger.Printf("%s: %s skipping block range %d-%d due to persistent RPC limit errors", b.name, label, from, batchTo)
+					b.lastLimitLog = now
+				}
+				if done != nil {
+					done(batchTo, 0)
+				}
+				from = batchTo + 1
+				continue
+			}
+
+			// Other errors: log once and abort this pass; caller can retry later.
+			b.logger.Printf("%s: %s FilterLogs error (from %d to %d): %v", b.name, label, from, batchTo, err)
+			return err
+		}
+
+		for _, lg := range logs {
+			if err := handle(ctx, lg); err != nil {
+				b.logger.Printf("%s: %s handleLog error: %v", b.name, label, err)
+				b.handleFailed(ctx, lg, err)
+			}
+		}
+
+		if done != nil {
+			done(batchTo, len(logs))
+		}
+		from = batchTo + 1
+	}
+
+	return nil
+}
+
+// handleFailed passes a log whose handler failed to b.failed, if set.
+func (b *logBatcher) handleFailed(ctx context.Context, lg types.Log, err error) {
+	if b.failed != nil {
+		b.failed(ctx, lg, err)
+	}
+}
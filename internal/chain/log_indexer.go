@@ -0,0 +1,220 @@
+package chain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/store"
+)
+
+// logIndexer is the polling loop shared by the token indexers. It resumes
+// from the checkpoint of its contract (or, on first start, the configured
+// start block or the confirmed head), only applies blocks with enough
+// confirmations, and hands every log that matches topics to handle, saving
+// the checkpoint after each batch.
+type logIndexer struct {
+	client        ChainClient
+	chainID       int64
+	contract      common.Address
+	topics        [][]common.Hash
+	checkpoints   *store.CheckpointStore
+	logger        *log.Logger
+	name          string
+	pollInterval  time.Duration
+	confirmations uint64
+	startAt       uint64 // first block to scan when there is no checkpoint, 0 = head
+	subscribe     bool
+	batcher       *logBatcher
+	handle        func(context.Context, types.Log) error
+}
+
+func newLogIndexer(client ChainClient, chainID int64, contract common.Address, checkpoints *store.CheckpointStore, logger *log.Logger, name string) *logIndexer {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &logIndexer{
+		client:       client,
+		chainID:      chainID,
+		contract:     contract,
+		checkpoints:  checkpoints,
+		logger:       logger,
+		name:         name,
+		pollInterval: 5 * time.Second,
+		batcher:      newLogBatcher(client, logger, name),
+	}
+}
+
+// run polls for new confirmed blocks until ctx is canceled.
+func (x *logIndexer) run(ctx context.Context) {
+	lastScanned, err := x.startBlock(ctx)
+	if err != nil {
+		x.logger.Printf("%s: failed to determine start block: %v", x.name, err)
+		return
+	}
+
+	ticker := time.NewTicker(x.pollInterval)
+	defer ticker.Stop()
+
+	var sub *logSubscription
+	var wake <-chan struct{}
+	if x.subscribe {
+		sub = newLogSubscription(x.client, []common.Address{x.contract}, x.topics, x.logger, x.name)
+		wake = sub.wake
+		go sub.run(ctx)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			x.logger.Printf("%s: context canceled, stopping", x.name)
+			return
+		case <-ticker.C:
+			// While the websocket subscription is up, new heads drive the loop.
+			if sub != nil && sub.isLive() {
+				continue
+			}
+		case <-wake:
+		}
+
+		latest, err := x.confirmedHead(ctx)
+		if err != nil {
+			x.logger.Printf("%s: get head error: %v", x.name, err)
+			continue
+		}
+		if latest <= lastScanned {
+			continue
+		}
+
+		done := func(batchTo uint64, _ int) {
+			lastScanned = batchTo
+			x.saveCheckpoint(ctx, lastScanned)
+		}
+		// On errors the scan has already logged; we'll retry from lastScanned next tick.
+		if sub != nil {
+			_ = sub.scan(ctx, x.batcher, "scan", lastScanned+1, latest, x.handle, done)
+		} else {
+			_ = x.batcher.scan(ctx, "scan", []common.Address{x.contract}, x.topics, lastScanned+1, latest, x.handle, done)
+		}
+	}
+}
+
+// setDeadLetterQueue stores the logs handle fails on in q, which retries them.
+func (x *logIndexer) setDeadLetterQueue(q *DeadLetterQueue) {
+	x.batcher.failed = q.register(x.chainID, x.contract, x.name, x.client, x.handle)
+}
+
+func (x *logIndexer) startBlock(ctx context.Context) (uint64, error) {
+	if x.checkpoints != nil {
+		block, err := x.checkpoints.Get(ctx, x.chainID, x.contract.Hex())
+		if err == nil {
+			x.logger.Printf("%s: resuming from checkpoint block %d", x.name, block)
+			return block, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+
+	if x.startAt > 0 {
+		block := x.startAt - 1
+		x.logger.Printf("%s: no checkpoint for %s, starting from configured start block %d", x.name, x.contract.Hex(), x.startAt)
+		x.saveCheckpoint(ctx, block)
+		return block, nil
+	}
+
+	block, err := x.confirmedHead(ctx)
+	if err != nil {
+		return 0, err
+	}
+	x.logger.Printf("%s: no checkpoint, starting from confirmed head block %d", x.name, block)
+	x.saveCheckpoint(ctx, block)
+	return block, nil
+}
+
+// backfill replays the logs of the historical range [from, to] (to = 0 means
+// the newest confirmed block), like MarketplaceScanner.Backfill: progress is
+// saved in scanner_backfills after every batch, so running it again with the
+// same from block resumes an interrupted run. progress may be nil.
+func (x *logIndexer) backfill(ctx context.Context, from, to uint64, progress func(BackfillProgress)) error {
+	if to == 0 {
+		confirmed, err := x.confirmedHead(ctx)
+		if err != nil {
+			return err
+		}
+		to = confirmed
+	}
+	if from > to {
+		return fmt.Errorf("invalid backfill range %d-%d", from, to)
+	}
+
+	start := from
+	if x.checkpoints != nil {
+		last, err := x.checkpoints.GetBackfill(ctx, x.chainID, x.contract.Hex(), from)
+		switch {
+		case err == nil:
+			if last >= to {
+				x.logger.Printf("%s: backfill %d-%d already completed up to block %d", x.name, from, to, last)
+				return nil
+			}
+			start = last + 1
+			x.logger.Printf("%s: resuming backfill %d-%d from block %d", x.name, from, to, start)
+		case err != sql.ErrNoRows:
+			return err
+		}
+	}
+
+	handled := 0
+	return x.batcher.scan(ctx, "backfill", []common.Address{x.contract}, x.topics, start, to, x.handle, func(batchTo uint64, logs int) {
+		handled += logs
+		if x.checkpoints != nil {
+			saveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if err := x.checkpoints.SaveBackfill(saveCtx, x.chainID, x.contract.Hex(), from, to, batchTo); err != nil {
+				x.logger.Printf("%s: save backfill progress %d error: %v", x.name, batchTo, err)
+			}
+			cancel()
+		}
+		if progress != nil {
+			progress(BackfillProgress{From: from, To: to, Current: batchTo, Logs: handled})
+		}
+	})
+}
+
+// confirmedHead returns the newest block with enough confirmations.
+func (x *logIndexer) confirmedHead(ctx context.Context) (uint64, error) {
+	header, err := x.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	head := header.Number.Uint64()
+	if head <= x.confirmations {
+		return 0, nil
+	}
+	return head - x.confirmations, nil
+}
+
+func (x *logIndexer) saveCheckpoint(ctx context.Context, block uint64) {
+	if x.checkpoints == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := x.checkpoints.Save(ctx, x.chainID, x.contract.Hex(), block); err != nil {
+		x.logger.Printf("%s: save checkpoint %d error: %v", x.name, block, err)
+	}
+}
+
+// isAfter reports whether a log at (block, index) comes after the position
+// (prevBlock, prevIndex) that was last applied.
+func isAfter(block uint64, index uint, prevBlock uint64, prevIndex uint) bool {
+	if block != prevBlock {
+		return block > prevBlock
+	}
+	return index > prevIndex
+}
@@ -0,0 +1,28 @@
+package chain
+
+import "testing"
+
+func TestIsAfter(t *testing.T) {
+	tests := []struct {
+		name      string
+		block     uint64
+		index     uint
+		prevBlock uint64
+		prevIndex uint
+		want      bool
+	}{
+		{"later block", 11, 0, 10, 5, true},
+		{"earlier block", 9, 7, 10, 5, false},
+		{"same block later index", 10, 6, 10, 5, true},
+		{"same position", 10, 5, 10, 5, false},
+		{"same block earlier index", 10, 4, 10, 5, false},
+		{"replayed log of an older block", 10, 9, 12, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAfter(tt.block, tt.index, tt.prevBlock, tt.prevIndex); got != tt.want {
+				t.Errorf("isAfter(%d, %d, %d, %d) = %v, want %v", tt.block, tt.index, tt.prevBlock, tt.prevIndex, got, tt.want)
+			}
+		})
+	}
+}
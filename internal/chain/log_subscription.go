@@ -0,0 +1,235 @@
+package chain
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// logSubscription keeps a SubscribeNewHead + SubscribeFilterLogs pair open on
+// a websocket RPC endpoint. New heads wake the scanner immediately instead of
+// waiting for the next poll, and the logs pushed by the node are buffered so
+// the scanner can apply a confirmed range without calling FilterLogs.
+//
+// The buffer is only trusted for blocks after the subscription was
+// established ("covered"). Ranges before that (catching up, re-scans after a
+// reorg, the gap left by a dropped subscription) fall back to FilterLogs.
+// While the subscription is down the scanner keeps polling.
+type logSubscription struct {
+	client     ChainClient
+	query      ethereum.FilterQuery
+	logger     *log.Logger
+	name       string
+	retryDelay time.Duration
+
+	wake chan struct{}
+
+	mu      sync.Mutex
+	live    bool
+	covered uint64 // first block whose logs are complete in buffer; 0 until the first head
+	head    uint64
+	buffer  map[uint64][]types.Log
+}
+
+func newLogSubscription(client ChainClient, addresses []common.Address, topics [][]common.Hash, logger *log.Logger, name string) *logSubscription {
+	return &logSubscription{
+		client:     client,
+		query:      ethereum.FilterQuery{Addresses: addresses, Topics: topics},
+		logger:     logger,
+		name:       name,
+		retryDelay: 5 * time.Second,
+		wake:       make(chan struct{}, 1),
+		buffer:     make(map[uint64][]types.Log),
+	}
+}
+
+// run keeps the subscriptions open until ctx is canceled, re-subscribing
+// after retryDelay whenever they fail or drop.
+func (s *logSubscription) run(ctx context.Context) {
+	for {
+		err := s.subscribe(ctx)
+		s.reset()
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Printf("%s: subscription dropped, falling back to polling: %v", s.name, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retryDelay):
+		}
+	}
+}
+
+func (s *logSubscription) subscribe(ctx context.Context) error {
+	heads := make(chan *types.Header, 16)
+	headSub, err := s.client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer headSub.Unsubscribe()
+
+	logs := make(chan types.Log, 256)
+	logSub, err := s.client.SubscribeFilterLogs(ctx, s.query, logs)
+	if err != nil {
+		return err
+	}
+	defer logSub.Unsubscribe()
+
+	s.logger.Printf("%s: websocket subscription established", s.name)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-headSub.Err():
+			return err
+		case err := <-logSub.Err():
+			return err
+		case h := <-heads:
+			s.onHead(h.Number.Uint64())
+		case lg := <-logs:
+			s.onLog(lg)
+		}
+	}
+}
+
+func (s *logSubscription) onHead(number uint64) {
+	s.mu.Lock()
+	if !s.live {
+		// Logs of this head may have been delivered before the subscription
+		// was in place; only trust the blocks after it.
+		s.live = true
+		s.covered = number + 1
+	}
+	if number > s.head {
+		s.head = number
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *logSubscription) onLog(lg types.Log) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.live || lg.BlockNumber < s.covered {
+		return
+	}
+
+	logs := s.buffer[lg.BlockNumber]
+	if lg.Removed {
+		// The block was reorged out before we applied it; forget the log.
+		kept := logs[:0]
+		for _, l := range logs {
+			if l.TxHash != lg.TxHash || l.Index != lg.Index {
+				kept = append(kept, l)
+			}
+		}
+		s.buffer[lg.BlockNumber] = kept
+		return
+	}
+	s.buffer[lg.BlockNumber] = append(logs, lg)
+}
+
+func (s *logSubscription) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.live = false
+	s.covered = 0
+	s.buffer = make(map[uint64][]types.Log)
+}
+
+// isLive reports whether the subscription is currently delivering heads.
+func (s *logSubscription) isLive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.live
+}
+
+// take returns the buffered logs of [from, to] in chain order when the
+// buffer covers the whole range, removing them from the buffer.
+func (s *logSubscription) take(from, to uint64) ([]types.Log, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.live || from < s.covered || to > s.head {
+		return nil, false
+	}
+
+	var out []types.Log
+	for n, logs := range s.buffer {
+		if n > to {
+			continue
+		}
+		if n >= from {
+			out = append(out, logs...)
+		}
+		delete(s.buffer, n)
+	}
+	s.covered = to + 1
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].BlockNumber != out[j].BlockNumber {
+			return out[i].BlockNumber < out[j].BlockNumber
+		}
+		return out[i].Index < out[j].Index
+	})
+	return out, true
+}
+
+// advance records that [from, to] was scanned some other way, so buffered
+// logs up to to are no longer needed. The buffer is not trusted for any
+// block up to to afterwards, including blocks before from that were never
+// scanned.
+func (s *logSubscription) advance(from, to uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.live {
+		return
+	}
+	for n := range s.buffer {
+		if n <= to {
+			delete(s.buffer, n)
+		}
+	}
+	if to+1 > s.covered {
+		s.covered = to + 1
+	}
+}
+
+// scan applies [from, to] from the buffer when possible and otherwise via
+// FilterLogs through b. handle and done behave as in logBatcher.scan.
+func (s *logSubscription) scan(ctx context.Context, b *logBatcher, label string, from, to uint64, handle func(context.Context, types.Log) error, done func(batchTo uint64, logs int)) error {
+	if logs, ok := s.take(from, to); ok {
+		for _, lg := range logs {
+			if err := handle(ctx, lg); err != nil {
+				s.logger.Printf("%s: %s handleLog error: %v", s.name, label, err)
+				b.handleFailed(ctx, lg, err)
+			}
+		}
+		if done != nil {
+			done(to, len(logs))
+		}
+		return nil
+	}
+
+	return b.scan(ctx, label, s.query.Addresses, s.query.Topics, from, to, handle, func(batchTo uint64, n int) {
+		s.advance(from, batchTo)
+		if done != nil {
+			done(batchTo, n)
+		}
+	})
+}
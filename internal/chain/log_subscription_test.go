@@ -0,0 +1,174 @@
+package chain
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func testLog(block uint64, index uint) types.Log {
+	return types.Log{BlockNumber: block, Index: index, TxHash: common.BigToHash(common.Big1)}
+}
+
+// liveSubscription returns a subscription that went live at head and then
+// received heads up to latest and logs.
+func liveSubscription(head, latest uint64, logs ...types.Log) *logSubscription {
+	s := newLogSubscription(nil, nil, nil, log.New(io.Discard, "", 0), "test")
+	s.onHead(head)
+	for n := head + 1; n <= latest; n++ {
+		s.onHead(n)
+	}
+	for _, lg := range logs {
+		s.onLog(lg)
+	}
+	return s
+}
+
+func logPositions(logs []types.Log) [][2]uint64 {
+	out := make([][2]uint64, len(logs))
+	for i, lg := range logs {
+		out[i] = [2]uint64{lg.BlockNumber, uint64(lg.Index)}
+	}
+	return out
+}
+
+func TestLogSubscriptionTake(t *testing.T) {
+	tests := []struct {
+		name     string
+		sub      func() *logSubscription
+		from, to uint64
+		ok       bool
+		want     [][2]uint64
+	}{
+		{
+			name: "not live",
+			sub: func() *logSubscription {
+				return newLogSubscription(nil, nil, nil, log.New(io.Discard, "", 0), "test")
+			},
+			from: 1, to: 1,
+		},
+		{
+			name: "range starts before the subscription",
+			sub:  func() *logSubscription { return liveSubscription(10, 12) },
+			from: 10, to: 12,
+		},
+		{
+			name: "range ends after the head",
+			sub:  func() *logSubscription { return liveSubscription(10, 12) },
+			from: 11, to: 13,
+		},
+		{
+			name: "empty blocks",
+			sub:  func() *logSubscription { return liveSubscription(10, 12) },
+			from: 11, to: 12, ok: true,
+			want: [][2]uint64{},
+		},
+		{
+			name: "logs in chain order",
+			sub: func() *logSubscription {
+				return liveSubscription(10, 13, testLog(12, 3), testLog(11, 5), testLog(12, 1), testLog(13, 0))
+			},
+			from: 11, to: 12, ok: true,
+			want: [][2]uint64{{11, 5}, {12, 1}, {12, 3}},
+		},
+		{
+			name: "removed log is dropped",
+			sub: func() *logSubscription {
+				removed := testLog(11, 2)
+				removed.Removed = true
+				return liveSubscription(10, 11, testLog(11, 1), testLog(11, 2), removed)
+			},
+			from: 11, to: 11, ok: true,
+			want: [][2]uint64{{11, 1}},
+		},
+		{
+			name: "logs before the subscription are ignored",
+			sub: func() *logSubscription {
+				return liveSubscription(10, 11, testLog(10, 0), testLog(11, 0))
+			},
+			from: 11, to: 11, ok: true,
+			want: [][2]uint64{{11, 0}},
+		},
+		{
+			name: "taken range is not taken again",
+			sub: func() *logSubscription {
+				s := liveSubscription(10, 12, testLog(11, 0))
+				s.take(11, 12)
+				return s
+			},
+			from: 11, to: 12,
+		},
+		{
+			name: "next range after a take",
+			sub: func() *logSubscription {
+				s := liveSubscription(10, 13, testLog(11, 0), testLog(13, 0))
+				s.take(11, 12)
+				return s
+			},
+			from: 13, to: 13, ok: true,
+			want: [][2]uint64{{13, 0}},
+		},
+		{
+			name: "advance covers the scanned range",
+			sub: func() *logSubscription {
+				s := liveSubscription(10, 13, testLog(11, 0), testLog(13, 0))
+				s.advance(11, 12)
+				return s
+			},
+			from: 13, to: 13, ok: true,
+			want: [][2]uint64{{13, 0}},
+		},
+		{
+			name: "advance drops the buffered logs it covers",
+			sub: func() *logSubscription {
+				s := liveSubscription(10, 13, testLog(11, 0), testLog(13, 0))
+				s.advance(11, 12)
+				s.covered = 11 // pretend the scanner re-scans from 11
+				return s
+			},
+			from: 11, to: 13, ok: true,
+			want: [][2]uint64{{13, 0}},
+		},
+		{
+			name: "advance past a gap drops the gap",
+			sub: func() *logSubscription {
+				s := liveSubscription(10, 14, testLog(11, 0), testLog(13, 0))
+				s.advance(13, 13) // 11-12 not scanned yet
+				return s
+			},
+			from: 11, to: 12,
+		},
+		{
+			name: "reset forgets the buffer",
+			sub: func() *logSubscription {
+				s := liveSubscription(10, 12, testLog(11, 0))
+				s.reset()
+				return s
+			},
+			from: 11, to: 12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, ok := tt.sub().take(tt.from, tt.to)
+			if ok != tt.ok {
+				t.Fatalf("take(%d, %d) ok = %v, want %v", tt.from, tt.to, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			got := logPositions(logs)
+			if len(got) != len(tt.want) {
+				t.Fatalf("take(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("take(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,44 +1,99 @@
 package chain
 
 import (
-	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
 	"log"
 	"math/big"
-	"os"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 
+	"github.com/nft_market_go/internal/contracts"
 	"github.com/nft_market_go/internal/store"
 )
 
 // MarketplaceScanner periodically scans blocks for marketplace events
 // (Listed / Cancelled / Sold) and syncs them into the orders table.
+// Progress is persisted in scanner_checkpoints so restarts resume from the
+// last fully processed block instead of the current head.
+//
+// To survive chain reorganizations the scanner records the hash of every
+// processed block and, before applying an event, snapshots the order it
+// changes. When the canonical chain no longer links to a recorded hash, the
+// orders are rolled back to the last common block and re-scanned.
+//
+// A scanner follows a single marketplace contract. When the marketplace is
+// redeployed, run one scanner per contract version: orders are keyed by
+// (chain, marketplace, listing ID), so listing IDs of different versions
+// never collide.
 type MarketplaceScanner struct {
-	client         *ethclient.Client
-	contract       common.Address
-	abi            abi.ABI
-	orderStore     *store.OrderStore
-	logger         *log.Logger
-	pollInterval   time.Duration
-	maxBatchBlocks uint64
-	lastLimitLog   time.Time
-}
-
-// NewMarketplaceScanner creates a scanner using the NFTMarketplace ABI at docs/NFTMarketplace.abi.json.
-func NewMarketplaceScanner(client *ethclient.Client, contractAddr common.Address, orders *store.OrderStore, logger *log.Logger) (*MarketplaceScanner, error) {
-	data, err := os.ReadFile("docs/NFTMarketplace.abi.json")
+	client        ChainClient
+	db            *sql.DB
+	chainID       int64
+	contract      common.Address
+	abi           abi.ABI
+	events        *contracts.NFTMarketplaceFilterer
+	orderStore    *store.OrderStore
+	checkpoints   *store.CheckpointStore
+	reorgs        *store.ReorgStore
+	logger        *log.Logger
+	pollInterval  time.Duration
+	batcher       *logBatcher
+	maxReorgDepth uint64
+	confirmations uint64
+	exposePending bool
+	subscribe     bool
+	startAt       uint64 // first block to scan when there is no checkpoint, 0 = head
+
+	mu         sync.Mutex
+	rewind     *uint64 // set when removed logs rolled back state; Run re-scans from here
+	pending    []PendingEvent
+	blockTimes map[common.Hash]time.Time // block timestamps by hash, see chainEvent
+
+	lastReconcile *ListingReconcileReport
+	lastGapCheck  *ListingGapReport
+}
+
+// blockTimeCacheSize bounds the block timestamp cache; it is simply reset
+// when full.
+const blockTimeCacheSize = 1024
+
+// PendingEvent is a marketplace event found in a block that does not have
+// enough confirmations yet. It is not applied to the orders table; it is only
+// reported (with status PENDING) so the UI can show it early.
+type PendingEvent struct {
+	Event         string            `json:"event"` // Listed, Cancelled or Sold
+	ChainID       int64             `json:"chain_id"`
+	Marketplace   string            `json:"marketplace"`
+	ListingID     string            `json:"listing_id"`
+	Seller        string            `json:"seller,omitempty"`
+	Buyer         string            `json:"buyer,omitempty"`
+	NFTAddress    string            `json:"nft_address,omitempty"`
+	TokenID       string            `json:"token_id,omitempty"`
+	Amount        string            `json:"amount,omitempty"`
+	Price         string            `json:"price,omitempty"`
+	Status        store.OrderStatus `json:"status"`
+	TxHash        string            `json:"tx_hash"`
+	BlockNumber   uint64            `json:"block_number"`
+	Confirmations uint64            `json:"confirmations"`
+}
+
+// NewMarketplaceScanner creates a scanner using the NFTMarketplace ABI from the contracts package.
+// checkpoints may be nil, in which case the scanner always starts from the current head.
+// reorgs may be nil, in which case reorg detection and rollback are disabled.
+func NewMarketplaceScanner(client ChainClient, db *sql.DB, chainID int64, contractAddr common.Address, orders *store.OrderStore, checkpoints *store.CheckpointStore, reorgs *store.ReorgStore, logger *log.Logger) (*MarketplaceScanner, error) {
+	parsedABI, err := contracts.NFTMarketplaceABI()
 	if err != nil {
 		return nil, err
 	}
-
-	parsedABI, err := abi.JSON(bytes.NewReader(data))
+	events, err := contracts.NewNFTMarketplaceFilterer(contractAddr, client)
 	if err != nil {
 		return nil, err
 	}
@@ -48,133 +103,436 @@ func NewMarketplaceScanner(client *ethclient.Client, contractAddr common.Address
 	}
 
 	return &MarketplaceScanner{
-		client:         client,
-		contract:       contractAddr,
-		abi:            parsedABI,
-		orderStore:     orders,
-		logger:         logger,
-		pollInterval:   5 * time.Second,
-		maxBatchBlocks: 100, // small block range per query to avoid RPC "limit exceeded"
+		client:        client,
+		db:            db,
+		chainID:       chainID,
+		contract:      contractAddr,
+		abi:           parsedABI,
+		events:        events,
+		orderStore:    orders,
+		checkpoints:   checkpoints,
+		reorgs:        reorgs,
+		logger:        logger,
+		pollInterval:  5 * time.Second,
+		batcher:       newLogBatcher(client, logger, "marketplace scanner"),
+		maxReorgDepth: 1000,
+		blockTimes:    make(map[common.Hash]time.Time),
 	}, nil
 }
 
+// SetStartBlock makes a scanner without a saved checkpoint start at block
+// (typically the contract's deployment block) instead of the current head,
+// so listings created before the server first ran are indexed too.
+func (s *MarketplaceScanner) SetStartBlock(block uint64) {
+	s.startAt = block
+}
+
+// Contract returns the address of the marketplace contract being scanned.
+func (s *MarketplaceScanner) Contract() common.Address {
+	return s.contract
+}
+
+// SetMaxBatchBlocks overrides the maximum number of blocks per FilterLogs query.
+func (s *MarketplaceScanner) SetMaxBatchBlocks(n uint64) {
+	if n > 0 {
+		s.batcher.maxBatchBlocks = n
+	}
+}
+
+// SetConfirmations makes the scanner apply only events in blocks at least n
+// blocks below the head (the scanner always stays at least one block behind).
+// When exposePending is true, events in the newer, unconfirmed blocks are
+// kept in memory and returned by PendingEvents.
+func (s *MarketplaceScanner) SetConfirmations(n uint64, exposePending bool) {
+	s.confirmations = n
+	s.exposePending = exposePending
+}
+
+// SetDeadLetterQueue makes the scanner store logs it fails to handle in q
+// (whichever job fetched them), which retries them in the background. Call
+// before Run.
+func (s *MarketplaceScanner) SetDeadLetterQueue(q *DeadLetterQueue) {
+	s.batcher.failed = q.register(s.chainID, s.contract, "marketplace scanner", s.client, s.handleLog)
+}
+
+// EnableSubscription makes Run follow new heads and logs over a websocket
+// subscription (the client must be dialed with a ws:// or wss:// URL). Logs
+// are still only applied once confirmed; polling takes over whenever the
+// subscription is down and FilterLogs fills the blocks it missed.
+func (s *MarketplaceScanner) EnableSubscription() {
+	s.subscribe = true
+}
+
+// PendingEvents returns the unconfirmed events seen in the last poll.
+// It is empty unless pending events were enabled via SetConfirmations.
+func (s *MarketplaceScanner) PendingEvents() []PendingEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]PendingEvent, len(s.pending))
+	copy(out, s.pending)
+	return out
+}
+
 // Run starts the scanning loop. It should be run in its own goroutine.
-// It starts from the current latest block and only processes new blocks,
-// so it does not backfill historical events.
+// It resumes from the saved checkpoint when one exists; otherwise it starts
+// from the block set by SetStartBlock, or from the current latest block when
+// none was set and only processes new blocks.
 func (s *MarketplaceScanner) Run(ctx context.Context) {
-	// Initialize lastScanned to the current head, so we only process new blocks.
-	head, err := s.client.HeaderByNumber(ctx, nil)
+	lastScanned, err := s.startBlock(ctx)
 	if err != nil {
-		s.logger.Printf("marketplace scanner: failed to get head block: %v", err)
+		s.logger.Printf("marketplace scanner: failed to determine start block: %v", err)
 		return
 	}
-	lastScanned := head.Number.Uint64()
-	s.logger.Printf("marketplace scanner: starting from block %d (no historical backfill)", lastScanned)
 
 	ticker := time.NewTicker(s.pollInterval)
 	defer ticker.Stop()
 
+	var sub *logSubscription
+	var wake <-chan struct{}
+	if s.subscribe {
+		sub = newLogSubscription(s.client, []common.Address{s.contract}, s.eventTopics(), s.logger, "marketplace scanner")
+		wake = sub.wake
+		go sub.run(ctx)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			s.logger.Printf("marketplace scanner: context canceled, stopping")
 			return
 		case <-ticker.C:
-			// Check latest block number.
-			head, err := s.client.HeaderByNumber(ctx, nil)
-			if err != nil {
-				s.logger.Printf("marketplace scanner: get head error: %v", err)
+			// While the websocket subscription is up, new heads drive the loop.
+			if sub != nil && sub.isLive() {
 				continue
 			}
-			latest := head.Number.Uint64()
-			if latest <= lastScanned {
+		case <-wake:
+		}
+
+		if r, ok := s.takeRewind(); ok && r < lastScanned {
+			lastScanned = r
+		}
+
+		// Check latest block number.
+		latest, headNum, err := s.confirmedHead(ctx)
+		if err != nil {
+			s.logger.Printf("marketplace scanner: get head error: %v", err)
+			continue
+		}
+		if s.exposePending {
+			s.refreshPending(ctx, latest+1, headNum)
+		}
+		if latest <= lastScanned {
+			continue
+		}
+
+		lastScanned, err = s.checkReorg(ctx, lastScanned)
+		if err != nil {
+			s.logger.Printf("marketplace scanner: reorg check error: %v", err)
+			continue
+		}
+
+		done := func(batchTo uint64, _ int) {
+			// Successfully processed this batch; advance and persist progress.
+			lastScanned = batchTo
+			s.recordBlock(ctx, batchTo)
+			s.saveCheckpoint(ctx, lastScanned)
+		}
+		// On errors the scan has already logged; we'll retry from lastScanned next tick.
+		if sub != nil {
+			_ = sub.scan(ctx, s.batcher, "scan", lastScanned+1, latest, s.handleLog, done)
+		} else {
+			_ = s.scanRange(ctx, "scan", lastScanned+1, latest, done)
+		}
+
+		s.pruneReorgData(ctx, lastScanned)
+	}
+}
+
+// startBlock returns the last fully processed block to resume from.
+// Without a saved checkpoint it falls back to the block before the configured
+// start block, or else to the current head, and records it so that the next
+// restart resumes from there.
+func (s *MarketplaceScanner) startBlock(ctx context.Context) (uint64, error) {
+	if s.checkpoints != nil {
+		block, err := s.checkpoints.Get(ctx, s.chainID, s.contract.Hex())
+		if err == nil {
+			s.logger.Printf("marketplace scanner: resuming from checkpoint block %d", block)
+			return block, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+
+	if s.startAt > 0 {
+		block := s.startAt - 1
+		s.logger.Printf("marketplace scanner: no checkpoint for %s, starting from configured start block %d", s.contract.Hex(), s.startAt)
+		s.saveCheckpoint(ctx, block)
+		return block, nil
+	}
+
+	block, _, err := s.confirmedHead(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.logger.Printf("marketplace scanner: no checkpoint for %s, starting from confirmed head block %d", s.contract.Hex(), block)
+	s.saveCheckpoint(ctx, block)
+	return block, nil
+}
+
+// confirmedHead returns the newest block with enough confirmations to be
+// applied, together with the current head. The result is always at least one
+// block behind head: a block's hash is taken from its child's parentHash, so
+// only blocks with a known child are applied.
+func (s *MarketplaceScanner) confirmedHead(ctx context.Context) (confirmed, head uint64, err error) {
+	header, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	head = header.Number.Uint64()
+
+	lag := s.confirmations
+	if lag < 1 {
+		lag = 1
+	}
+	if head <= lag {
+		return 0, head, nil
+	}
+	return head - lag, head, nil
+}
+
+// saveCheckpoint persists the last fully processed block. Failures are only
+// logged: the scanner keeps running and the next batch will try again.
+func (s *MarketplaceScanner) saveCheckpoint(ctx context.Context, block uint64) {
+	if s.checkpoints == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.checkpoints.Save(ctx, s.chainID, s.contract.Hex(), block); err != nil {
+		s.logger.Printf("marketplace scanner: save checkpoint %d error: %v", block, err)
+	}
+}
+
+// refreshPending replaces the pending event list with the marketplace events
+// found in the unconfirmed range [from, head]. Errors keep the previous list.
+func (s *MarketplaceScanner) refreshPending(ctx context.Context, from, head uint64) {
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(head),
+		Addresses: []common.Address{s.contract},
+		Topics:    s.eventTopics(),
+	}
+	logs, err := s.client.FilterLogs(ctx, query)
+	if err != nil {
+		s.logger.Printf("marketplace scanner: pending FilterLogs error (from %d to %d): %v", from, head, err)
+		return
+	}
+
+	pending := make([]PendingEvent, 0, len(logs))
+	for _, lg := range logs {
+		if lg.Removed || len(lg.Topics) < 2 {
+			continue
+		}
+		ev := PendingEvent{
+			ChainID:       s.chainID,
+			Marketplace:   s.contract.Hex(),
+			ListingID:     lg.Topics[1].Big().String(),
+			Status:        store.OrderStatusPending,
+			TxHash:        lg.TxHash.Hex(),
+			BlockNumber:   lg.BlockNumber,
+			Confirmations: head - lg.BlockNumber + 1,
+		}
+		switch lg.Topics[0] {
+		case s.abi.Events["Listed"].ID:
+			order, err := s.listedOrder(lg)
+			if err != nil || order == nil {
 				continue
 			}
-
-			// Scan in small batches to avoid RPC "limit exceeded" errors.
-			from := lastScanned + 1
-			batchSize := s.maxBatchBlocks
-			for from <= latest {
-				to := from + batchSize - 1
-				if to > latest {
-					to = latest
-				}
-
-				// Only care about Listed / Cancelled / Sold events.
-				topics := [][]common.Hash{
-					{
-						s.abi.Events["Listed"].ID,
-						s.abi.Events["Cancelled"].ID,
-						s.abi.Events["Sold"].ID,
-					},
-				}
-
-				query := ethereum.FilterQuery{
-					FromBlock: big.NewInt(int64(from)),
-					ToBlock:   big.NewInt(int64(to)),
-					Addresses: []common.Address{s.contract},
-					Topics:    topics,
-				}
-
-				logs, err := s.client.FilterLogs(ctx, query)
-				if err != nil {
-					limitErr := strings.Contains(err.Error(), "limit exceeded")
-					if limitErr {
-						// Throttle logging for noisy RPC limit errors.
-						now := time.Now()
-						if now.Sub(s.lastLimitLog) > 5*time.Second {
-							s.logger.Printf("marketplace scanner: FilterLogs limit exceeded (from %d to %d)", from, to)
-							s.lastLimitLog = now
-						}
-
-						// If RPC complains about limits, reduce batch size and retry from the same block.
-						if batchSize > 1 {
-							batchSize = batchSize / 2
-							if batchSize < 1 {
-								batchSize = 1
-							}
-							continue
-						}
-						// Already at batch size 1 and still hitting limits: skip this block range.
-						now = time.Now()
-						if now.Sub(s.lastLimitLog) > 5*time.Second {
-							s.logger.Printf("marketplace scanner: skipping block range %d-%d due to persistent RPC limit errors", from, to)
-							s.lastLimitLog = now
-						}
-						lastScanned = to
-						from = to + 1
-						continue
-					}
-
-					// Other errors: log once and break this polling cycle; we'll retry next tick.
-					s.logger.Printf("marketplace scanner: FilterLogs error (from %d to %d): %v", from, to, err)
-					break
-				}
-
-				for _, lg := range logs {
-					if err := s.handleLog(ctx, lg); err != nil {
-						s.logger.Printf("marketplace scanner: handleLog error: %v", err)
-					}
-				}
-
-				// Successfully processed this batch; advance.
-				lastScanned = to
-				from = to + 1
+			ev.Event = "Listed"
+			ev.Seller = order.Seller
+			ev.NFTAddress = order.NFTAddress
+			ev.TokenID = order.TokenID
+			ev.Amount = order.Amount
+			ev.Price = order.Price
+		case s.abi.Events["Cancelled"].ID:
+			ev.Event = "Cancelled"
+		case s.abi.Events["Sold"].ID:
+			sold, err := s.events.ParseSold(lg)
+			if err != nil {
+				continue
 			}
+			ev.Event = "Sold"
+			ev.Buyer = sold.Buyer.Hex()
+		default:
+			continue
 		}
+		pending = append(pending, ev)
+	}
+
+	s.mu.Lock()
+	s.pending = pending
+	s.mu.Unlock()
+}
+
+// canonicalHash returns the hash of block number on the canonical chain, see
+// canonicalBlockHash.
+func (s *MarketplaceScanner) canonicalHash(ctx context.Context, number uint64) (common.Hash, error) {
+	return canonicalBlockHash(ctx, s.client, number)
+}
+
+// canonicalBlockHash returns the hash of block number on the canonical chain,
+// as reported by the node in the parentHash of its child block. The hash is
+// not computed locally because header layouts differ between EVM chains.
+func canonicalBlockHash(ctx context.Context, client ChainClient, number uint64) (common.Hash, error) {
+	child, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(number+1))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return child.ParentHash, nil
+}
+
+// recordBlock stores the canonical hash of a processed block so later polls
+// can detect that it was reorged away. Failures are only logged.
+func (s *MarketplaceScanner) recordBlock(ctx context.Context, number uint64) {
+	if s.reorgs == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hash, err := s.canonicalHash(ctx, number)
+	if err != nil {
+		s.logger.Printf("marketplace scanner: get hash of block %d error: %v", number, err)
+		return
+	}
+	if err := s.reorgs.SaveBlock(ctx, s.chainID, s.contract.Hex(), number, hash.Hex()); err != nil {
+		s.logger.Printf("marketplace scanner: save block %d error: %v", number, err)
+	}
+}
+
+// checkReorg verifies that the next block's parentHash still matches the
+// recorded hash of lastScanned. On a mismatch it finds the newest recorded
+// block that is still canonical, rolls orders back to it and returns it as
+// the new lastScanned.
+func (s *MarketplaceScanner) checkReorg(ctx context.Context, lastScanned uint64) (uint64, error) {
+	if s.reorgs == nil {
+		return lastScanned, nil
+	}
+
+	stored, err := s.reorgs.GetBlockHash(ctx, s.chainID, s.contract.Hex(), lastScanned)
+	if err == sql.ErrNoRows {
+		// Nothing recorded for this block (e.g. first run); nothing to compare.
+		return lastScanned, nil
+	}
+	if err != nil {
+		return lastScanned, err
+	}
+
+	canonical, err := s.canonicalHash(ctx, lastScanned)
+	if err != nil {
+		return lastScanned, err
+	}
+	if canonical.Hex() == stored {
+		return lastScanned, nil
+	}
+
+	s.logger.Printf("marketplace scanner: reorg detected at block %d (recorded %s, canonical %s)", lastScanned, stored, canonical.Hex())
+
+	ancestor, err := s.findCommonAncestor(ctx, lastScanned)
+	if err != nil {
+		return lastScanned, err
+	}
+	if err := s.rollbackTo(ctx, ancestor); err != nil {
+		return lastScanned, err
+	}
+	return ancestor, nil
+}
+
+// findCommonAncestor walks recorded blocks below number (newest first) and
+// returns the first one whose hash is still canonical. If none is found
+// within the tracked window it falls back to number - maxReorgDepth.
+func (s *MarketplaceScanner) findCommonAncestor(ctx context.Context, number uint64) (uint64, error) {
+	blocks, err := s.reorgs.ListBlocksBefore(ctx, s.chainID, s.contract.Hex(), number, 256)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range blocks {
+		canonical, err := s.canonicalHash(ctx, b.Number)
+		if err != nil {
+			return 0, err
+		}
+		if canonical.Hex() == b.Hash {
+			return b.Number, nil
+		}
+	}
+
+	if number > s.maxReorgDepth {
+		return number - s.maxReorgDepth, nil
+	}
+	return 0, nil
+}
+
+// rollbackTo restores orders changed by blocks above ancestor and moves the
+// checkpoint back, so those blocks are re-applied from the canonical chain.
+func (s *MarketplaceScanner) rollbackTo(ctx context.Context, ancestor uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	n, err := s.reorgs.RollbackAfter(ctx, s.chainID, s.contract.Hex(), ancestor)
+	if err != nil {
+		return err
+	}
+	s.logger.Printf("marketplace scanner: rolled back %d order changes above block %d", n, ancestor)
+	s.saveCheckpoint(ctx, ancestor)
+	return nil
+}
+
+// pruneReorgData forgets recorded blocks and undo entries deeper than
+// maxReorgDepth below lastScanned. Failures are only logged.
+func (s *MarketplaceScanner) pruneReorgData(ctx context.Context, lastScanned uint64) {
+	if s.reorgs == nil || lastScanned <= s.maxReorgDepth {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.reorgs.PruneBefore(ctx, s.chainID, s.contract.Hex(), lastScanned-s.maxReorgDepth); err != nil {
+		s.logger.Printf("marketplace scanner: prune reorg data error: %v", err)
+	}
+}
+
+// requestRewind asks Run to re-scan from block on its next poll.
+func (s *MarketplaceScanner) requestRewind(block uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rewind == nil || block < *s.rewind {
+		s.rewind = &block
+	}
+}
+
+func (s *MarketplaceScanner) takeRewind() (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rewind == nil {
+		return 0, false
 	}
+	block := *s.rewind
+	s.rewind = nil
+	return block, true
 }
 
 // ResyncRecent rescans the recent block range [latest-lookback+1, latest]
-// and re-applies marketplace events to the orders table. This helps repair
-// backend state when some events were missed due to temporary RPC errors
-// or downtime. It is safe to call periodically; writes are idempotent.
+// (latest = newest confirmed block) and re-applies marketplace events to
+// the orders table. This helps repair backend state when some events were
+// missed due to temporary RPC errors or downtime. It is safe to call
+// periodically; writes are idempotent.
 func (s *MarketplaceScanner) ResyncRecent(ctx context.Context, lookbackBlocks uint64) error {
-	head, err := s.client.HeaderByNumber(ctx, nil)
+	latest, _, err := s.confirmedHead(ctx)
 	if err != nil {
 		return err
 	}
-	latest := head.Number.Uint64()
 	if latest == 0 {
 		return nil
 	}
@@ -184,74 +542,82 @@ func (s *MarketplaceScanner) ResyncRecent(ctx context.Context, lookbackBlocks ui
 		from = latest - lookbackBlocks + 1
 	}
 
-	batchSize := s.maxBatchBlocks
-
-	for from <= latest {
-		to := from + batchSize - 1
-		if to > latest {
-			to = latest
-		}
+	return s.scanRange(ctx, "reconcile", from, latest, nil)
+}
 
-		// Only care about Listed / Cancelled / Sold events.
-		topics := [][]common.Hash{
-			{
-				s.abi.Events["Listed"].ID,
-				s.abi.Events["Cancelled"].ID,
-				s.abi.Events["Sold"].ID,
-			},
-		}
+// BackfillProgress describes how far a Backfill run has advanced.
+type BackfillProgress struct {
+	From    uint64 // first block of the requested range
+	To      uint64 // last block of the requested range
+	Current uint64 // last fully processed block
+	Logs    int    // marketplace logs handled so far in this run
+}
 
-		query := ethereum.FilterQuery{
-			FromBlock: big.NewInt(int64(from)),
-			ToBlock:   big.NewInt(int64(to)),
-			Addresses: []common.Address{s.contract},
-			Topics:    topics,
+// Backfill replays marketplace events in the historical range [from, to]
+// (to = 0 means the newest confirmed block) and applies them to the orders table.
+// Progress is saved in scanner_backfills after every batch, keyed by chain,
+// contract and from block, so running Backfill again with the same from
+// block resumes where an interrupted run stopped. progress may be nil.
+func (s *MarketplaceScanner) Backfill(ctx context.Context, from, to uint64, progress func(BackfillProgress)) error {
+	if to == 0 {
+		confirmed, _, err := s.confirmedHead(ctx)
+		if err != nil {
+			return err
 		}
+		to = confirmed
+	}
+	if from > to {
+		return fmt.Errorf("invalid backfill range %d-%d", from, to)
+	}
 
-		logs, err := s.client.FilterLogs(ctx, query)
-		if err != nil {
-			limitErr := strings.Contains(err.Error(), "limit exceeded")
-			if limitErr {
-				// Throttle logging for noisy RPC limit errors.
-				now := time.Now()
-				if now.Sub(s.lastLimitLog) > 5*time.Second {
-					s.logger.Printf("marketplace scanner: reconcile FilterLogs limit exceeded (from %d to %d)", from, to)
-					s.lastLimitLog = now
-				}
-
-				// If RPC complains about limits, reduce batch size and retry from the same block.
-				if batchSize > 1 {
-					batchSize = batchSize / 2
-					if batchSize < 1 {
-						batchSize = 1
-					}
-					continue
-				}
-				// Already at batch size 1 and still hitting limits: skip this block range.
-				now = time.Now()
-				if now.Sub(s.lastLimitLog) > 5*time.Second {
-					s.logger.Printf("marketplace scanner: reconcile skipping block range %d-%d due to persistent RPC limit errors", from, to)
-					s.lastLimitLog = now
-				}
-				from = to + 1
-				continue
+	start := from
+	if s.checkpoints != nil {
+		last, err := s.checkpoints.GetBackfill(ctx, s.chainID, s.contract.Hex(), from)
+		switch {
+		case err == nil:
+			if last >= to {
+				s.logger.Printf("marketplace scanner: backfill %d-%d already completed up to block %d", from, to, last)
+				return nil
 			}
-
-			// Other errors: log once and abort this reconciliation pass; caller can retry later.
-			s.logger.Printf("marketplace scanner: reconcile FilterLogs error (from %d to %d): %v", from, to, err)
+			start = last + 1
+			s.logger.Printf("marketplace scanner: resuming backfill %d-%d from block %d", from, to, start)
+		case err != sql.ErrNoRows:
 			return err
 		}
+	}
 
-		for _, lg := range logs {
-			if err := s.handleLog(ctx, lg); err != nil {
-				s.logger.Printf("marketplace scanner: reconcile handleLog error: %v", err)
+	handled := 0
+	return s.scanRange(ctx, "backfill", start, to, func(batchTo uint64, logs int) {
+		handled += logs
+		if s.checkpoints != nil {
+			saveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if err := s.checkpoints.SaveBackfill(saveCtx, s.chainID, s.contract.Hex(), from, to, batchTo); err != nil {
+				s.logger.Printf("marketplace scanner: save backfill progress %d error: %v", batchTo, err)
 			}
+			cancel()
 		}
+		if progress != nil {
+			progress(BackfillProgress{From: from, To: to, Current: batchTo, Logs: handled})
+		}
+	})
+}
 
-		from = to + 1
-	}
+// scanRange fetches Listed / Cancelled / Sold logs in [from, to] in small
+// batches and applies them to the orders table. See logBatcher.scan.
+func (s *MarketplaceScanner) scanRange(ctx context.Context, label string, from, to uint64, done func(batchTo uint64, logs int)) error {
+	return s.batcher.scan(ctx, label, []common.Address{s.contract}, s.eventTopics(), from, to, s.handleLog, done)
+}
 
-	return nil
+// eventTopics filters logs down to the events the scanner cares about.
+func (s *MarketplaceScanner) eventTopics() [][]common.Hash {
+	// Only care about Listed / Cancelled / Sold events.
+	return [][]common.Hash{
+		{
+			s.abi.Events["Listed"].ID,
+			s.abi.Events["Cancelled"].ID,
+			s.abi.Events["Sold"].ID,
+		},
+	}
 }
 
 func (s *MarketplaceScanner) handleLog(ctx context.Context, lg types.Log) error {
@@ -259,6 +625,20 @@ func (s *MarketplaceScanner) handleLog(ctx context.Context, lg types.Log) error
 		return nil
 	}
 
+	// A removed log means its block (and everything after it) was reorged
+	// away: undo what those blocks changed and re-scan from the block before.
+	if lg.Removed {
+		if s.reorgs == nil || lg.BlockNumber == 0 {
+			return nil
+		}
+		ancestor := lg.BlockNumber - 1
+		if err := s.rollbackTo(ctx, ancestor); err != nil {
+			return err
+		}
+		s.requestRewind(ancestor)
+		return nil
+	}
+
 	sig := lg.Topics[0]
 	switch sig {
 	case s.abi.Events["Listed"].ID:
@@ -274,70 +654,88 @@ func (s *MarketplaceScanner) handleLog(ctx context.Context, lg types.Log) error
 }
 
 func (s *MarketplaceScanner) handleListed(ctx context.Context, lg types.Log) error {
-	// Indexed topics: [0] event sig, [1] listingId, [2] seller, [3] nft
-	if len(lg.Topics) < 4 {
-		return nil
+	order, err := s.listedOrder(lg)
+	if err != nil || order == nil {
+		return err
 	}
 
-	listingID := lg.Topics[1].Big()
-	seller := common.HexToAddress(lg.Topics[2].Hex())
-	nft := common.HexToAddress(lg.Topics[3].Hex())
-
-	// Non-indexed fields: tokenId, amount, price
-	var data struct {
-		TokenId *big.Int
-		Amount  *big.Int
-		Price   *big.Int
-	}
-	if err := s.abi.UnpackIntoInterface(&data, "Listed", lg.Data); err != nil {
+	payload, err := json.Marshal(map[string]any{
+		"seller":      order.Seller,
+		"nft_address": order.NFTAddress,
+		"token_id":    order.TokenID,
+		"amount":      order.Amount,
+		"price":       order.Price,
+	})
+	if err != nil {
 		return err
 	}
+	event := &store.OrderEvent{Type: store.OrderEventListed, Actor: order.Seller, Payload: payload}
+
+	return s.applyOrderEvent(ctx, lg, order.ListingID, event, func(_ *store.Order, at *store.ChainEvent) *store.Order {
+		order.Listed = at
+		return order
+	})
+}
 
-	order := &store.Order{
-		ListingID:  listingID.Int64(),
-		Seller:     seller.Hex(),
-		Buyer:      "",
-		NFTName:    "",
-		NFTAddress: nft.Hex(),
-		TokenID:    data.TokenId.Int64(),
-		Amount:     data.Amount.Int64(),
-		Price:      data.Price.String(), // wei string
-		Status:     store.OrderStatusListed,
-		TxHash:     lg.TxHash.Hex(),
-		Deleted:    0,
+// listedOrder decodes a Listed log into the order row it describes.
+// It returns nil when the log does not carry the expected topics.
+func (s *MarketplaceScanner) listedOrder(lg types.Log) (*store.Order, error) {
+	// Indexed topics: [0] event sig, [1] listingId, [2] seller, [3] nft
+	if len(lg.Topics) < 4 {
+		return nil, nil
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	ev, err := s.events.ParseListed(lg)
+	if err != nil {
+		return nil, err
+	}
 
-	return s.orderStore.Upsert(ctx, order)
+	return &store.Order{
+		ChainID:     s.chainID,
+		Marketplace: s.contract.Hex(),
+		ListingID:   ev.ListingId.String(),
+		Seller:      ev.Seller.Hex(),
+		Buyer:       "",
+		NFTName:     "",
+		NFTAddress:  ev.Nft.Hex(),
+		TokenID:     ev.TokenId.String(),
+		Amount:      ev.Amount.String(),
+		Price:       ev.Price.String(), // wei string
+		Status:      store.OrderStatusListed,
+		TxHash:      lg.TxHash.Hex(),
+		Deleted:     0,
+	}, nil
 }
 
 func (s *MarketplaceScanner) handleCancelled(ctx context.Context, lg types.Log) error {
 	if len(lg.Topics) < 2 {
 		return nil
 	}
-	listingID := lg.Topics[1].Big().Int64()
-
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	// Try to load existing order; if not found, create a minimal one.
-	existing, err := s.orderStore.GetByID(ctx, listingID)
+	ev, err := s.events.ParseCancelled(lg)
 	if err != nil {
-		// If no row, create a new placeholder.
-		order := &store.Order{
-			ListingID: listingID,
-			Status:    store.OrderStatusCanceled,
-			TxHash:    lg.TxHash.Hex(),
-			Deleted:   0,
-		}
-		return s.orderStore.Upsert(ctx, order)
+		return err
 	}
-
-	existing.Status = store.OrderStatusCanceled
-	existing.TxHash = lg.TxHash.Hex()
-	return s.orderStore.Upsert(ctx, existing)
+	listingID := ev.ListingId.String()
+	event := &store.OrderEvent{Type: store.OrderEventCancelled}
+
+	return s.applyOrderEvent(ctx, lg, listingID, event, func(existing *store.Order, at *store.ChainEvent) *store.Order {
+		if existing == nil {
+			// If no row, create a new placeholder.
+			return &store.Order{
+				ChainID:     s.chainID,
+				Marketplace: s.contract.Hex(),
+				ListingID:   listingID,
+				Status:      store.OrderStatusCanceled,
+				TxHash:      lg.TxHash.Hex(),
+				Deleted:     0,
+				Canceled:    at,
+			}
+		}
+		existing.Status = store.OrderStatusCanceled
+		existing.TxHash = lg.TxHash.Hex()
+		existing.Canceled = at
+		return existing
+	})
 }
 
 func (s *MarketplaceScanner) handleSold(ctx context.Context, lg types.Log) error {
@@ -345,28 +743,145 @@ func (s *MarketplaceScanner) handleSold(ctx context.Context, lg types.Log) error
 		return nil
 	}
 
-	listingID := lg.Topics[1].Big().Int64()
-	buyer := common.HexToAddress(lg.Topics[2].Hex())
+	ev, err := s.events.ParseSold(lg)
+	if err != nil {
+		return err
+	}
+	listingID := ev.ListingId.String()
+	buyer := ev.Buyer
+	payload, err := json.Marshal(map[string]any{"buyer": buyer.Hex()})
+	if err != nil {
+		return err
+	}
+	event := &store.OrderEvent{Type: store.OrderEventSold, Actor: buyer.Hex(), Payload: payload}
+
+	return s.applyOrderEvent(ctx, lg, listingID, event, func(existing *store.Order, at *store.ChainEvent) *store.Order {
+		if existing == nil {
+			// If no row yet, create a minimal record.
+			return &store.Order{
+				ChainID:     s.chainID,
+				Marketplace: s.contract.Hex(),
+				ListingID:   listingID,
+				Buyer:       buyer.Hex(),
+				Status:      store.OrderStatusSuccess,
+				TxHash:      lg.TxHash.Hex(),
+				Deleted:     0,
+				Sold:        at,
+			}
+		}
+		existing.Buyer = buyer.Hex()
+		existing.Status = store.OrderStatusSuccess
+		existing.TxHash = lg.TxHash.Hex()
+		existing.Sold = at
+		return existing
+	})
+}
 
+// applyOrderEvent loads the order for listingID (nil if it does not exist),
+// lets apply compute the new row and upserts it. Within the same transaction
+// it snapshots the previous row into order_undo_log, so the change can be
+// rolled back if the log's block is later reorged away, and appends event
+// (type, actor and payload filled in by the caller) to order_events.
+//
+// Events are applied strictly in chain order: a log at or before the last
+// position applied to the order is skipped, so re-scans and late logs never
+// move an order back. apply receives the log's position and block time.
+func (s *MarketplaceScanner) applyOrderEvent(ctx context.Context, lg types.Log, listingID string, event *store.OrderEvent, apply func(existing *store.Order, at *store.ChainEvent) *store.Order) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	existing, err := s.orderStore.GetByID(ctx, listingID)
+	at, err := s.chainEvent(ctx, lg)
 	if err != nil {
-		// If no row yet, create a minimal record.
-		order := &store.Order{
-			ListingID: listingID,
-			Buyer:     buyer.Hex(),
-			Status:    store.OrderStatusSuccess,
-			TxHash:    lg.TxHash.Hex(),
-			Deleted:   0,
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				s.logger.Printf("marketplace scanner: rollback tx error: %v", err)
+			}
 		}
-		return s.orderStore.Upsert(ctx, order)
+	}()
+
+	existing, err := s.orderStore.GetByIDForUpdateTx(ctx, tx, s.chainID, s.contract.Hex(), listingID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return err
+		}
+		existing = nil
+	}
+	if existing != nil && !isAfter(lg.BlockNumber, lg.Index, existing.BlockNumber, existing.LogIndex) {
+		return nil
 	}
 
-	existing.Buyer = buyer.Hex()
-	existing.Status = store.OrderStatusSuccess
-	existing.TxHash = lg.TxHash.Hex()
+	if s.reorgs != nil {
+		if err := s.reorgs.SaveOrderUndoTx(ctx, tx, s.chainID, s.contract.Hex(), lg.BlockNumber, lg.BlockHash.Hex(), listingID, existing); err != nil {
+			return err
+		}
+	}
+
+	order := apply(existing, at)
+	order.BlockNumber = lg.BlockNumber
+	order.LogIndex = lg.Index
+	if err := s.orderStore.UpsertTx(ctx, tx, order); err != nil {
+		return err
+	}
 
-	return s.orderStore.Upsert(ctx, existing)
+	event.ChainID = s.chainID
+	event.Marketplace = s.contract.Hex()
+	event.ListingID = listingID
+	event.Source = store.OrderEventSourceChain
+	event.Status = order.Status
+	event.TxHash = at.TxHash
+	event.Chain = at
+	if event.Actor == "" {
+		// Only the seller can cancel a listing.
+		event.Actor = order.Seller
+	}
+	if err := s.orderStore.AppendEventTx(ctx, tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// chainEvent returns the on-chain position of lg together with its block
+// timestamp. Timestamps are cached by block hash, since the logs of a batch
+// usually share a few blocks.
+func (s *MarketplaceScanner) chainEvent(ctx context.Context, lg types.Log) (*store.ChainEvent, error) {
+	s.mu.Lock()
+	blockTime, ok := s.blockTimes[lg.BlockHash]
+	s.mu.Unlock()
+
+	if !ok {
+		header, err := s.client.HeaderByHash(ctx, lg.BlockHash)
+		if err != nil {
+			return nil, fmt.Errorf("get header of block %d: %w", lg.BlockNumber, err)
+		}
+		blockTime = time.Unix(int64(header.Time), 0).UTC()
+
+		s.mu.Lock()
+		if len(s.blockTimes) >= blockTimeCacheSize {
+			s.blockTimes = make(map[common.Hash]time.Time)
+		}
+		s.blockTimes[lg.BlockHash] = blockTime
+		s.mu.Unlock()
+	}
+
+	return &store.ChainEvent{
+		TxHash:      lg.TxHash.Hex(),
+		BlockNumber: lg.BlockNumber,
+		LogIndex:    lg.Index,
+		BlockHash:   lg.BlockHash.Hex(),
+		BlockTime:   blockTime,
+	}, nil
 }
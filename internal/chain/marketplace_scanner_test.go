@@ -0,0 +1,32 @@
+package chain
+
+import "testing"
+
+func TestMarketplaceScannerRewind(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []uint64
+		want     uint64
+		ok       bool
+	}{
+		{"none", nil, 0, false},
+		{"single", []uint64{120}, 120, true},
+		{"lowest wins", []uint64{120, 100, 110}, 100, true},
+		{"block zero", []uint64{5, 0}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &MarketplaceScanner{}
+			for _, b := range tt.requests {
+				s.requestRewind(b)
+			}
+			got, ok := s.takeRewind()
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("takeRewind() = %d, %v, want %d, %v", got, ok, tt.want, tt.ok)
+			}
+			if _, ok := s.takeRewind(); ok {
+				t.Fatal("second takeRewind() still returned a rewind")
+			}
+		})
+	}
+}
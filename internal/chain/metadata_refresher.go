@@ -0,0 +1,156 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/nft_market_go/internal/store"
+)
+
+// MetadataFetcher downloads the metadata document behind a token URI.
+// It returns nil (and no error) when the URI does not point to JSON.
+type MetadataFetcher interface {
+	FetchMetadata(ctx context.Context, uri string) ([]byte, error)
+}
+
+// A failed metadata fetch is retried after metadataRetryBase, doubled per
+// failure up to metadataRetryMax, and given up after metadataMaxAttempts
+// failures until the token's URI changes.
+const (
+	metadataRetryBase   = time.Minute
+	metadataRetryMax    = 24 * time.Hour
+	metadataMaxAttempts = 10
+)
+
+// MetadataRefresher copies indexed token URIs onto nft_assets and fetches the
+// metadata they point to. It runs periodically and whenever Trigger is called
+// (the ERC1155 scanner triggers it after a URI event).
+type MetadataRefresher struct {
+	uris     *store.TokenURIStore
+	assets   *store.NftAssetStore
+	fetcher  MetadataFetcher
+	logger   *log.Logger
+	interval time.Duration
+	trigger  chan struct{}
+}
+
+// NewMetadataRefresher creates a MetadataRefresher.
+func NewMetadataRefresher(uris *store.TokenURIStore, assets *store.NftAssetStore, fetcher MetadataFetcher, logger *log.Logger) *MetadataRefresher {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &MetadataRefresher{
+		uris:     uris,
+		assets:   assets,
+		fetcher:  fetcher,
+		logger:   logger,
+		interval: 1 * time.Minute,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Trigger asks the refresher to run as soon as possible. It never blocks.
+func (r *MetadataRefresher) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts the refresh loop. It should be run in its own goroutine.
+func (r *MetadataRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			r.logger.Printf("metadata refresher: context canceled, stopping")
+			return
+		case <-ticker.C:
+		case <-r.trigger:
+		}
+	}
+}
+
+func (r *MetadataRefresher) refresh(ctx context.Context) {
+	listCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	stale, err := r.uris.ListStaleAssets(listCtx, 50, metadataMaxAttempts)
+	cancel()
+	if err != nil {
+		r.logger.Printf("metadata refresher: list stale assets error: %v", err)
+		return
+	}
+
+	for _, a := range stale {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Failed fetches leave the asset stale; it is retried with backoff.
+		fetchCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		metadata, err := r.fetcher.FetchMetadata(fetchCtx, expandTokenID(a.URI, a.TokenID))
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.recordFailure(ctx, a, err)
+			continue
+		}
+
+		updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = r.assets.UpdateMetadata(updateCtx, a.AssetID, a.URI, metadata)
+		cancel()
+		if err != nil {
+			r.logger.Printf("metadata refresher: update asset %d error: %v", a.AssetID, err)
+		}
+	}
+}
+
+// recordFailure records a failed fetch of a's URI, so it is retried later
+// instead of on every run.
+func (r *MetadataRefresher) recordFailure(ctx context.Context, a *store.StaleAssetURI, fetchErr error) {
+	attempts := a.Attempts + 1
+	retry := metadataBackoff(attempts)
+	if attempts >= metadataMaxAttempts {
+		r.logger.Printf("metadata refresher: fetch %s for asset %d error, giving up after %d attempts: %v", a.URI, a.AssetID, attempts, fetchErr)
+	} else {
+		r.logger.Printf("metadata refresher: fetch %s for asset %d error, retrying in %s: %v", a.URI, a.AssetID, retry, fetchErr)
+	}
+
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := r.assets.RecordMetadataFailure(updateCtx, a.AssetID, a.URI, attempts, retry, fetchErr.Error()); err != nil {
+		r.logger.Printf("metadata refresher: update asset %d error: %v", a.AssetID, err)
+	}
+}
+
+// metadataBackoff returns the delay before retrying a fetch that failed
+// attempts times in a row.
+func metadataBackoff(attempts int) time.Duration {
+	d := metadataRetryBase
+	for i := 1; i < attempts && d < metadataRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, metadataRetryMax)
+}
+
+// expandTokenID substitutes the ERC1155 {id} placeholder with the token ID
+// (decimal string) as 64 lowercase hex characters, as required by the standard.
+func expandTokenID(uri string, tokenID string) string {
+	if !strings.Contains(uri, "{id}") {
+		return uri
+	}
+	id, ok := new(big.Int).SetString(tokenID, 10)
+	if !ok {
+		return uri
+	}
+	return strings.ReplaceAll(uri, "{id}", fmt.Sprintf("%064x", id))
+}
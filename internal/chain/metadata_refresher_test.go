@@ -0,0 +1,49 @@
+package chain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandTokenID(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		tokenID string
+		want    string
+	}{
+		{"no placeholder", "ipfs://Qm/1.json", "1", "ipfs://Qm/1.json"},
+		{"zero", "https://x.io/{id}.json", "0", "https://x.io/0000000000000000000000000000000000000000000000000000000000000000.json"},
+		{"hex lowercase", "https://x.io/{id}.json", "314592", "https://x.io/000000000000000000000000000000000000000000000000000000000004cce0.json"},
+		{"max uint256", "{id}", "115792089237316195423570985008687907853269984665640564039457584007913129639935", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{"every placeholder", "https://x.io/{id}/{id}", "1", "https://x.io/0000000000000000000000000000000000000000000000000000000000000001/0000000000000000000000000000000000000000000000000000000000000001"},
+		{"invalid token ID", "https://x.io/{id}.json", "0x1", "https://x.io/{id}.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandTokenID(tt.uri, tt.tokenID); got != tt.want {
+				t.Errorf("expandTokenID(%q, %q) = %q, want %q", tt.uri, tt.tokenID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{11, 1024 * time.Minute},
+		{12, 24 * time.Hour},
+		{metadataMaxAttempts, 512 * time.Minute},
+		{100, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := metadataBackoff(tt.attempts); got != tt.want {
+			t.Errorf("metadataBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
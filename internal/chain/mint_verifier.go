@@ -0,0 +1,122 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/contracts"
+)
+
+// MintedToken is a token minted by a transaction, decoded from its receipt.
+type MintedToken struct {
+	NFTAddress common.Address
+	TokenID    *big.Int
+	Amount     *big.Int // always 1 for ERC721
+}
+
+// MintVerifier derives the tokens a mint transaction created from its
+// receipt, so mint-info callbacks cannot attach arbitrary tokens to an asset.
+type MintVerifier struct {
+	client         ChainClient
+	transfer       common.Hash // ERC721 Transfer
+	transferSingle common.Hash // ERC1155 TransferSingle
+}
+
+// NewMintVerifier creates a verifier reading receipts through client.
+func NewMintVerifier(client ChainClient) (*MintVerifier, error) {
+	nftABI, err := contracts.ProjectNFTABI()
+	if err != nil {
+		return nil, err
+	}
+	abi1155, err := contracts.Project1155ABI()
+	if err != nil {
+		return nil, err
+	}
+	return &MintVerifier{
+		client:         client,
+		transfer:       nftABI.Events["Transfer"].ID,
+		transferSingle: abi1155.Events["TransferSingle"].ID,
+	}, nil
+}
+
+// Minted returns the tokens minted to owner by transaction txHash on any of
+// the nfts contracts: ERC721 Transfer and ERC1155 TransferSingle logs from the
+// zero address. Like OrderVerifier.Verify it returns an error wrapping
+// ErrClaimUnverified when the receipt is not available yet and one wrapping
+// ErrClaimMismatch when the transaction reverted.
+func (v *MintVerifier) Minted(ctx context.Context, txHash common.Hash, owner common.Address, nfts []common.Address) ([]MintedToken, error) {
+	receipt, err := v.client.TransactionReceipt(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, fmt.Errorf("%w: transaction %s not mined", ErrClaimUnverified, txHash.Hex())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: get receipt: %v", ErrClaimUnverified, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("%w: transaction %s reverted", ErrClaimMismatch, txHash.Hex())
+	}
+
+	var out []MintedToken
+	for _, lg := range receipt.Logs {
+		if len(lg.Topics) == 0 || !ContainsAddress(nfts, lg.Address) {
+			continue
+		}
+		minted, err := v.decodeMint(*lg, owner)
+		if err != nil {
+			return nil, err
+		}
+		if minted != nil {
+			out = append(out, *minted)
+		}
+	}
+	return out, nil
+}
+
+// decodeMint returns the token lg mints to owner, or nil when lg is not such a mint.
+func (v *MintVerifier) decodeMint(lg types.Log, owner common.Address) (*MintedToken, error) {
+	switch {
+	case lg.Topics[0] == v.transfer && len(lg.Topics) == 4:
+		events, err := contracts.NewProjectNFTFilterer(lg.Address, v.client)
+		if err != nil {
+			return nil, err
+		}
+		ev, err := events.ParseTransfer(lg)
+		if err != nil {
+			return nil, err
+		}
+		if ev.From != (common.Address{}) || ev.To != owner {
+			return nil, nil
+		}
+		return &MintedToken{NFTAddress: lg.Address, TokenID: ev.TokenId, Amount: big.NewInt(1)}, nil
+	case lg.Topics[0] == v.transferSingle:
+		events, err := contracts.NewProject1155Filterer(lg.Address, v.client)
+		if err != nil {
+			return nil, err
+		}
+		ev, err := events.ParseTransferSingle(lg)
+		if err != nil {
+			return nil, err
+		}
+		if ev.From != (common.Address{}) || ev.To != owner {
+			return nil, nil
+		}
+		return &MintedToken{NFTAddress: lg.Address, TokenID: ev.Id, Amount: ev.Value}, nil
+	}
+	return nil, nil
+}
+
+// ContainsAddress reports whether addr is in list.
+func ContainsAddress(list []common.Address, addr common.Address) bool {
+	for _, a := range list {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
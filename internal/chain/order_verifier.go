@@ -0,0 +1,178 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/contracts"
+	"github.com/nft_market_go/internal/store"
+)
+
+var (
+	// ErrClaimMismatch is returned when the chain contradicts a callback.
+	ErrClaimMismatch = errors.New("callback does not match chain state")
+	// ErrClaimUnverified is returned when the chain does not show the claimed
+	// change yet (transaction not mined, node unreachable, ...). The callback
+	// may become verifiable later.
+	ErrClaimUnverified = errors.New("callback cannot be verified yet")
+)
+
+// OrderClaim is what a frontend callback says happened to a listing.
+type OrderClaim struct {
+	Event       string // store.OrderEventListed, OrderEventCancelled or OrderEventSold
+	Marketplace common.Address
+	ListingID   *big.Int
+	TxHash      common.Hash // zero when the client did not send one
+
+	// Listed
+	Seller     common.Address
+	NFTAddress common.Address
+	TokenID    *big.Int
+	Amount     *big.Int
+	Price      *big.Int
+
+	// Sold
+	Buyer common.Address
+
+	// Position of the matching log, set by Verify when it checked the
+	// transaction receipt; zero otherwise.
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// OrderVerifier checks frontend order callbacks against the chain before the
+// backend trusts them.
+//
+// With a transaction hash the claim must match a Listed / Cancelled / Sold
+// log of the marketplace in that transaction's receipt. Without one the
+// listing is read through listings(listingId); that is enough for Listed
+// only: a sold listing is inactive just like a cancelled one, and the buyer
+// of a sale is not recorded in it.
+type OrderVerifier struct {
+	client ChainClient
+	abi    abi.ABI
+}
+
+// NewOrderVerifier creates a verifier reading the chain through client.
+func NewOrderVerifier(client ChainClient) (*OrderVerifier, error) {
+	parsedABI, err := contracts.NFTMarketplaceABI()
+	if err != nil {
+		return nil, err
+	}
+	return &OrderVerifier{client: client, abi: parsedABI}, nil
+}
+
+// Verify returns nil when the chain confirms c, an error wrapping
+// ErrClaimMismatch when it contradicts c, and an error wrapping
+// ErrClaimUnverified when it cannot tell yet.
+func (v *OrderVerifier) Verify(ctx context.Context, c *OrderClaim) error {
+	if c.TxHash != (common.Hash{}) {
+		return v.verifyReceipt(ctx, c)
+	}
+	return v.verifyListing(ctx, c)
+}
+
+func (v *OrderVerifier) verifyReceipt(ctx context.Context, c *OrderClaim) error {
+	receipt, err := v.client.TransactionReceipt(ctx, c.TxHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("%w: transaction %s not mined", ErrClaimUnverified, c.TxHash.Hex())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: get receipt: %v", ErrClaimUnverified, err)
+	}
+	return v.matchReceipt(receipt, c)
+}
+
+// matchReceipt checks c against receipt, the mined receipt of c.TxHash: the
+// transaction must have succeeded and emitted the claimed event for the
+// listing, with matching fields.
+func (v *OrderVerifier) matchReceipt(receipt *types.Receipt, c *OrderClaim) error {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("%w: transaction %s reverted", ErrClaimMismatch, c.TxHash.Hex())
+	}
+
+	events, err := contracts.NewNFTMarketplaceFilterer(c.Marketplace, v.client)
+	if err != nil {
+		return err
+	}
+	eventID := v.abi.Events[c.Event].ID
+	for _, lg := range receipt.Logs {
+		if lg.Address != c.Marketplace || len(lg.Topics) < 2 || lg.Topics[0] != eventID {
+			continue
+		}
+		if lg.Topics[1].Big().Cmp(c.ListingID) != 0 {
+			continue
+		}
+		if err := v.matchLog(events, *lg, c); err != nil {
+			return err
+		}
+		c.BlockNumber, c.LogIndex = lg.BlockNumber, lg.Index
+		return nil
+	}
+	return fmt.Errorf("%w: transaction %s has no %s event for listing %d", ErrClaimMismatch, c.TxHash.Hex(), c.Event, c.ListingID)
+}
+
+// matchLog compares the decoded event lg with c.
+func (v *OrderVerifier) matchLog(events *contracts.NFTMarketplaceFilterer, lg types.Log, c *OrderClaim) error {
+	switch c.Event {
+	case store.OrderEventListed:
+		ev, err := events.ParseListed(lg)
+		if err != nil {
+			return err
+		}
+		return c.matchListing(ev.Seller, ev.Nft, ev.TokenId, ev.Amount, ev.Price)
+	case store.OrderEventSold:
+		ev, err := events.ParseSold(lg)
+		if err != nil {
+			return err
+		}
+		if ev.Buyer != c.Buyer {
+			return fmt.Errorf("%w: buyer is %s", ErrClaimMismatch, ev.Buyer.Hex())
+		}
+	}
+	return nil
+}
+
+func (v *OrderVerifier) verifyListing(ctx context.Context, c *OrderClaim) error {
+	caller, err := contracts.NewNFTMarketplaceCaller(c.Marketplace, v.client)
+	if err != nil {
+		return err
+	}
+	listing, err := caller.Listings(&bind.CallOpts{Context: ctx}, c.ListingID)
+	if err != nil {
+		return fmt.Errorf("%w: call listings: %v", ErrClaimUnverified, err)
+	}
+	if listing.Seller == (common.Address{}) {
+		return fmt.Errorf("%w: listing %d does not exist yet", ErrClaimUnverified, c.ListingID)
+	}
+
+	if c.Event != store.OrderEventListed {
+		return fmt.Errorf("%w: tx_hash is required to verify a %s", ErrClaimUnverified, c.Event)
+	}
+	return c.matchListing(listing.Seller, listing.Nft, listing.TokenId, listing.Amount, listing.Price)
+}
+
+// matchListing compares the listing fields reported by the chain with c.
+func (c *OrderClaim) matchListing(seller, nft common.Address, tokenID, amount, price *big.Int) error {
+	switch {
+	case seller != c.Seller:
+		return fmt.Errorf("%w: seller is %s", ErrClaimMismatch, seller.Hex())
+	case nft != c.NFTAddress:
+		return fmt.Errorf("%w: nft_address is %s", ErrClaimMismatch, nft.Hex())
+	case c.TokenID == nil || tokenID.Cmp(c.TokenID) != 0:
+		return fmt.Errorf("%w: token_id is %s", ErrClaimMismatch, tokenID)
+	case c.Amount == nil || amount.Cmp(c.Amount) != 0:
+		return fmt.Errorf("%w: amount is %s", ErrClaimMismatch, amount)
+	case c.Price == nil || price.Cmp(c.Price) != 0:
+		return fmt.Errorf("%w: price is %s", ErrClaimMismatch, price)
+	}
+	return nil
+}
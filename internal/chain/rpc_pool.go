@@ -0,0 +1,429 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ChainClient is the subset of the go-ethereum client API used by the
+// scanners and read APIs. It is implemented by *ethclient.Client and RPCPool.
+type ChainClient interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+// ErrNoWebsocketEndpoint is returned by the subscription methods of RPCPool
+// when none of its endpoints is a ws:// or wss:// URL.
+var ErrNoWebsocketEndpoint = errors.New("no websocket rpc endpoint configured")
+
+const (
+	// healthDecay is the weight of the newest sample in the moving averages.
+	healthDecay = 0.2
+	// failuresBeforeCooldown consecutive failures put an endpoint on cooldown.
+	failuresBeforeCooldown = 3
+	rpcCooldown            = 30 * time.Second
+)
+
+// RPCPool spreads calls over several RPC endpoints. Each endpoint keeps a
+// moving average of its latency and error rate; calls go to the healthiest
+// endpoint first and fail over to the next one on connection errors, rate
+// limits (429) and other node-side failures. Endpoints that fail repeatedly
+// are put on a short cooldown and only used as a last resort.
+//
+// Answers such as "not found", a reverted call or a FilterLogs range limit
+// ("limit exceeded") are returned as-is and do not count against the
+// endpoint: they would be the same on every node, and the caller reacts to
+// a range limit by querying fewer blocks (see logBatcher).
+type RPCPool struct {
+	endpoints []*rpcEndpoint
+	logger    *log.Logger
+
+	mu       sync.Mutex
+	pinnedWS *rpcEndpoint // endpoint used for subscriptions, see subscriptionEndpoint
+}
+
+type rpcEndpoint struct {
+	url       string
+	client    *ethclient.Client
+	websocket bool
+
+	// Guarded by RPCPool.mu.
+	latency       float64 // moving average, milliseconds
+	errorRate     float64 // moving average, 0..1
+	failures      int     // consecutive failures
+	cooldownUntil time.Time
+	calls         uint64
+	errors        uint64
+}
+
+// RPCEndpointStatus is a snapshot of the health of one endpoint.
+type RPCEndpointStatus struct {
+	URL                 string  `json:"url"`
+	LatencyMS           float64 `json:"latency_ms"`
+	ErrorRate           float64 `json:"error_rate"`
+	Calls               uint64  `json:"calls"`
+	Errors              uint64  `json:"errors"`
+	CoolingDown         bool    `json:"cooling_down"`
+	Websocket           bool    `json:"websocket"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+}
+
+// DialRPCPool dials every URL and returns a pool over the endpoints that
+// could be dialed. It fails only when none of them could.
+func DialRPCPool(ctx context.Context, urls []string, logger *log.Logger) (*RPCPool, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	p := &RPCPool{logger: logger}
+	var lastErr error
+	for _, url := range urls {
+		client, err := ethclient.DialContext(ctx, url)
+		if err != nil {
+			logger.Printf("rpc pool: dial %s error: %v", redactURL(url), err)
+			lastErr = err
+			continue
+		}
+		p.endpoints = append(p.endpoints, &rpcEndpoint{
+			url:       url,
+			client:    client,
+			websocket: isWebsocket(url),
+		})
+	}
+	if len(p.endpoints) == 0 {
+		if lastErr == nil {
+			lastErr = errors.New("no rpc url configured")
+		}
+		return nil, lastErr
+	}
+	return p, nil
+}
+
+// Close closes all endpoint clients.
+func (p *RPCPool) Close() {
+	for _, ep := range p.endpoints {
+		ep.client.Close()
+	}
+}
+
+// HasWebsocket reports whether at least one endpoint supports subscriptions.
+func (p *RPCPool) HasWebsocket() bool {
+	for _, ep := range p.endpoints {
+		if ep.websocket {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the health of every endpoint, healthiest first.
+func (p *RPCPool) Status() []RPCEndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	out := make([]RPCEndpointStatus, 0, len(p.endpoints))
+	for _, ep := range p.rankedLocked(now) {
+		out = append(out, RPCEndpointStatus{
+			URL:                 redactURL(ep.url),
+			LatencyMS:           ep.latency,
+			ErrorRate:           ep.errorRate,
+			Calls:               ep.calls,
+			Errors:              ep.errors,
+			CoolingDown:         now.Before(ep.cooldownUntil),
+			Websocket:           ep.websocket,
+			ConsecutiveFailures: ep.failures,
+		})
+	}
+	return out
+}
+
+// ChainID implements ChainClient.
+func (p *RPCPool) ChainID(ctx context.Context) (*big.Int, error) {
+	var out *big.Int
+	err := p.do(ctx, "ChainID", func(c *ethclient.Client) (err error) {
+		out, err = c.ChainID(ctx)
+		return err
+	})
+	return out, err
+}
+
+// HeaderByNumber implements ChainClient.
+func (p *RPCPool) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var out *types.Header
+	err := p.do(ctx, "HeaderByNumber", func(c *ethclient.Client) (err error) {
+		out, err = c.HeaderByNumber(ctx, number)
+		return err
+	})
+	return out, err
+}
+
+// HeaderByHash implements ChainClient.
+func (p *RPCPool) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	var out *types.Header
+	err := p.do(ctx, "HeaderByHash", func(c *ethclient.Client) (err error) {
+		out, err = c.HeaderByHash(ctx, hash)
+		return err
+	})
+	return out, err
+}
+
+// FilterLogs implements ChainClient.
+func (p *RPCPool) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var out []types.Log
+	err := p.do(ctx, "FilterLogs", func(c *ethclient.Client) (err error) {
+		out, err = c.FilterLogs(ctx, q)
+		return err
+	})
+	return out, err
+}
+
+// TransactionByHash implements ChainClient.
+func (p *RPCPool) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	var (
+		out     *types.Transaction
+		pending bool
+	)
+	err := p.do(ctx, "TransactionByHash", func(c *ethclient.Client) (err error) {
+		out, pending, err = c.TransactionByHash(ctx, hash)
+		return err
+	})
+	return out, pending, err
+}
+
+// TransactionReceipt implements ChainClient.
+func (p *RPCPool) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	var out *types.Receipt
+	err := p.do(ctx, "TransactionReceipt", func(c *ethclient.Client) (err error) {
+		out, err = c.TransactionReceipt(ctx, txHash)
+		return err
+	})
+	return out, err
+}
+
+// CallContract implements ChainClient.
+func (p *RPCPool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var out []byte
+	err := p.do(ctx, "CallContract", func(c *ethclient.Client) (err error) {
+		out, err = c.CallContract(ctx, msg, blockNumber)
+		return err
+	})
+	return out, err
+}
+
+// CodeAt implements ChainClient. Together with CallContract it lets the pool
+// back the read-only contract bindings.
+func (p *RPCPool) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	var out []byte
+	err := p.do(ctx, "CodeAt", func(c *ethclient.Client) (err error) {
+		out, err = c.CodeAt(ctx, account, blockNumber)
+		return err
+	})
+	return out, err
+}
+
+// SubscribeNewHead implements ChainClient. Subscriptions do not fail over by
+// themselves; see subscriptionEndpoint.
+func (p *RPCPool) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	ep, err := p.subscriptionEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	sub, err := ep.client.SubscribeNewHead(ctx, ch)
+	p.afterSubscribe(ep, err)
+	return sub, err
+}
+
+// SubscribeFilterLogs implements ChainClient.
+func (p *RPCPool) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	ep, err := p.subscriptionEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	sub, err := ep.client.SubscribeFilterLogs(ctx, q, ch)
+	p.afterSubscribe(ep, err)
+	return sub, err
+}
+
+// subscriptionEndpoint returns the websocket endpoint used for
+// subscriptions. It stays pinned until subscribing on it fails, so that the
+// head and log subscriptions of a scanner come from the same node.
+func (p *RPCPool) subscriptionEndpoint() (*rpcEndpoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pinnedWS != nil {
+		return p.pinnedWS, nil
+	}
+	for _, ep := range p.rankedLocked(time.Now()) {
+		if ep.websocket {
+			p.pinnedWS = ep
+			return ep, nil
+		}
+	}
+	return nil, ErrNoWebsocketEndpoint
+}
+
+func (p *RPCPool) afterSubscribe(ep *rpcEndpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		return
+	}
+	p.recordLocked(ep, 0, err)
+	if p.pinnedWS == ep {
+		p.pinnedWS = nil
+	}
+}
+
+// do runs call against the endpoints in order of health until one succeeds
+// or returns an error that another endpoint would return as well.
+func (p *RPCPool) do(ctx context.Context, method string, call func(*ethclient.Client) error) error {
+	p.mu.Lock()
+	ranked := p.rankedLocked(time.Now())
+	p.mu.Unlock()
+
+	var lastErr error
+	for i, ep := range ranked {
+		start := time.Now()
+		err := call(ep.client)
+
+		p.mu.Lock()
+		p.recordLocked(ep, time.Since(start), classify(err))
+		p.mu.Unlock()
+
+		if err == nil || !isFailoverError(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+		if i+1 < len(ranked) {
+			p.logger.Printf("rpc pool: %s on %s failed, trying next endpoint: %v", method, redactURL(ep.url), err)
+		}
+	}
+	return lastErr
+}
+
+// classify returns err if it should count against the endpoint's health.
+func classify(err error) error {
+	if err != nil && isFailoverError(err) {
+		return err
+	}
+	return nil
+}
+
+func (p *RPCPool) recordLocked(ep *rpcEndpoint, latency time.Duration, err error) {
+	ep.calls++
+	sample := 0.0
+	if err != nil {
+		sample = 1
+		ep.errors++
+		ep.failures++
+		if ep.failures >= failuresBeforeCooldown {
+			ep.cooldownUntil = time.Now().Add(rpcCooldown)
+		}
+	} else {
+		ep.failures = 0
+		ms := float64(latency) / float64(time.Millisecond)
+		if ep.latency == 0 {
+			ep.latency = ms
+		} else {
+			ep.latency = (1-healthDecay)*ep.latency + healthDecay*ms
+		}
+	}
+	ep.errorRate = (1-healthDecay)*ep.errorRate + healthDecay*sample
+}
+
+// rankedLocked returns the endpoints ordered by health: endpoints on
+// cooldown last, then by latency weighted with the error rate.
+func (p *RPCPool) rankedLocked(now time.Time) []*rpcEndpoint {
+	ranked := make([]*rpcEndpoint, len(p.endpoints))
+	copy(ranked, p.endpoints)
+
+	score := func(ep *rpcEndpoint) float64 {
+		// Untried endpoints get a neutral latency so they are tried in config order.
+		latency := ep.latency
+		if latency == 0 {
+			latency = 100
+		}
+		return latency * (1 + 10*ep.errorRate)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		ci, cj := now.Before(ranked[i].cooldownUntil), now.Before(ranked[j].cooldownUntil)
+		if ci != cj {
+			return !ci
+		}
+		return score(ranked[i]) < score(ranked[j])
+	})
+	return ranked
+}
+
+// isRangeLimitError reports whether err is a node refusing a FilterLogs query
+// because its block range or result set is too large. Rate limits are not
+// range limits.
+func isRangeLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests") || strings.Contains(msg, "429") {
+		return false
+	}
+	return strings.Contains(msg, "limit exceeded") ||
+		strings.Contains(msg, "query returned more than") ||
+		strings.Contains(msg, "block range")
+}
+
+// isFailoverError reports whether err is a failure of the endpoint (network,
+// rate limit, node error) rather than an answer every node would give.
+func isFailoverError(err error) bool {
+	if err == nil || errors.Is(err, ethereum.NotFound) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isRangeLimitError(err) {
+		return false
+	}
+	msg := err.Error()
+	return !strings.Contains(msg, "execution reverted")
+}
+
+func isWebsocket(url string) bool {
+	return strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://")
+}
+
+// redactURL strips the path and query of an RPC URL, which often carry API keys.
+func redactURL(url string) string {
+	scheme := ""
+	rest := url
+	if i := strings.Index(url, "://"); i >= 0 {
+		scheme, rest = url[:i+3], url[i+3:]
+	}
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		rest = rest[:i]
+	}
+	return fmt.Sprintf("%s%s", scheme, rest)
+}
@@ -0,0 +1,47 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+func TestIsFailoverError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		failover   bool
+		rangeLimit bool
+	}{
+		{"nil", nil, false, false},
+		{"not found", ethereum.NotFound, false, false},
+		{"wrapped not found", fmt.Errorf("receipt: %w", ethereum.NotFound), false, false},
+		{"canceled", context.Canceled, false, false},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), false, false},
+		{"reverted", errors.New("execution reverted: not owner"), false, false},
+		{"range limit", errors.New("query limit exceeded"), false, true},
+		{"result limit", errors.New("query returned more than 10000 results"), false, true},
+		{"block range", errors.New("exceed maximum block range: 5000"), false, true},
+		{"rate limit", errors.New("rate limit exceeded"), true, false},
+		{"429", errors.New("429 Too Many Requests: {}"), true, false},
+		{"5xx", errors.New("502 Bad Gateway: upstream"), true, false},
+		{"connection refused", errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"), true, false},
+		{"eof", errors.New("EOF"), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFailoverError(tt.err); got != tt.failover {
+				t.Errorf("isFailoverError(%v) = %v, want %v", tt.err, got, tt.failover)
+			}
+			if got := isRangeLimitError(tt.err); got != tt.rangeLimit {
+				t.Errorf("isRangeLimitError(%v) = %v, want %v", tt.err, got, tt.rangeLimit)
+			}
+			if got := classify(tt.err) != nil; got != tt.failover {
+				t.Errorf("classify(%v) counts against the endpoint = %v, want %v", tt.err, got, tt.failover)
+			}
+		})
+	}
+}
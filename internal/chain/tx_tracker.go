@@ -0,0 +1,395 @@
+package chain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/nft_market_go/internal/store"
+)
+
+// TxTracker follows buy / cancel transactions the frontend broadcast before
+// they were mined, and keeps the order status in line with their receipts:
+//
+//	LISTED --seen on chain--> LOCKED --mined--> SETTLING --confirmed--> SUCCESS (buy) / CANCELED (cancel)
+//	LOCKED / SETTLING --reverted, or not mined within the timeout--> LISTED (status before the lock)
+//
+// Tracked transactions are reported by unverified callbacks, so Track does
+// not touch the order. It is only locked once the node knows the transaction
+// (mempool or block), sent to the marketplace by the claimed buyer (or the
+// seller, for a cancel). A mined receipt only moves the order on once its
+// logs contain the claimed Sold / Cancelled event of the listing (and
+// buyer); a successful transaction without it fails like a reverted one. A
+// failed transaction gives the order back its status from before the lock,
+// as the listing is still active on chain. A receipt that disappears again
+// (reorg) moves the order back from SETTLING to LOCKED. Orders that left
+// these states in the meantime (e.g. the scanner already applied the Sold
+// event) are not touched. Tracked transactions are stored in pending_txs, so
+// tracking survives restarts.
+type TxTracker struct {
+	client        ChainClient
+	db            *sql.DB
+	chainID       int64
+	txs           *store.PendingTxStore
+	orders        *store.OrderStore
+	verifier      *OrderVerifier
+	logger        *log.Logger
+	pollInterval  time.Duration
+	timeout       time.Duration
+	confirmations uint64
+}
+
+// NewTxTracker creates a tracker for the transactions of one chain, matching
+// receipts with verifier.
+func NewTxTracker(client ChainClient, db *sql.DB, chainID int64, txs *store.PendingTxStore, orders *store.OrderStore, verifier *OrderVerifier, logger *log.Logger) *TxTracker {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &TxTracker{
+		client:       client,
+		db:           db,
+		chainID:      chainID,
+		txs:          txs,
+		orders:       orders,
+		verifier:     verifier,
+		logger:       logger,
+		pollInterval: 5 * time.Second,
+		timeout:      10 * time.Minute,
+	}
+}
+
+// SetConfirmations makes the tracker wait until a receipt's block is n blocks
+// deep before finalizing the order.
+func (t *TxTracker) SetConfirmations(n uint64) {
+	t.confirmations = n
+}
+
+// SetTimeout sets how long a transaction may stay unmined before it is
+// considered dropped.
+func (t *TxTracker) SetTimeout(d time.Duration) {
+	if d > 0 {
+		t.timeout = d
+	}
+}
+
+// Track starts following p (ChainID, TxHash, Marketplace, ListingID, Type
+// and Actor set). Its order is locked by a later poll, once the transaction
+// shows up on chain. Tracking the same transaction again is a no-op.
+func (t *TxTracker) Track(ctx context.Context, p *store.PendingTx) error {
+	p.ChainID = t.chainID
+	return t.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := t.txs.InsertTx(ctx, tx, p)
+		return err
+	})
+}
+
+// Run polls the receipts of tracked transactions until ctx is cancelled.
+func (t *TxTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := t.poll(ctx); err != nil {
+			t.logger.Printf("tx tracker: poll error: %v", err)
+		}
+	}
+}
+
+func (t *TxTracker) poll(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	open, err := t.txs.ListOpen(ctx, t.chainID, 100)
+	if err != nil || len(open) == 0 {
+		return err
+	}
+	header, err := t.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	head := header.Number.Uint64()
+
+	for _, p := range open {
+		if err := t.check(ctx, p, head); err != nil {
+			t.logger.Printf("tx tracker: check %s error: %v", p.TxHash, err)
+		}
+	}
+	return nil
+}
+
+// check fetches the receipt of p and advances it and its order.
+func (t *TxTracker) check(ctx context.Context, p *store.PendingTx, head uint64) error {
+	receipt, err := t.client.TransactionReceipt(ctx, common.HexToHash(p.TxHash))
+	if err != nil && !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+
+	switch {
+	case receipt == nil && p.Status == store.TxStatusMined:
+		// The block of the receipt was reorged away; wait for it again.
+		return t.advance(ctx, p, store.TxStatusPending, 0, "receipt disappeared after a reorg",
+			[]store.OrderStatus{store.OrderStatusSettling}, store.OrderStatusLocked, store.OrderEventTxSubmitted)
+	case receipt == nil:
+		if p.PrevStatus == "" {
+			if failed, err := t.lockBroadcast(ctx, p); failed || err != nil {
+				return err
+			}
+		}
+		if time.Since(p.CreatedAt) < t.timeout {
+			return nil
+		}
+		return t.fail(ctx, p, 0, "not mined within "+t.timeout.String())
+	}
+
+	block := receipt.BlockNumber.Uint64()
+	claim, err := pendingClaim(p)
+	if err != nil {
+		return t.fail(ctx, p, block, err.Error())
+	}
+	if err := t.verifier.matchReceipt(receipt, claim); err != nil {
+		if errors.Is(err, ErrClaimMismatch) {
+			return t.fail(ctx, p, block, err.Error())
+		}
+		// Decoding error: leave p and its order as they are and retry.
+		return err
+	}
+	if p.PrevStatus == "" {
+		// Mined before a poll saw it pending; the receipt vouches for it.
+		if _, err := t.lock(ctx, p, nil); err != nil {
+			return err
+		}
+	}
+
+	if head < block+t.confirmations {
+		if p.Status == store.TxStatusMined {
+			return nil
+		}
+		return t.advance(ctx, p, store.TxStatusMined, block, "",
+			[]store.OrderStatus{store.OrderStatusLocked}, store.OrderStatusSettling, store.OrderEventTxMined)
+	}
+
+	final := store.OrderStatusSuccess
+	if p.Type == store.OrderEventCancelled {
+		final = store.OrderStatusCanceled
+	}
+	return t.advance(ctx, p, store.TxStatusSuccess, block, "",
+		[]store.OrderStatus{store.OrderStatusLocked, store.OrderStatusSettling}, final, p.Type)
+}
+
+// pendingClaim returns what the callback that reported p asserts about its
+// transaction.
+func pendingClaim(p *store.PendingTx) (*OrderClaim, error) {
+	listingID, ok := new(big.Int).SetString(p.ListingID, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid listing id %q", p.ListingID)
+	}
+	c := &OrderClaim{
+		Event:       p.Type,
+		Marketplace: common.HexToAddress(p.Marketplace),
+		ListingID:   listingID,
+		TxHash:      common.HexToHash(p.TxHash),
+	}
+	if p.Type == store.OrderEventSold {
+		c.Buyer = common.HexToAddress(p.Actor)
+	}
+	return c, nil
+}
+
+// lockBroadcast looks p up on the node and locks its order once it is known
+// and sent to the marketplace by the claimed account. It reports true when p
+// failed because it was sent elsewhere or by someone else.
+func (t *TxTracker) lockBroadcast(ctx context.Context, p *store.PendingTx) (bool, error) {
+	txn, _, err := t.client.TransactionByHash(ctx, common.HexToHash(p.TxHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if to := txn.To(); to == nil || *to != common.HexToAddress(p.Marketplace) {
+		return true, t.fail(ctx, p, 0, "not sent to the marketplace")
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(t.chainID)), txn)
+	if err != nil {
+		return false, fmt.Errorf("recover sender: %w", err)
+	}
+	return t.lock(ctx, p, &sender)
+}
+
+// lock moves the order of p from LISTED / UNFILLABLE to LOCKED and records
+// the previous status in p, so a failure can restore it. sender, when set,
+// must be the buyer (Sold) or the order's seller (Cancelled); otherwise p
+// fails and lock reports true.
+func (t *TxTracker) lock(ctx context.Context, p *store.PendingTx, sender *common.Address) (bool, error) {
+	var mismatch string
+	err := t.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		o, err := t.orders.GetByIDForUpdateTx(ctx, tx, t.chainID, p.Marketplace, p.ListingID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !containsStatus([]store.OrderStatus{store.OrderStatusListed, store.OrderStatusUnfillable}, o.Status) {
+			return nil
+		}
+		if sender != nil {
+			want := p.Actor
+			if p.Type == store.OrderEventCancelled {
+				want = o.Seller
+			}
+			if *sender != common.HexToAddress(want) {
+				mismatch = fmt.Sprintf("sent by %s, not %s", sender.Hex(), want)
+				return nil
+			}
+		}
+		if err := t.txs.SetPrevStatusTx(ctx, tx, p.ID, o.Status); err != nil {
+			return err
+		}
+		if err := t.setOrderTx(ctx, tx, p, o, store.OrderStatusLocked, store.OrderEventTxSubmitted, ""); err != nil {
+			return err
+		}
+		p.PrevStatus = o.Status
+		return nil
+	})
+	if err != nil || mismatch == "" {
+		return false, err
+	}
+	return true, t.fail(ctx, p, 0, mismatch)
+}
+
+// fail marks p FAILED. If p holds the order's lock, the order goes back to
+// its status from before the lock; another transaction for the same listing
+// may lock it again on a later poll.
+func (t *TxTracker) fail(ctx context.Context, p *store.PendingTx, block uint64, reason string) error {
+	t.logger.Printf("tx tracker: %s of listing %s on %s failed: %s", p.TxHash, p.ListingID, p.Marketplace, reason)
+	return t.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		if err := t.txs.UpdateStatusTx(ctx, tx, p.ID, store.TxStatusFailed, block, reason); err != nil {
+			return err
+		}
+		if p.PrevStatus == "" {
+			return nil
+		}
+		other, err := t.txs.HasOtherLockingTx(ctx, tx, p)
+		if err != nil || other {
+			return err
+		}
+		return t.applyOrderTx(ctx, tx, p,
+			[]store.OrderStatus{store.OrderStatusLocked, store.OrderStatusSettling},
+			p.PrevStatus, store.OrderEventTxFailed, reason)
+	})
+}
+
+// advance moves p to status and its order from one of from to to, in one
+// transaction.
+func (t *TxTracker) advance(ctx context.Context, p *store.PendingTx, status store.TxStatus, block uint64, reason string, from []store.OrderStatus, to store.OrderStatus, eventType string) error {
+	return t.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		if err := t.txs.UpdateStatusTx(ctx, tx, p.ID, status, block, reason); err != nil {
+			return err
+		}
+		return t.applyOrderTx(ctx, tx, p, from, to, eventType, reason)
+	})
+}
+
+// applyOrderTx sets the order of p to status to if it currently is in one of
+// from, and records the change in order_events.
+func (t *TxTracker) applyOrderTx(ctx context.Context, tx *sql.Tx, p *store.PendingTx, from []store.OrderStatus, to store.OrderStatus, eventType, reason string) error {
+	o, err := t.orders.GetByIDForUpdateTx(ctx, tx, t.chainID, p.Marketplace, p.ListingID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !containsStatus(from, o.Status) {
+		return nil
+	}
+	return t.setOrderTx(ctx, tx, p, o, to, eventType, reason)
+}
+
+// setOrderTx sets o, the order of p, to status to and records the change in
+// order_events.
+func (t *TxTracker) setOrderTx(ctx context.Context, tx *sql.Tx, p *store.PendingTx, o *store.Order, to store.OrderStatus, eventType, reason string) error {
+	o.Status = to
+	if to == store.OrderStatusSuccess && p.Actor != "" {
+		o.Buyer = p.Actor
+	}
+	if err := t.orders.UpsertTx(ctx, tx, o); err != nil {
+		return err
+	}
+
+	actor := p.Actor
+	if actor == "" {
+		actor = o.Seller
+	}
+	var payload json.RawMessage
+	if reason != "" {
+		var err error
+		if payload, err = json.Marshal(map[string]string{"reason": reason}); err != nil {
+			return err
+		}
+	}
+	t.logger.Printf("tx tracker: listing %s on %s is now %s (%s)", p.ListingID, p.Marketplace, to, p.TxHash)
+	return t.orders.AppendEventTx(ctx, tx, &store.OrderEvent{
+		ChainID:     t.chainID,
+		Marketplace: p.Marketplace,
+		ListingID:   p.ListingID,
+		Type:        eventType,
+		Source:      store.OrderEventSourceTracker,
+		Status:      to,
+		Actor:       actor,
+		TxHash:      p.TxHash,
+		Payload:     payload,
+	})
+}
+
+func (t *TxTracker) inTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+				t.logger.Printf("tx tracker: rollback tx error: %v", err)
+			}
+		}
+	}()
+
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func containsStatus(list []store.OrderStatus, s store.OrderStatus) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+// Package contracts holds the ABIs of the NFTMarketplace, ProjectNFT and
+// Project1155 contracts and typed bindings for them.
+//
+// The ABIs under abi/ are embedded in the binary, so the server does not
+// depend on its working directory. SetABIDir makes the bindings load them
+// from a directory instead, e.g. to pick up a redeployed contract's ABI
+// without rebuilding.
+//
+// The bindings follow the layout abigen generates (XCaller for view calls,
+// XFilterer for decoding events) but only cover what the backend reads: it
+// never sends transactions, so there are no transactor bindings.
+package contracts
+
+import (
+	"bytes"
+	"embed"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+//go:embed abi/*.abi.json
+var abiFiles embed.FS
+
+// ABI file names, both in the embedded abi/ directory and in an override
+// directory set with SetABIDir.
+const (
+	NFTMarketplaceABIFile = "NFTMarketplace.abi.json"
+	ProjectNFTABIFile     = "ProjectNFT.abi.json"
+	Project1155ABIFile    = "Project1155.abi.json"
+)
+
+var abiDir string
+
+// SetABIDir makes the bindings read ABI files from dir instead of the
+// embedded copies. An empty dir restores the embedded ABIs. It must be called
+// before any binding or scanner is created.
+func SetABIDir(dir string) {
+	abiDir = dir
+}
+
+// ReadABI returns the raw JSON of the named ABI file.
+func ReadABI(file string) ([]byte, error) {
+	if abiDir != "" {
+		return os.ReadFile(filepath.Join(abiDir, file))
+	}
+	return abiFiles.ReadFile("abi/" + file)
+}
+
+func parseABI(file string) (abi.ABI, error) {
+	data, err := ReadABI(file)
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	return abi.JSON(bytes.NewReader(data))
+}
+
+// NFTMarketplaceABI returns the parsed NFTMarketplace ABI.
+func NFTMarketplaceABI() (abi.ABI, error) {
+	return parseABI(NFTMarketplaceABIFile)
+}
+
+// ProjectNFTABI returns the parsed ProjectNFT ABI.
+func ProjectNFTABI() (abi.ABI, error) {
+	return parseABI(ProjectNFTABIFile)
+}
+
+// Project1155ABI returns the parsed Project1155 ABI.
+func Project1155ABI() (abi.ABI, error) {
+	return parseABI(Project1155ABIFile)
+}
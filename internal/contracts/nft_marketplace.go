@@ -0,0 +1,136 @@
+package contracts
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// NFTMarketplaceCaller is a read-only binding to the NFTMarketplace contract.
+type NFTMarketplaceCaller struct {
+	contract *bind.BoundContract
+}
+
+// NFTMarketplaceFilterer decodes NFTMarketplace event logs.
+type NFTMarketplaceFilterer struct {
+	contract *bind.BoundContract
+}
+
+// NFTMarketplaceListing is the on-chain state of a listing, as returned by
+// listings(listingId).
+type NFTMarketplaceListing struct {
+	Seller  common.Address
+	Nft     common.Address
+	TokenId *big.Int
+	Amount  *big.Int
+	Price   *big.Int
+	Active  bool
+}
+
+// NFTMarketplaceListed represents a Listed event.
+type NFTMarketplaceListed struct {
+	ListingId *big.Int
+	Seller    common.Address
+	Nft       common.Address
+	TokenId   *big.Int
+	Amount    *big.Int
+	Price     *big.Int
+	Raw       types.Log
+}
+
+// NFTMarketplaceCancelled represents a Cancelled event.
+type NFTMarketplaceCancelled struct {
+	ListingId *big.Int
+	Raw       types.Log
+}
+
+// NFTMarketplaceSold represents a Sold event.
+type NFTMarketplaceSold struct {
+	ListingId *big.Int
+	Buyer     common.Address
+	Raw       types.Log
+}
+
+// NewNFTMarketplaceCaller creates a read-only binding to the marketplace at address.
+func NewNFTMarketplaceCaller(address common.Address, caller bind.ContractCaller) (*NFTMarketplaceCaller, error) {
+	contract, err := bindNFTMarketplace(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &NFTMarketplaceCaller{contract: contract}, nil
+}
+
+// NewNFTMarketplaceFilterer creates a log decoder for the marketplace at address.
+func NewNFTMarketplaceFilterer(address common.Address, filterer bind.ContractFilterer) (*NFTMarketplaceFilterer, error) {
+	contract, err := bindNFTMarketplace(address, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &NFTMarketplaceFilterer{contract: contract}, nil
+}
+
+func bindNFTMarketplace(address common.Address, caller bind.ContractCaller, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := NFTMarketplaceABI()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, nil, filterer), nil
+}
+
+// Listings calls listings(listingId).
+func (c *NFTMarketplaceCaller) Listings(opts *bind.CallOpts, listingID *big.Int) (NFTMarketplaceListing, error) {
+	var out []interface{}
+	var listing NFTMarketplaceListing
+	if err := c.contract.Call(opts, &out, "listings", listingID); err != nil {
+		return listing, err
+	}
+	listing.Seller = *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+	listing.Nft = *abi.ConvertType(out[1], new(common.Address)).(*common.Address)
+	listing.TokenId = *abi.ConvertType(out[2], new(*big.Int)).(**big.Int)
+	listing.Amount = *abi.ConvertType(out[3], new(*big.Int)).(**big.Int)
+	listing.Price = *abi.ConvertType(out[4], new(*big.Int)).(**big.Int)
+	listing.Active = *abi.ConvertType(out[5], new(bool)).(*bool)
+	return listing, nil
+}
+
+// NextListingId calls nextListingId(), the ID the next listing will get.
+func (c *NFTMarketplaceCaller) NextListingId(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "nextListingId"); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// ParseListed decodes a Listed log.
+func (f *NFTMarketplaceFilterer) ParseListed(log types.Log) (*NFTMarketplaceListed, error) {
+	event := new(NFTMarketplaceListed)
+	if err := f.contract.UnpackLog(event, "Listed", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseCancelled decodes a Cancelled log.
+func (f *NFTMarketplaceFilterer) ParseCancelled(log types.Log) (*NFTMarketplaceCancelled, error) {
+	event := new(NFTMarketplaceCancelled)
+	if err := f.contract.UnpackLog(event, "Cancelled", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseSold decodes a Sold log.
+func (f *NFTMarketplaceFilterer) ParseSold(log types.Log) (*NFTMarketplaceSold, error) {
+	event := new(NFTMarketplaceSold)
+	if err := f.contract.UnpackLog(event, "Sold", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
@@ -0,0 +1,148 @@
+package contracts
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Project1155Caller is a read-only binding to the Project1155 (ERC1155) contract.
+type Project1155Caller struct {
+	contract *bind.BoundContract
+}
+
+// Project1155Filterer decodes Project1155 event logs.
+type Project1155Filterer struct {
+	contract *bind.BoundContract
+}
+
+// Project1155TransferSingle represents a TransferSingle event.
+type Project1155TransferSingle struct {
+	Operator common.Address
+	From     common.Address
+	To       common.Address
+	Id       *big.Int
+	Value    *big.Int
+	Raw      types.Log
+}
+
+// Project1155TransferBatch represents a TransferBatch event.
+type Project1155TransferBatch struct {
+	Operator common.Address
+	From     common.Address
+	To       common.Address
+	Ids      []*big.Int
+	Values   []*big.Int
+	Raw      types.Log
+}
+
+// Project1155URI represents a URI event.
+type Project1155URI struct {
+	Value string
+	Id    *big.Int
+	Raw   types.Log
+}
+
+// Project1155ApprovalForAll represents an ApprovalForAll event.
+type Project1155ApprovalForAll struct {
+	Owner    common.Address
+	Operator common.Address
+	Approved bool
+	Raw      types.Log
+}
+
+// NewProject1155Caller creates a read-only binding to the ERC1155 contract at address.
+func NewProject1155Caller(address common.Address, caller bind.ContractCaller) (*Project1155Caller, error) {
+	contract, err := bindProject1155(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Project1155Caller{contract: contract}, nil
+}
+
+// NewProject1155Filterer creates a log decoder for the ERC1155 contract at address.
+func NewProject1155Filterer(address common.Address, filterer bind.ContractFilterer) (*Project1155Filterer, error) {
+	contract, err := bindProject1155(address, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &Project1155Filterer{contract: contract}, nil
+}
+
+func bindProject1155(address common.Address, caller bind.ContractCaller, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := Project1155ABI()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, nil, filterer), nil
+}
+
+// BalanceOf calls balanceOf(account, id).
+func (c *Project1155Caller) BalanceOf(opts *bind.CallOpts, account common.Address, id *big.Int) (*big.Int, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "balanceOf", account, id); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// IsApprovedForAll calls isApprovedForAll(account, operator).
+func (c *Project1155Caller) IsApprovedForAll(opts *bind.CallOpts, account, operator common.Address) (bool, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "isApprovedForAll", account, operator); err != nil {
+		return false, err
+	}
+	return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+}
+
+// Uri calls uri(id).
+func (c *Project1155Caller) Uri(opts *bind.CallOpts, id *big.Int) (string, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "uri", id); err != nil {
+		return "", err
+	}
+	return *abi.ConvertType(out[0], new(string)).(*string), nil
+}
+
+// ParseTransferSingle decodes a TransferSingle log.
+func (f *Project1155Filterer) ParseTransferSingle(log types.Log) (*Project1155TransferSingle, error) {
+	event := new(Project1155TransferSingle)
+	if err := f.contract.UnpackLog(event, "TransferSingle", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseTransferBatch decodes a TransferBatch log.
+func (f *Project1155Filterer) ParseTransferBatch(log types.Log) (*Project1155TransferBatch, error) {
+	event := new(Project1155TransferBatch)
+	if err := f.contract.UnpackLog(event, "TransferBatch", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseURI decodes a URI log.
+func (f *Project1155Filterer) ParseURI(log types.Log) (*Project1155URI, error) {
+	event := new(Project1155URI)
+	if err := f.contract.UnpackLog(event, "URI", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseApprovalForAll decodes an ApprovalForAll log.
+func (f *Project1155Filterer) ParseApprovalForAll(log types.Log) (*Project1155ApprovalForAll, error) {
+	event := new(Project1155ApprovalForAll)
+	if err := f.contract.UnpackLog(event, "ApprovalForAll", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
@@ -0,0 +1,145 @@
+package contracts
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ProjectNFTCaller is a read-only binding to the ProjectNFT (ERC721) contract.
+type ProjectNFTCaller struct {
+	contract *bind.BoundContract
+}
+
+// ProjectNFTFilterer decodes ProjectNFT event logs.
+type ProjectNFTFilterer struct {
+	contract *bind.BoundContract
+}
+
+// ProjectNFTTransfer represents a Transfer event.
+type ProjectNFTTransfer struct {
+	From    common.Address
+	To      common.Address
+	TokenId *big.Int
+	Raw     types.Log
+}
+
+// ProjectNFTApproval represents an Approval event.
+type ProjectNFTApproval struct {
+	Owner    common.Address
+	Approved common.Address
+	TokenId  *big.Int
+	Raw      types.Log
+}
+
+// ProjectNFTApprovalForAll represents an ApprovalForAll event.
+type ProjectNFTApprovalForAll struct {
+	Owner    common.Address
+	Operator common.Address
+	Approved bool
+	Raw      types.Log
+}
+
+// NewProjectNFTCaller creates a read-only binding to the ERC721 contract at address.
+func NewProjectNFTCaller(address common.Address, caller bind.ContractCaller) (*ProjectNFTCaller, error) {
+	contract, err := bindProjectNFT(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectNFTCaller{contract: contract}, nil
+}
+
+// NewProjectNFTFilterer creates a log decoder for the ERC721 contract at address.
+func NewProjectNFTFilterer(address common.Address, filterer bind.ContractFilterer) (*ProjectNFTFilterer, error) {
+	contract, err := bindProjectNFT(address, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectNFTFilterer{contract: contract}, nil
+}
+
+func bindProjectNFT(address common.Address, caller bind.ContractCaller, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := ProjectNFTABI()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, nil, filterer), nil
+}
+
+// OwnerOf calls ownerOf(tokenId).
+func (c *ProjectNFTCaller) OwnerOf(opts *bind.CallOpts, tokenID *big.Int) (common.Address, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "ownerOf", tokenID); err != nil {
+		return common.Address{}, err
+	}
+	return *abi.ConvertType(out[0], new(common.Address)).(*common.Address), nil
+}
+
+// GetApproved calls getApproved(tokenId).
+func (c *ProjectNFTCaller) GetApproved(opts *bind.CallOpts, tokenID *big.Int) (common.Address, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "getApproved", tokenID); err != nil {
+		return common.Address{}, err
+	}
+	return *abi.ConvertType(out[0], new(common.Address)).(*common.Address), nil
+}
+
+// IsApprovedForAll calls isApprovedForAll(owner, operator).
+func (c *ProjectNFTCaller) IsApprovedForAll(opts *bind.CallOpts, owner, operator common.Address) (bool, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "isApprovedForAll", owner, operator); err != nil {
+		return false, err
+	}
+	return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+}
+
+// TokenURI calls tokenURI(tokenId).
+func (c *ProjectNFTCaller) TokenURI(opts *bind.CallOpts, tokenID *big.Int) (string, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "tokenURI", tokenID); err != nil {
+		return "", err
+	}
+	return *abi.ConvertType(out[0], new(string)).(*string), nil
+}
+
+// NextTokenId calls nextTokenId(), the ID the next mint will get.
+func (c *ProjectNFTCaller) NextTokenId(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	if err := c.contract.Call(opts, &out, "nextTokenId"); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// ParseTransfer decodes a Transfer log.
+func (f *ProjectNFTFilterer) ParseTransfer(log types.Log) (*ProjectNFTTransfer, error) {
+	event := new(ProjectNFTTransfer)
+	if err := f.contract.UnpackLog(event, "Transfer", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseApproval decodes an Approval log.
+func (f *ProjectNFTFilterer) ParseApproval(log types.Log) (*ProjectNFTApproval, error) {
+	event := new(ProjectNFTApproval)
+	if err := f.contract.UnpackLog(event, "Approval", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseApprovalForAll decodes an ApprovalForAll log.
+func (f *ProjectNFTFilterer) ParseApprovalForAll(log types.Log) (*ProjectNFTApprovalForAll, error) {
+	event := new(ProjectNFTApprovalForAll)
+	if err := f.contract.UnpackLog(event, "ApprovalForAll", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
@@ -0,0 +1,65 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxMetadataSize caps how much of a metadata response is read.
+const maxMetadataSize = 1 << 20
+
+// ResolveURL turns an ipfs:// URI into a URL on the configured gateway.
+// http(s) URLs are returned unchanged.
+func (c *PinataClient) ResolveURL(uri string) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, "ipfs://"):
+		path := strings.TrimPrefix(uri, "ipfs://")
+		path = strings.TrimPrefix(path, "ipfs/")
+		return fmt.Sprintf("%s/%s", strings.TrimRight(c.gatewayURL, "/"), path), nil
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		return uri, nil
+	default:
+		return "", fmt.Errorf("unsupported uri scheme: %q", uri)
+	}
+}
+
+// FetchMetadata downloads the document behind a token URI. It returns the
+// body when it is a JSON object, and nil when the URI points to something
+// else (e.g. directly to an image).
+func (c *PinataClient) FetchMetadata(ctx context.Context, uri string) ([]byte, error) {
+	url, err := c.ResolveURL(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("metadata request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("metadata error: status=%d", resp.StatusCode)
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '{' || !json.Valid(body) {
+		return nil, nil
+	}
+	return body, nil
+}
@@ -0,0 +1,184 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LeaderElector elects one leader among the server replicas sharing a Redis
+// instance, using a lease: the leader holds the key <prefix><name> with a TTL
+// and renews it with the lock watchdog (RedisLock.KeepAlive); the other
+// replicas try to take it every retryInterval, so a dead leader is replaced
+// within ttl + retryInterval.
+//
+// Every term gets a number, a counter incremented in the same Lua script that
+// takes the lease. It is part of the lock value, so renewals are bound to the
+// term: a leader that lost the lease (e.g. paused longer than ttl) can never
+// extend its successor's. The leader also stops leading on its own when the
+// watchdog could not renew the lease in time, before it can expire in Redis.
+// Writes outside Redis are fenced separately, see store.LeaderFence: lead
+// takes a fencing token there for each term.
+type LeaderElector struct {
+	locker        *RedisLocker
+	name          string
+	id            string
+	ttl           time.Duration
+	retryInterval time.Duration
+	logger        *log.Logger
+
+	mu   sync.Mutex
+	term *leaderTerm
+}
+
+type leaderTerm struct {
+	lock   *RedisLock
+	number int64
+	since  time.Time
+}
+
+// LeaderStatus describes the election as seen by this replica.
+type LeaderStatus struct {
+	Name   string     `json:"name"`
+	ID     string     `json:"id"` // this replica
+	Leader bool       `json:"leader"`
+	Term   int64      `json:"term,omitempty"` // number of this replica's term
+	Since  *time.Time `json:"since,omitempty"`
+	Holder string     `json:"holder"` // current lease value (<id>/<term>), empty when vacant
+}
+
+// NewLeaderElector creates an elector for name. ttl is the lease length
+// (at least 3s). Replicas are told apart by host name and process ID.
+func NewLeaderElector(locker *RedisLocker, name string, ttl time.Duration, logger *log.Logger) *LeaderElector {
+	if ttl < 3*time.Second {
+		ttl = 3 * time.Second
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &LeaderElector{
+		locker:        locker,
+		name:          name,
+		id:            host + ":" + strconv.Itoa(os.Getpid()) + ":" + randomLockValue()[:8],
+		ttl:           ttl,
+		retryInterval: 2 * time.Second,
+		logger:        logger,
+	}
+}
+
+// Run campaigns until ctx is canceled. Each time this replica becomes the
+// leader, lead is called with the term number and a context that is
+// canceled when leadership is lost; lead must return soon after that. The
+// lease is released when lead returns, and the replica campaigns again.
+func (e *LeaderElector) Run(ctx context.Context, lead func(ctx context.Context, term int64)) {
+	for {
+		term, err := e.campaign(ctx)
+		switch {
+		case err == nil:
+			e.serve(ctx, term, lead)
+		case !errors.Is(err, ErrLockNotAcquired) && ctx.Err() == nil:
+			e.logger.Printf("leader election %s: campaign error: %v", e.name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.retryInterval):
+		}
+	}
+}
+
+// Status reports whether this replica leads and who holds the lease.
+func (e *LeaderElector) Status(ctx context.Context) (*LeaderStatus, error) {
+	st := &LeaderStatus{Name: e.name, ID: e.id}
+	e.mu.Lock()
+	if e.term != nil {
+		since := e.term.since
+		st.Leader = true
+		st.Term = e.term.number
+		st.Since = &since
+	}
+	e.mu.Unlock()
+
+	holder, err := e.locker.client.Get(ctx, e.key()).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	st.Holder = holder
+	return st, nil
+}
+
+// IsLeader reports whether this replica currently leads.
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.term != nil
+}
+
+func (e *LeaderElector) key() string {
+	return e.locker.prefix + e.name
+}
+
+// campaign takes the lease if it is vacant, with a new term number.
+func (e *LeaderElector) campaign(ctx context.Context) (*leaderTerm, error) {
+	const script = `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+  return 0
+end
+local term = redis.call("INCR", KEYS[2])
+redis.call("SET", KEYS[1], ARGV[1] .. "/" .. term, "PX", ARGV[2])
+return term`
+
+	ctx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+
+	key := e.key()
+	number, err := e.locker.client.Eval(ctx, script, []string{key, key + ":term"}, e.id, e.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if number == 0 {
+		return nil, ErrLockNotAcquired
+	}
+	return &leaderTerm{
+		lock:   &RedisLock{client: e.locker.client, key: key, value: fmt.Sprintf("%s/%d", e.id, number), ttl: e.ttl},
+		number: number,
+		since:  time.Now().UTC(),
+	}, nil
+}
+
+// serve runs lead for one term while keeping the lease alive.
+func (e *LeaderElector) serve(ctx context.Context, term *leaderTerm, lead func(ctx context.Context, term int64)) {
+	e.logger.Printf("leader election %s: %s is the leader (term %d)", e.name, e.id, term.number)
+	e.mu.Lock()
+	e.term = term
+	e.mu.Unlock()
+
+	leaderCtx := term.lock.KeepAlive(ctx)
+	lead(leaderCtx, term.number)
+	if cause := context.Cause(leaderCtx); errors.Is(cause, ErrLockLost) {
+		e.logger.Printf("leader election %s: %v", e.name, cause)
+	}
+
+	e.mu.Lock()
+	e.term = nil
+	e.mu.Unlock()
+
+	releaseCtx, cancelRelease := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancelRelease()
+	if err := term.lock.Release(releaseCtx); err != nil {
+		e.logger.Printf("leader election %s: release lease error: %v", e.name, err)
+	}
+	e.logger.Printf("leader election %s: %s stepped down (term %d)", e.name, e.id, term.number)
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -13,6 +15,15 @@ import (
 // ErrLockNotAcquired is returned when a lock key is already held.
 var ErrLockNotAcquired = errors.New("lock not acquired")
 
+// ErrLockLost is returned by Extend, and is the cause of a KeepAlive
+// context's cancellation, when the lock is no longer held by its owner
+// (it expired, possibly taken by someone else, or was released).
+var ErrLockLost = errors.New("lock ownership lost")
+
+// minTTL is the shortest lock TTL: Redis expires keys with millisecond
+// precision, and a zero TTL would make the lock never expire.
+const minTTL = time.Millisecond
+
 // RedisLocker provides simple distributed locks backed by Redis.
 type RedisLocker struct {
 	client *redis.Client
@@ -24,6 +35,11 @@ type RedisLock struct {
 	client *redis.Client
 	key    string
 	value  string
+	ttl    time.Duration // TTL the lock was acquired with, kept by the watchdog
+
+	mu       sync.Mutex
+	released bool
+	stop     chan struct{} // closed by Release to stop the watchdog
 }
 
 // NewRedisLocker creates a new locker with the given key prefix.
@@ -37,9 +53,13 @@ func NewRedisLocker(client *redis.Client, prefix string) *RedisLocker {
 	}
 }
 
-// Acquire tries to acquire a lock for the specified key with the given TTL.
+// Acquire tries to acquire a lock for the specified key with the given TTL,
+// which must be at least a millisecond.
 // On success, it returns a RedisLock that must be released.
 func (l *RedisLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (*RedisLock, error) {
+	if ttl < minTTL {
+		return nil, fmt.Errorf("lock ttl %v is shorter than %v", ttl, minTTL)
+	}
 	fullKey := l.prefix + key
 	value := randomLockValue()
 
@@ -54,22 +74,131 @@ func (l *RedisLocker) Acquire(ctx context.Context, key string, ttl time.Duration
 		client: l.client,
 		key:    fullKey,
 		value:  value,
+		ttl:    ttl,
 	}, nil
 }
 
-// Release releases the held lock. It is safe to call multiple times.
+// Release releases the held lock and stops its watchdog. It is safe to call
+// multiple times.
 func (l *RedisLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.released {
+		l.released = true
+		if l.stop != nil {
+			close(l.stop)
+		}
+	}
+	l.mu.Unlock()
+
+	// Waiters of AcquireWait with Notify are woken through the release
+	// channel; nothing is published when the lock was no longer ours.
 	const script = `
 if redis.call("GET", KEYS[1]) == ARGV[1] then
-  return redis.call("DEL", KEYS[1])
+  redis.call("DEL", KEYS[1])
+  redis.call("PUBLISH", ARGV[2], "1")
+  return 1
 else
   return 0
 end`
 
-	cmd := l.client.Eval(ctx, script, []string{l.key}, l.value)
+	cmd := l.client.Eval(ctx, script, []string{l.key}, l.value, releaseChannel(l.key))
 	return cmd.Err()
 }
 
+// Extend resets the lock's TTL to ttl, comparing the stored value first so
+// that only the owner can extend it. It returns ErrLockLost when the lock is
+// no longer held with this lock's value. ttl must be at least a millisecond.
+func (l *RedisLock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl < minTTL {
+		return fmt.Errorf("lock ttl %v is shorter than %v", ttl, minTTL)
+	}
+	const script = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+  return 0
+end`
+
+	n, err := l.client.Eval(ctx, script, []string{l.key}, l.value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// KeepAlive starts a watchdog that extends the lock to its TTL every ttl/3
+// while the holder is alive, until Release is called or ctx is done. If the
+// process dies, the watchdog dies with it and the lock expires as usual.
+//
+// It returns a context derived from ctx that is canceled, with a cause
+// wrapping ErrLockLost, when ownership was lost: Extend reported it, or the
+// lock could not be extended (e.g. Redis unreachable) before most of its TTL
+// had passed. Work protected by the lock should run under that context, so
+// that it stops instead of running unprotected. Call KeepAlive at most once.
+func (l *RedisLock) KeepAlive(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		cancel(fmt.Errorf("%w: already released", ErrLockLost))
+		return ctx
+	}
+	if l.ttl < minTTL {
+		// Only locks built outside Acquire can get here; there is no lease
+		// to keep alive.
+		cancel(fmt.Errorf("%w: ttl %v is shorter than %v", ErrLockLost, l.ttl, minTTL))
+		return ctx
+	}
+	l.stop = make(chan struct{})
+	go l.watchdog(ctx, cancel, l.stop)
+	return ctx
+}
+
+func (l *RedisLock) watchdog(ctx context.Context, cancel context.CancelCauseFunc, stop <-chan struct{}) {
+	defer cancel(nil)
+
+	// Give up a little before the TTL runs out, so the protected work stops
+	// before anyone else can take the lock (assuming similar clock rates).
+	lease := l.ttl - l.ttl/5
+	expiry := time.NewTimer(lease)
+	defer expiry.Stop()
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-expiry.C:
+			cancel(fmt.Errorf("%w: not extended before its ttl ran out", ErrLockLost))
+			return
+		case <-ticker.C:
+		}
+
+		extendCtx, cancelExtend := context.WithTimeout(ctx, l.ttl/3)
+		start := time.Now()
+		err := l.Extend(extendCtx, l.ttl)
+		cancelExtend()
+		switch {
+		case errors.Is(err, ErrLockLost):
+			cancel(err)
+			return
+		case err != nil:
+			// Transient error: try again on the next tick; expiry gives up
+			// in time if Redis stays unreachable.
+			continue
+		}
+		// Count from before the call: the new TTL started no earlier.
+		expiry.Reset(lease - time.Since(start))
+	}
+}
+
 func randomLockValue() string {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
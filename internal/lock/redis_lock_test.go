@@ -0,0 +1,58 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// unreachableClient returns a client whose every command fails quickly.
+func unreachableClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestTTLShorterThanAMillisecond(t *testing.T) {
+	ctx := context.Background()
+	locker := NewRedisLocker(unreachableClient(t), "")
+	lock := &RedisLock{client: locker.client, key: "lock:k", value: "v"}
+
+	for _, ttl := range []time.Duration{-time.Second, 0, time.Microsecond, time.Millisecond - 1} {
+		if _, err := locker.Acquire(ctx, "k", ttl); err == nil || errors.Is(err, ErrLockNotAcquired) {
+			t.Errorf("Acquire with ttl %v: err = %v, want a ttl error", ttl, err)
+		}
+		if err := lock.Extend(ctx, ttl); err == nil || errors.Is(err, ErrLockLost) {
+			t.Errorf("Extend with ttl %v: err = %v, want a ttl error", ttl, err)
+		}
+	}
+}
+
+func TestKeepAliveLost(t *testing.T) {
+	client := unreachableClient(t)
+	tests := []struct {
+		name string
+		lock *RedisLock
+	}{
+		{"released", &RedisLock{client: client, key: "lock:k", value: "v", ttl: time.Second, released: true}},
+		{"no ttl", &RedisLock{client: client, key: "lock:k", value: "v"}},
+		// Extend keeps failing, so the lease runs out.
+		{"not extended", &RedisLock{client: client, key: "lock:k", value: "v", ttl: 60 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.lock.KeepAlive(context.Background())
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("KeepAlive context not canceled")
+			}
+			if cause := context.Cause(ctx); !errors.Is(cause, ErrLockLost) {
+				t.Fatalf("cause = %v, want ErrLockLost", cause)
+			}
+		})
+	}
+}
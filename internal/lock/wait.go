@@ -0,0 +1,96 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WaitOptions configures AcquireWait. The zero value uses the defaults.
+type WaitOptions struct {
+	// MinBackoff is the delay before the first retry (default 20ms). It is
+	// doubled after every failed attempt, up to MaxBackoff (default 500ms).
+	// Each delay is jittered to between half and all of its value, so waiters
+	// do not retry in lockstep.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Notify subscribes to the lock's release channel while waiting, so a
+	// waiter retries as soon as the holder calls Release instead of at its
+	// next backoff. Locks that expire are still only noticed by the backoff.
+	Notify bool
+}
+
+func (o WaitOptions) withDefaults() WaitOptions {
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 20 * time.Millisecond
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = max(500*time.Millisecond, o.MinBackoff)
+	}
+	return o
+}
+
+// AcquireWait is like Acquire, but when the key is held it retries with
+// jittered exponential backoff until the lock is acquired or ctx is done.
+// In the latter case the returned error wraps both ErrLockNotAcquired and
+// ctx's cause. ctx should carry a deadline: without one, AcquireWait waits
+// until the holder releases the lock or it expires.
+//
+// ctx only bounds the wait; the lock itself lives for ttl as with Acquire.
+func (l *RedisLocker) AcquireWait(ctx context.Context, key string, ttl time.Duration, opts WaitOptions) (*RedisLock, error) {
+	opts = opts.withDefaults()
+
+	var released <-chan *redis.Message
+	if opts.Notify {
+		// Subscribe before the first attempt, so a release between a failed
+		// attempt and the wait is not missed. If subscribing fails, the
+		// backoff alone still gets the lock eventually.
+		pubsub := l.client.Subscribe(ctx, releaseChannel(l.prefix+key))
+		defer pubsub.Close()
+		if _, err := pubsub.Receive(ctx); err == nil {
+			released = pubsub.Channel()
+		}
+	}
+
+	backoff := opts.MinBackoff
+	for {
+		lock, err := l.Acquire(ctx, key, ttl)
+		switch {
+		case err == nil:
+			return lock, nil
+		case ctx.Err() != nil:
+			return nil, waitTimeout(ctx)
+		case !errors.Is(err, ErrLockNotAcquired):
+			return nil, err
+		}
+
+		delay := backoff/2 + rand.N(backoff/2+1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, waitTimeout(ctx)
+		case _, ok := <-released:
+			timer.Stop()
+			if !ok {
+				released = nil
+			}
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
+
+func waitTimeout(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", ErrLockNotAcquired, context.Cause(ctx))
+}
+
+// releaseChannel is the pub/sub channel Release publishes to after deleting
+// fullKey.
+func releaseChannel(fullKey string) string {
+	return fullKey + ":released"
+}
@@ -0,0 +1,31 @@
+package lock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitOptionsDefaults(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		in       WaitOptions
+		min, max time.Duration
+	}{
+		{"zero value", WaitOptions{}, 20 * ms, 500 * ms},
+		{"negative", WaitOptions{MinBackoff: -ms, MaxBackoff: -ms}, 20 * ms, 500 * ms},
+		{"min only", WaitOptions{MinBackoff: 50 * ms}, 50 * ms, 500 * ms},
+		{"min above default max", WaitOptions{MinBackoff: time.Second}, time.Second, time.Second},
+		{"max below min", WaitOptions{MinBackoff: 100 * ms, MaxBackoff: 10 * ms}, 100 * ms, 500 * ms},
+		{"both set", WaitOptions{MinBackoff: 5 * ms, MaxBackoff: 80 * ms}, 5 * ms, 80 * ms},
+		{"max equal to min", WaitOptions{MinBackoff: 30 * ms, MaxBackoff: 30 * ms}, 30 * ms, 30 * ms},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in.withDefaults()
+			if got.MinBackoff != tt.min || got.MaxBackoff != tt.max {
+				t.Errorf("withDefaults() = %v..%v, want %v..%v", got.MinBackoff, got.MaxBackoff, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// OperatorApproval represents a row in the nft_operator_approvals table
+// (ERC721 / ERC1155 setApprovalForAll).
+type OperatorApproval struct {
+	ChainID     int64     `json:"chain_id"`
+	NFTAddress  string    `json:"nft_address"`
+	Owner       string    `json:"owner"`
+	Operator    string    `json:"operator"`
+	Approved    bool      `json:"approved"`
+	BlockNumber uint64    `json:"block_number"`
+	LogIndex    uint      `json:"log_index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TokenApproval represents a row in the nft_token_approvals table
+// (ERC721 approve for a single token).
+type TokenApproval struct {
+	ChainID     int64     `json:"chain_id"`
+	NFTAddress  string    `json:"nft_address"`
+	TokenID     string    `json:"token_id"`
+	Owner       string    `json:"owner"`
+	Approved    string    `json:"approved"`
+	BlockNumber uint64    `json:"block_number"`
+	LogIndex    uint      `json:"log_index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ApprovalStore wraps access to the nft_operator_approvals and
+// nft_token_approvals tables in MySQL.
+type ApprovalStore struct {
+	db *sql.DB
+}
+
+// NewApprovalStore creates a new ApprovalStore.
+func NewApprovalStore(db *sql.DB) *ApprovalStore {
+	return &ApprovalStore{db: db}
+}
+
+// InitSchema ensures the nft_operator_approvals and nft_token_approvals tables exist.
+func (s *ApprovalStore) InitSchema(ctx context.Context) error {
+	for _, file := range []string{
+		"sql/create_nft_operator_approvals_table.sql",
+		"sql/create_nft_token_approvals_table.sql",
+	} {
+		content, err := schemaFiles.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+			return err
+		}
+	}
+	if err := addChainID(ctx, s.db, "nft_operator_approvals", "chain_id", "nft_address", "owner", "operator"); err != nil {
+		return err
+	}
+	if err := addChainID(ctx, s.db, "nft_token_approvals", "chain_id", "nft_address", "token_id"); err != nil {
+		return err
+	}
+	return modifyToVarchar(ctx, s.db, "nft_token_approvals", []columnDef{
+		{"token_id", "VARCHAR(78) NOT NULL COMMENT 'ERC721 tokenId'"},
+	})
+}
+
+// GetOperatorForUpdateTx returns the operator approval of owner for operator
+// on a contract and locks it within the given transaction.
+func (s *ApprovalStore) GetOperatorForUpdateTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, owner, operator string) (*OperatorApproval, error) {
+	const q = `
+SELECT chain_id, nft_address, owner, operator, approved, block_number, log_index, created_at, updated_at
+FROM nft_operator_approvals
+WHERE chain_id = ? AND nft_address = ? AND owner = ? AND operator = ?
+FOR UPDATE`
+
+	var a OperatorApproval
+	if err := tx.QueryRowContext(ctx, q, chainID, nftAddress, owner, operator).Scan(
+		&a.ChainID,
+		&a.NFTAddress,
+		&a.Owner,
+		&a.Operator,
+		&a.Approved,
+		&a.BlockNumber,
+		&a.LogIndex,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// UpsertOperatorTx creates or updates an operator approval.
+func (s *ApprovalStore) UpsertOperatorTx(ctx context.Context, tx *sql.Tx, a *OperatorApproval) error {
+	const q = `
+INSERT INTO nft_operator_approvals (chain_id, nft_address, owner, operator, approved, block_number, log_index)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  approved = VALUES(approved),
+  block_number = VALUES(block_number),
+  log_index = VALUES(log_index);`
+
+	_, err := tx.ExecContext(ctx, q, a.ChainID, a.NFTAddress, a.Owner, a.Operator, a.Approved, a.BlockNumber, a.LogIndex)
+	return err
+}
+
+// GetTokenForUpdateTx returns the single-token approval of a token and locks
+// it within the given transaction.
+func (s *ApprovalStore) GetTokenForUpdateTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, tokenID string) (*TokenApproval, error) {
+	const q = `
+SELECT chain_id, nft_address, token_id, owner, approved, block_number, log_index, created_at, updated_at
+FROM nft_token_approvals
+WHERE chain_id = ? AND nft_address = ? AND token_id = ?
+FOR UPDATE`
+
+	var a TokenApproval
+	if err := tx.QueryRowContext(ctx, q, chainID, nftAddress, tokenID).Scan(
+		&a.ChainID,
+		&a.NFTAddress,
+		&a.TokenID,
+		&a.Owner,
+		&a.Approved,
+		&a.BlockNumber,
+		&a.LogIndex,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// UpsertTokenTx creates or updates a single-token approval.
+func (s *ApprovalStore) UpsertTokenTx(ctx context.Context, tx *sql.Tx, a *TokenApproval) error {
+	const q = `
+INSERT INTO nft_token_approvals (chain_id, nft_address, token_id, owner, approved, block_number, log_index)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  owner = VALUES(owner),
+  approved = VALUES(approved),
+  block_number = VALUES(block_number),
+  log_index = VALUES(log_index);`
+
+	_, err := tx.ExecContext(ctx, q, a.ChainID, a.NFTAddress, a.TokenID, a.Owner, a.Approved, a.BlockNumber, a.LogIndex)
+	return err
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// CallbackStatus is the verification state of a queued order callback.
+type CallbackStatus string
+
+const (
+	CallbackStatusPending   CallbackStatus = "PENDING"   // waiting for the chain to confirm it
+	CallbackStatusConfirmed CallbackStatus = "CONFIRMED" // verified and applied to the order
+	CallbackStatusRejected  CallbackStatus = "REJECTED"  // contradicted by the chain
+	CallbackStatusExpired   CallbackStatus = "EXPIRED"   // still unverifiable after the retry window
+)
+
+// OrderCallback is a frontend order callback (POST /orders or
+// POST /orders/:listingId/status) that could not be verified against the
+// chain when it arrived. Payload holds the original request body so it can
+// be applied once verified.
+type OrderCallback struct {
+	ID          int64           `json:"id"`
+	ChainID     int64           `json:"chain_id"`
+	Marketplace string          `json:"marketplace"`
+	ListingID   string          `json:"listing_id"`
+	Type        string          `json:"type"` // OrderEventListed, OrderEventCancelled or OrderEventSold
+	TxHash      string          `json:"tx_hash,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      CallbackStatus  `json:"status"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// CallbackStore wraps access to the order_callbacks table.
+type CallbackStore struct {
+	db *sql.DB
+}
+
+// NewCallbackStore creates a new CallbackStore.
+func NewCallbackStore(db *sql.DB) *CallbackStore {
+	return &CallbackStore{db: db}
+}
+
+// InitSchema ensures the order_callbacks table exists.
+func (s *CallbackStore) InitSchema(ctx context.Context) error {
+	content, err := schemaFiles.ReadFile("sql/create_order_callbacks_table.sql")
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+		return err
+	}
+	return modifyToVarchar(ctx, s.db, "order_callbacks", []columnDef{
+		{"listing_id", "VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId'"},
+	})
+}
+
+// Insert queues cb as PENDING and fills in its ID.
+func (s *CallbackStore) Insert(ctx context.Context, cb *OrderCallback) error {
+	const q = `
+INSERT INTO order_callbacks (
+  chain_id, marketplace, listing_id, event_type, tx_hash, payload, status, last_error
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	cb.Status = CallbackStatusPending
+	res, err := s.db.ExecContext(ctx, q,
+		cb.ChainID,
+		cb.Marketplace,
+		cb.ListingID,
+		cb.Type,
+		cb.TxHash,
+		string(cb.Payload),
+		cb.Status,
+		truncate(cb.LastError, 512),
+	)
+	if err != nil {
+		return err
+	}
+	cb.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByID returns a callback by ID (sql.ErrNoRows if it does not exist).
+func (s *CallbackStore) GetByID(ctx context.Context, id int64) (*OrderCallback, error) {
+	const q = `SELECT ` + callbackColumns + ` FROM order_callbacks WHERE id = ?`
+
+	return scanCallback(s.db.QueryRowContext(ctx, q, id))
+}
+
+// ListPending returns up to limit PENDING callbacks, oldest first.
+func (s *CallbackStore) ListPending(ctx context.Context, limit int) ([]*OrderCallback, error) {
+	const q = `SELECT ` + callbackColumns + `
+FROM order_callbacks
+WHERE status = ?
+ORDER BY id
+LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, q, CallbackStatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*OrderCallback
+	for rows.Next() {
+		cb, err := scanCallback(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cb)
+	}
+	return out, rows.Err()
+}
+
+// Retry records another failed verification attempt of a PENDING callback.
+func (s *CallbackStore) Retry(ctx context.Context, id int64, reason string) error {
+	const q = `
+UPDATE order_callbacks
+SET attempts = attempts + 1, last_error = ?
+WHERE id = ? AND status = ?`
+
+	_, err := s.db.ExecContext(ctx, q, truncate(reason, 512), id, CallbackStatusPending)
+	return err
+}
+
+// Resolve moves a PENDING callback to a final status.
+func (s *CallbackStore) Resolve(ctx context.Context, id int64, status CallbackStatus, reason string) error {
+	const q = `
+UPDATE order_callbacks
+SET status = ?, attempts = attempts + 1, last_error = ?
+WHERE id = ? AND status = ?`
+
+	_, err := s.db.ExecContext(ctx, q, status, truncate(reason, 512), id, CallbackStatusPending)
+	return err
+}
+
+const callbackColumns = `
+  id, chain_id, marketplace, listing_id, event_type, tx_hash, payload,
+  status, attempts, last_error, created_at, updated_at`
+
+func scanCallback(row rowScanner) (*OrderCallback, error) {
+	var cb OrderCallback
+	var payload string
+	if err := row.Scan(
+		&cb.ID,
+		&cb.ChainID,
+		&cb.Marketplace,
+		&cb.ListingID,
+		&cb.Type,
+		&cb.TxHash,
+		&payload,
+		&cb.Status,
+		&cb.Attempts,
+		&cb.LastError,
+		&cb.CreatedAt,
+		&cb.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	cb.Payload = json.RawMessage(payload)
+	return &cb, nil
+}
+
+// truncate cuts s to at most n bytes so it fits a VARCHAR column.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// CheckpointStore wraps access to the scanner_checkpoints table in MySQL.
+// Each row records the last block a scanner has fully processed for a
+// given chain + contract, so the scanner can resume there after a restart.
+// Saves are fenced (see LeaderFence) when the context carries a token.
+type CheckpointStore struct {
+	db *sql.DB
+}
+
+// NewCheckpointStore creates a new CheckpointStore.
+func NewCheckpointStore(db *sql.DB) *CheckpointStore {
+	return &CheckpointStore{db: db}
+}
+
+// InitSchema ensures the scanner_checkpoints, scanner_backfills and
+// scanner_listing_gaps tables exist.
+func (s *CheckpointStore) InitSchema(ctx context.Context) error {
+	for _, file := range []string{
+		"sql/create_scanner_checkpoints_table.sql",
+		"sql/create_scanner_backfills_table.sql",
+		"sql/create_scanner_listing_gaps_table.sql",
+	} {
+		content, err := schemaFiles.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the last fully processed block for the given chain + contract.
+// It returns sql.ErrNoRows when no checkpoint has been saved yet.
+func (s *CheckpointStore) Get(ctx context.Context, chainID int64, contract string) (uint64, error) {
+	const q = `
+SELECT block_number
+FROM scanner_checkpoints
+WHERE chain_id = ? AND contract = ?`
+
+	var block uint64
+	if err := s.db.QueryRowContext(ctx, q, chainID, contract).Scan(&block); err != nil {
+		return 0, err
+	}
+	return block, nil
+}
+
+// Save creates or updates the checkpoint for the given chain + contract.
+func (s *CheckpointStore) Save(ctx context.Context, chainID int64, contract string, block uint64) error {
+	const q = `
+INSERT INTO scanner_checkpoints (chain_id, contract, block_number)
+VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  block_number = VALUES(block_number);`
+
+	return execFenced(ctx, s.db, q, chainID, contract, block)
+}
+
+// GetBackfill returns the last fully processed block of the backfill that
+// started at fromBlock for the given chain + contract.
+// It returns sql.ErrNoRows when no such backfill has been recorded.
+func (s *CheckpointStore) GetBackfill(ctx context.Context, chainID int64, contract string, fromBlock uint64) (uint64, error) {
+	const q = `
+SELECT last_block
+FROM scanner_backfills
+WHERE chain_id = ? AND contract = ? AND from_block = ?`
+
+	var block uint64
+	if err := s.db.QueryRowContext(ctx, q, chainID, contract, fromBlock).Scan(&block); err != nil {
+		return 0, err
+	}
+	return block, nil
+}
+
+// SaveBackfill creates or updates backfill progress for the given chain + contract + fromBlock.
+func (s *CheckpointStore) SaveBackfill(ctx context.Context, chainID int64, contract string, fromBlock, toBlock, lastBlock uint64) error {
+	const q = `
+INSERT INTO scanner_backfills (chain_id, contract, from_block, to_block, last_block)
+VALUES (?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  to_block = VALUES(to_block),
+  last_block = VALUES(last_block);`
+
+	return execFenced(ctx, s.db, q, chainID, contract, fromBlock, toBlock, lastBlock)
+}
+
+// GetListingGaps returns the listing ID below which the marketplace contract
+// has been checked for gaps on the given chain.
+// It returns sql.ErrNoRows when no check has been recorded.
+func (s *CheckpointStore) GetListingGaps(ctx context.Context, chainID int64, contract string) (uint64, error) {
+	const q = `
+SELECT checked_below
+FROM scanner_listing_gaps
+WHERE chain_id = ? AND contract = ?`
+
+	var below uint64
+	if err := s.db.QueryRowContext(ctx, q, chainID, contract).Scan(&below); err != nil {
+		return 0, err
+	}
+	return below, nil
+}
+
+// SaveListingGaps creates or updates listing gap check progress for the given chain + contract.
+func (s *CheckpointStore) SaveListingGaps(ctx context.Context, chainID int64, contract string, checkedBelow uint64) error {
+	const q = `
+INSERT INTO scanner_listing_gaps (chain_id, contract, checked_below)
+VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  checked_below = VALUES(checked_below);`
+
+	return execFenced(ctx, s.db, q, chainID, contract, checkedBelow)
+}
@@ -0,0 +1,26 @@
+package store
+
+// uint256 values (listing IDs, token IDs, amounts, balances) are stored as
+// canonical decimal strings in VARCHAR(78) columns, since MySQL's DECIMAL
+// stops at 65 digits and 2^256 has 78. Canonical means no sign and no
+// leading zeros, as produced by big.Int.String: equal values are equal
+// strings, and values order numerically by (LENGTH(col), col). Only compare
+// with canonical values: "-1" sorts after "9", not before "0".
+
+// decimalCompare returns a condition comparing the canonical decimal column
+// col with a placeholder value using op (">", ">=", "<" or "<="). Pass the
+// value three times, see decimalArgs.
+func decimalCompare(col, op string) string {
+	return "(LENGTH(" + col + ") " + op[:1] + " LENGTH(?) OR (LENGTH(" + col + ") = LENGTH(?) AND " + col + " " + op + " ?))"
+}
+
+// decimalArgs returns the arguments of a decimalCompare condition.
+func decimalArgs(v string) []any {
+	return []any{v, v, v}
+}
+
+// decimalOrder returns an ORDER BY expression sorting the canonical decimal
+// column col numerically.
+func decimalOrder(col string) string {
+	return "LENGTH(" + col + "), " + col
+}
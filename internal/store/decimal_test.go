@@ -0,0 +1,94 @@
+package store
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+)
+
+func TestDecimalCompareSQL(t *testing.T) {
+	tests := []struct {
+		col, op string
+		want    string
+	}{
+		{"listing_id", ">", "(LENGTH(listing_id) > LENGTH(?) OR (LENGTH(listing_id) = LENGTH(?) AND listing_id > ?))"},
+		{"listing_id", ">=", "(LENGTH(listing_id) > LENGTH(?) OR (LENGTH(listing_id) = LENGTH(?) AND listing_id >= ?))"},
+		{"o.price", "<", "(LENGTH(o.price) < LENGTH(?) OR (LENGTH(o.price) = LENGTH(?) AND o.price < ?))"},
+		{"o.price", "<=", "(LENGTH(o.price) < LENGTH(?) OR (LENGTH(o.price) = LENGTH(?) AND o.price <= ?))"},
+	}
+	for _, tt := range tests {
+		if got := decimalCompare(tt.col, tt.op); got != tt.want {
+			t.Errorf("decimalCompare(%q, %q) = %s, want %s", tt.col, tt.op, got, tt.want)
+		}
+	}
+
+	args := decimalArgs("42")
+	if len(args) != 3 || args[0] != "42" || args[1] != "42" || args[2] != "42" {
+		t.Errorf("decimalArgs(42) = %v, want three copies", args)
+	}
+}
+
+// evalDecimalCompare evaluates a decimalCompare condition the way MySQL
+// does for binary-collated ASCII strings.
+func evalDecimalCompare(col, op, v string) bool {
+	cmp := func(a, b int, op string) bool {
+		switch op {
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		case "<":
+			return a < b
+		default:
+			return a <= b
+		}
+	}
+	strCmp := 0
+	if col < v {
+		strCmp = -1
+	} else if col > v {
+		strCmp = 1
+	}
+	return cmp(len(col), len(v), op[:1]) || (len(col) == len(v) && cmp(strCmp, 0, op))
+}
+
+func TestDecimalCompareIsNumeric(t *testing.T) {
+	values := []string{
+		"0", "1", "9", "10", "11", "99", "100", "12345",
+		"115792089237316195423570985008687907853269984665640564039457584007913129639935", // 2^256-1
+	}
+	for _, col := range values {
+		for _, v := range values {
+			a, _ := new(big.Int).SetString(col, 10)
+			b, _ := new(big.Int).SetString(v, 10)
+			c := a.Cmp(b)
+			want := map[string]bool{">": c > 0, ">=": c >= 0, "<": c < 0, "<=": c <= 0}
+			for op, w := range want {
+				if got := evalDecimalCompare(col, op, v); got != w {
+					t.Errorf("%s %s %s = %v, want %v (%s)", col, op, v, got, w, decimalCompare("col", op))
+				}
+			}
+		}
+	}
+}
+
+func TestDecimalOrder(t *testing.T) {
+	if got, want := decimalOrder("o.listing_id"), "LENGTH(o.listing_id), o.listing_id"; got != want {
+		t.Fatalf("decimalOrder = %q, want %q", got, want)
+	}
+
+	// ORDER BY LENGTH(col), col sorts canonical decimals numerically.
+	values := []string{"100", "9", "0", "10", "99", "1", "1000000000000000000000"}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) < len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	want := []string{"0", "1", "9", "10", "99", "100", "1000000000000000000000"}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Fatalf("sorted = %v, want %v", values, want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// FailedEventStatus is the retry state of a failed contract log.
+type FailedEventStatus string
+
+const (
+	FailedEventStatusPending   FailedEventStatus = "PENDING"   // waiting for the next retry
+	FailedEventStatusResolved  FailedEventStatus = "RESOLVED"  // a retry or replay succeeded
+	FailedEventStatusDead      FailedEventStatus = "DEAD"      // gave up after the maximum number of retries
+	FailedEventStatusDiscarded FailedEventStatus = "DISCARDED" // its block was reorged away
+)
+
+// FailedEvent is a contract log whose handler returned an error. The raw log
+// is kept so it can be handled again without fetching it from the chain.
+type FailedEvent struct {
+	ID          int64             `json:"id"`
+	ChainID     int64             `json:"chain_id"`
+	Contract    string            `json:"contract"`
+	Handler     string            `json:"handler"` // scanner name, e.g. "marketplace scanner"
+	BlockNumber uint64            `json:"block_number"`
+	BlockHash   string            `json:"block_hash"`
+	TxHash      string            `json:"tx_hash"`
+	TxIndex     uint              `json:"tx_index"`
+	LogIndex    uint              `json:"log_index"`
+	Topics      []string          `json:"topics"` // hex
+	Data        string            `json:"data"`   // hex
+	Status      FailedEventStatus `json:"status"`
+	Attempts    int               `json:"attempts"`
+	LastError   string            `json:"last_error,omitempty"`
+	NextRetryAt time.Time         `json:"next_retry_at"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// FailedEventStore wraps access to the failed_events table.
+type FailedEventStore struct {
+	db *sql.DB
+}
+
+// NewFailedEventStore creates a new FailedEventStore.
+func NewFailedEventStore(db *sql.DB) *FailedEventStore {
+	return &FailedEventStore{db: db}
+}
+
+// InitSchema ensures the failed_events table exists.
+func (s *FailedEventStore) InitSchema(ctx context.Context) error {
+	content, err := schemaFiles.ReadFile("sql/create_failed_events_table.sql")
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, string(content))
+	return err
+}
+
+// Record stores ev as PENDING, first retried after delay. When the same log
+// (chain, block hash, log index) is already stored, only its error is
+// updated; a log that had left PENDING (e.g. it was re-scanned after being
+// resolved and failed again) starts a new series of retries.
+func (s *FailedEventStore) Record(ctx context.Context, ev *FailedEvent, delay time.Duration) error {
+	const q = `
+INSERT INTO failed_events (
+  chain_id, contract, handler, block_number, block_hash, tx_hash, tx_index, log_index,
+  topics, data, status, last_error, next_retry_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW() + INTERVAL ? SECOND)
+ON DUPLICATE KEY UPDATE
+  last_error = VALUES(last_error),
+  attempts = IF(status = 'PENDING', attempts, 0),
+  next_retry_at = IF(status = 'PENDING', next_retry_at, VALUES(next_retry_at)),
+  status = 'PENDING'`
+
+	topics, err := json.Marshal(ev.Topics)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, q,
+		ev.ChainID,
+		ev.Contract,
+		ev.Handler,
+		ev.BlockNumber,
+		ev.BlockHash,
+		ev.TxHash,
+		ev.TxIndex,
+		ev.LogIndex,
+		string(topics),
+		ev.Data,
+		FailedEventStatusPending,
+		truncate(ev.LastError, 512),
+		int64(delay/time.Second),
+	)
+	return err
+}
+
+// GetByID returns a failed event by ID (sql.ErrNoRows if it does not exist).
+func (s *FailedEventStore) GetByID(ctx context.Context, id int64) (*FailedEvent, error) {
+	const q = `SELECT ` + failedEventColumns + ` FROM failed_events WHERE id = ?`
+
+	return scanFailedEvent(s.db.QueryRowContext(ctx, q, id))
+}
+
+// ListDue returns up to limit PENDING events whose next retry time has
+// passed, the longest waiting first.
+func (s *FailedEventStore) ListDue(ctx context.Context, limit int) ([]*FailedEvent, error) {
+	const q = `SELECT ` + failedEventColumns + `
+FROM failed_events
+WHERE status = ? AND next_retry_at <= NOW()
+ORDER BY next_retry_at, id
+LIMIT ?`
+
+	return s.query(ctx, q, FailedEventStatusPending, limit)
+}
+
+// List returns failed events, newest first. A zero chainID lists every
+// chain and an empty status every status.
+func (s *FailedEventStore) List(ctx context.Context, chainID int64, status FailedEventStatus, limit, offset int) ([]*FailedEvent, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	const q = `SELECT ` + failedEventColumns + `
+FROM failed_events
+WHERE (? = 0 OR chain_id = ?) AND (? = '' OR status = ?)
+ORDER BY id DESC
+LIMIT ? OFFSET ?`
+
+	return s.query(ctx, q, chainID, chainID, status, status, limit, offset)
+}
+
+// Retry records another failed attempt of a PENDING or DEAD event and
+// schedules the next one after delay (only PENDING events are retried).
+func (s *FailedEventStore) Retry(ctx context.Context, id int64, reason string, delay time.Duration) error {
+	const q = `
+UPDATE failed_events
+SET attempts = attempts + 1, last_error = ?, next_retry_at = NOW() + INTERVAL ? SECOND
+WHERE id = ? AND status IN (?, ?)`
+
+	_, err := s.db.ExecContext(ctx, q, truncate(reason, 512), int64(delay/time.Second), id, FailedEventStatusPending, FailedEventStatusDead)
+	return err
+}
+
+// Resolve moves a PENDING or DEAD event to status.
+func (s *FailedEventStore) Resolve(ctx context.Context, id int64, status FailedEventStatus, reason string) error {
+	const q = `
+UPDATE failed_events
+SET status = ?, attempts = attempts + 1, last_error = ?
+WHERE id = ? AND status IN (?, ?)`
+
+	_, err := s.db.ExecContext(ctx, q, status, truncate(reason, 512), id, FailedEventStatusPending, FailedEventStatusDead)
+	return err
+}
+
+func (s *FailedEventStore) query(ctx context.Context, q string, args ...any) ([]*FailedEvent, error) {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*FailedEvent
+	for rows.Next() {
+		ev, err := scanFailedEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+const failedEventColumns = `
+  id, chain_id, contract, handler, block_number, block_hash, tx_hash, tx_index, log_index,
+  topics, data, status, attempts, last_error, next_retry_at, created_at, updated_at`
+
+func scanFailedEvent(row rowScanner) (*FailedEvent, error) {
+	var ev FailedEvent
+	var topics string
+	if err := row.Scan(
+		&ev.ID,
+		&ev.ChainID,
+		&ev.Contract,
+		&ev.Handler,
+		&ev.BlockNumber,
+		&ev.BlockHash,
+		&ev.TxHash,
+		&ev.TxIndex,
+		&ev.LogIndex,
+		&topics,
+		&ev.Data,
+		&ev.Status,
+		&ev.Attempts,
+		&ev.LastError,
+		&ev.NextRetryAt,
+		&ev.CreatedAt,
+		&ev.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(topics), &ev.Topics); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
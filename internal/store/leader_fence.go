@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrFenced is returned by fenced writes made on behalf of a leader term
+// that has since been superseded by a newer one.
+var ErrFenced = errors.New("leader term superseded")
+
+// LeaderFence is the MySQL side of leader election: every leader term takes
+// a new fencing token from the leader_fences row of its election, and the
+// writes the leader jobs make (order changes, scanner checkpoints) check
+// that the row still holds their token before they commit. A leader that
+// lost its lease without noticing (e.g. paused longer than the lease) can
+// then no longer write once its successor has taken a token.
+//
+// The token is issued by MySQL rather than taken from the Redis term, so it
+// stays monotonic even when Redis loses its data.
+type LeaderFence struct {
+	db   *sql.DB
+	name string
+}
+
+// NewLeaderFence creates the fence of the election name.
+func NewLeaderFence(db *sql.DB, name string) *LeaderFence {
+	return &LeaderFence{db: db, name: name}
+}
+
+// InitSchema ensures the leader_fences table exists.
+func (f *LeaderFence) InitSchema(ctx context.Context) error {
+	content, err := schemaFiles.ReadFile("sql/create_leader_fences_table.sql")
+	if err != nil {
+		return err
+	}
+	_, err = f.db.ExecContext(ctx, string(content))
+	return err
+}
+
+// Next issues the fencing token of a new leader term. Fenced writes of
+// earlier terms that are still in flight finish first; later ones fail with
+// ErrFenced.
+func (f *LeaderFence) Next(ctx context.Context) (int64, error) {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	const q = `
+INSERT INTO leader_fences (name, token)
+VALUES (?, 1)
+ON DUPLICATE KEY UPDATE
+  token = token + 1;`
+
+	if _, err := tx.ExecContext(ctx, q, f.name); err != nil {
+		return 0, err
+	}
+	var token int64
+	if err := tx.QueryRowContext(ctx, `SELECT token FROM leader_fences WHERE name = ?`, f.name).Scan(&token); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	committed = true
+	return token, nil
+}
+
+type fenceKey struct{}
+
+type fenceToken struct {
+	name  string
+	token int64
+}
+
+// WithFence returns a context whose fenced writes only commit while token is
+// the current token of f.
+func WithFence(ctx context.Context, f *LeaderFence, token int64) context.Context {
+	return context.WithValue(ctx, fenceKey{}, fenceToken{name: f.name, token: token})
+}
+
+// checkFence returns ErrFenced when ctx carries a fencing token (see
+// WithFence) that is no longer current. Within a transaction the fence row
+// stays share-locked until commit, so Next waits for the write to finish.
+// Contexts without a token (HTTP handlers, the backfill command) are not
+// fenced.
+func checkFence(ctx context.Context, exec sqlExecutor) error {
+	f, ok := ctx.Value(fenceKey{}).(fenceToken)
+	if !ok {
+		return nil
+	}
+	var token int64
+	err := exec.QueryRowContext(ctx, `SELECT token FROM leader_fences WHERE name = ? LOCK IN SHARE MODE`, f.name).Scan(&token)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: no token issued for %s", ErrFenced, f.name)
+	}
+	if err != nil {
+		return err
+	}
+	if token != f.token {
+		return fmt.Errorf("%w: token %d, current %d", ErrFenced, f.token, token)
+	}
+	return nil
+}
+
+// execFenced runs a single write statement, checking the fence of ctx in
+// the same transaction.
+func execFenced(ctx context.Context, db *sql.DB, q string, args ...any) error {
+	if _, ok := ctx.Value(fenceKey{}).(fenceToken); !ok {
+		_, err := db.ExecContext(ctx, q, args...)
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err := checkFence(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
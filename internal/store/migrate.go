@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+)
+
+// Tables are created from the DDL under sql/ with CREATE TABLE IF NOT EXISTS,
+// which leaves a table created by an older version of that DDL untouched. The
+// helpers below bring such tables up to date from InitSchema. Every step
+// checks information_schema first, so running them again is a no-op.
+
+// columnDef is a column to add to an existing table: its name and its
+// definition, as in the CREATE TABLE file plus the placement.
+type columnDef struct {
+	name string
+	def  string
+}
+
+// tableExists reports whether table exists in the current database.
+func tableExists(ctx context.Context, db *sql.DB, table string) (bool, error) {
+	const q = `
+SELECT COUNT(*)
+FROM information_schema.TABLES
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`
+
+	var n int
+	if err := db.QueryRowContext(ctx, q, table).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// columnType returns the data type (e.g. "bigint") of a column, or "" when
+// the table has no such column.
+func columnType(ctx context.Context, db *sql.DB, table, column string) (string, error) {
+	const q = `
+SELECT DATA_TYPE
+FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+
+	var typ string
+	err := db.QueryRowContext(ctx, q, table, column).Scan(&typ)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return strings.ToLower(typ), err
+}
+
+// indexColumns returns the columns of an index (PRIMARY for the primary key)
+// in index order, or nil when the table has no such index.
+func indexColumns(ctx context.Context, db *sql.DB, table, index string) ([]string, error) {
+	const q = `
+SELECT COLUMN_NAME
+FROM information_schema.STATISTICS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?
+ORDER BY SEQ_IN_INDEX`
+
+	rows, err := db.QueryContext(ctx, q, table, index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		out = append(out, col)
+	}
+	return out, rows.Err()
+}
+
+// addMissingColumns adds the columns of cols that table does not have yet,
+// in order.
+func addMissingColumns(ctx context.Context, db *sql.DB, table string, cols []columnDef) error {
+	for _, c := range cols {
+		typ, err := columnType(ctx, db, table, c.name)
+		if err != nil {
+			return err
+		}
+		if typ != "" {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE `"+table+"` ADD COLUMN `"+c.name+"` "+c.def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureIndex makes the index name of table cover exactly columns, replacing
+// an older definition of the same name in a single ALTER. kind is "PRIMARY
+// KEY" (name "PRIMARY"), "UNIQUE KEY" or "KEY".
+func ensureIndex(ctx context.Context, db *sql.DB, table, kind, name string, columns ...string) error {
+	current, err := indexColumns(ctx, db, table, name)
+	if err != nil {
+		return err
+	}
+	if slices.Equal(current, columns) {
+		return nil
+	}
+
+	add := "ADD " + kind
+	drop := "DROP INDEX `" + name + "`"
+	if kind == "PRIMARY KEY" {
+		drop = "DROP PRIMARY KEY"
+	} else {
+		add += " `" + name + "`"
+	}
+	add += " (`" + strings.Join(columns, "`, `") + "`)"
+
+	stmt := "ALTER TABLE `" + table + "` " + add
+	if current != nil {
+		stmt = "ALTER TABLE `" + table + "` " + drop + ", " + add
+	}
+	_, err = db.ExecContext(ctx, stmt)
+	return err
+}
+
+// modifyToVarchar converts the columns of cols that exist but are not
+// VARCHAR yet (BIGINT in older versions of the DDL) to their definition, in
+// a single ALTER. It is used for the uint256 columns, whose integer values
+// MySQL converts to canonical decimal strings.
+func modifyToVarchar(ctx context.Context, db *sql.DB, table string, cols []columnDef) error {
+	var specs []string
+	for _, c := range cols {
+		typ, err := columnType(ctx, db, table, c.name)
+		if err != nil {
+			return err
+		}
+		if typ == "" || typ == "varchar" {
+			continue
+		}
+		specs = append(specs, "MODIFY COLUMN `"+c.name+"` "+c.def)
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, "ALTER TABLE `"+table+"` "+strings.Join(specs, ", "))
+	return err
+}
+
+// addChainID upgrades a token table created before multi-chain support:
+// chain_id is added as its first column (0 until AssignLegacyRows) and the
+// primary key is rebuilt as primaryKey, which starts with it.
+func addChainID(ctx context.Context, db *sql.DB, table string, primaryKey ...string) error {
+	if err := addMissingColumns(ctx, db, table, []columnDef{
+		{"chain_id", "BIGINT NOT NULL DEFAULT 0 COMMENT 'EVM chain ID of the contract' FIRST"},
+	}); err != nil {
+		return err
+	}
+	return ensureIndex(ctx, db, table, "PRIMARY KEY", "PRIMARY", primaryKey...)
+}
+
+// AssignLegacyRows moves rows written by a single-chain version onto chainID
+// and its marketplace contract: the columns InitSchema adds to existing
+// tables default to chain 0 (NULL for nft_assets) and an empty marketplace,
+// which no query matches. It should be called with the first configured
+// chain and its first marketplace (empty to leave orders' marketplace
+// unset), which is what a single-chain configuration becomes. Rows that
+// would collide with a row already stored for chainID are left as they are.
+// Tables that do not exist are skipped.
+func AssignLegacyRows(ctx context.Context, db *sql.DB, chainID int64, marketplace string) error {
+	type update struct {
+		stmt string
+		args []any
+	}
+	orders := []update{{"UPDATE IGNORE orders SET chain_id = ? WHERE chain_id = 0", []any{chainID}}}
+	if marketplace != "" {
+		orders = append(orders, update{"UPDATE IGNORE orders SET marketplace = ? WHERE chain_id = ? AND marketplace = ''", []any{marketplace, chainID}})
+	}
+	tables := []struct {
+		table   string
+		updates []update
+	}{
+		{"orders", orders},
+		{"nft_assets", []update{{"UPDATE nft_assets SET chain_id = ? WHERE chain_id IS NULL AND nft_address IS NOT NULL", []any{chainID}}}},
+		{"nft_token_owners", []update{{"UPDATE IGNORE nft_token_owners SET chain_id = ? WHERE chain_id = 0", []any{chainID}}}},
+		{"nft_token_balances", []update{{"UPDATE IGNORE nft_token_balances SET chain_id = ? WHERE chain_id = 0", []any{chainID}}}},
+		{"nft_token_uris", []update{{"UPDATE IGNORE nft_token_uris SET chain_id = ? WHERE chain_id = 0", []any{chainID}}}},
+		{"nft_token_approvals", []update{{"UPDATE IGNORE nft_token_approvals SET chain_id = ? WHERE chain_id = 0", []any{chainID}}}},
+		{"nft_operator_approvals", []update{{"UPDATE IGNORE nft_operator_approvals SET chain_id = ? WHERE chain_id = 0", []any{chainID}}}},
+	}
+	for _, t := range tables {
+		ok, err := tableExists(ctx, db, t.table)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for _, u := range t.updates {
+			if _, err := db.ExecContext(ctx, u.stmt, u.args...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
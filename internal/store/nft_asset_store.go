@@ -3,24 +3,27 @@ package store
 import (
 	"context"
 	"database/sql"
-	"os"
+	"encoding/json"
 	"time"
 )
 
 // NftAsset represents a row in the nft_assets table.
 // It mirrors the schema defined in sql/create_nft_assets_table.sql.
 type NftAsset struct {
-	ID         int64     `json:"id"`
-	Name       string    `json:"name"`
-	Owner      string    `json:"owner"`
-	CID        string    `json:"cid"`
-	URL        string    `json:"url"`
-	TokenID    int64     `json:"token_id"`    // 0 means "not minted yet" when NULL in DB
-	NFTAddress string    `json:"nft_address"` // empty when NULL in DB
-	Amount     int64     `json:"amount"`      // 0 when NULL in DB
-	Deleted    int8      `json:"deleted"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         int64           `json:"id"`
+	Name       string          `json:"name"`
+	Owner      string          `json:"owner"`
+	CID        string          `json:"cid"`
+	URL        string          `json:"url"`
+	ChainID    int64           `json:"chain_id"`           // 0 when NULL in DB (not minted yet)
+	TokenID    string          `json:"token_id"`           // canonical decimal string, VARCHAR(78) in DB; empty means "not minted yet" (NULL)
+	NFTAddress string          `json:"nft_address"`        // empty when NULL in DB
+	Amount     string          `json:"amount"`             // canonical decimal string, VARCHAR(78) in DB; empty when NULL
+	TokenURI   string          `json:"token_uri"`          // on-chain URI, empty when NULL in DB
+	Metadata   json.RawMessage `json:"metadata,omitempty"` // JSON fetched from TokenURI, nil if not JSON
+	Deleted    int8            `json:"deleted"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
 }
 
 // NftAssetStore wraps access to the nft_assets table in MySQL.
@@ -35,30 +38,58 @@ func NewNftAssetStore(db *sql.DB) *NftAssetStore {
 
 // InitSchema ensures the nft_assets table exists using the provided SQL file.
 func (s *NftAssetStore) InitSchema(ctx context.Context) error {
-	content, err := os.ReadFile("sql/create_nft_assets_table.sql")
+	content, err := schemaFiles.ReadFile("sql/create_nft_assets_table.sql")
 	if err != nil {
 		return err
 	}
-	_, err = s.db.ExecContext(ctx, string(content))
-	return err
+	if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+		return err
+	}
+	return s.migrate(ctx)
+}
+
+// migrate upgrades an nft_assets table created by an older version: the
+// chain of minted assets (NULL until AssignLegacyRows) and the metadata
+// copied from URI events.
+func (s *NftAssetStore) migrate(ctx context.Context) error {
+	if err := addMissingColumns(ctx, s.db, "nft_assets", []columnDef{
+		{"chain_id", "BIGINT DEFAULT NULL COMMENT 'EVM chain ID the token was minted on' AFTER `url`"},
+		{"token_uri", "VARCHAR(512) DEFAULT NULL COMMENT 'Metadata URI from the on-chain URI event' AFTER `amount`"},
+		{"metadata", "JSON DEFAULT NULL COMMENT 'Metadata fetched from token_uri (NULL if not JSON)' AFTER `token_uri`"},
+		{"metadata_updated_at", "DATETIME DEFAULT NULL COMMENT 'Last time token_uri / metadata were refreshed' AFTER `metadata`"},
+		{"metadata_failed_uri", "VARCHAR(512) DEFAULT NULL COMMENT 'Token URI whose metadata fetch last failed' AFTER `metadata_updated_at`"},
+		{"metadata_attempts", "INT NOT NULL DEFAULT 0 COMMENT 'Failed fetches of metadata_failed_uri in a row' AFTER `metadata_failed_uri`"},
+		{"metadata_next_attempt_at", "DATETIME DEFAULT NULL COMMENT 'No fetch of metadata_failed_uri before this time' AFTER `metadata_attempts`"},
+		{"metadata_error", "VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Last metadata fetch error' AFTER `metadata_next_attempt_at`"},
+	}); err != nil {
+		return err
+	}
+	if err := modifyToVarchar(ctx, s.db, "nft_assets", []columnDef{
+		{"token_id", "VARCHAR(78) DEFAULT NULL COMMENT 'Minted tokenId (ERC721 tokenId or ERC1155 id)'"},
+		{"amount", "VARCHAR(78) DEFAULT NULL COMMENT 'Minted amount (1 for ERC721, >=1 for ERC1155)'"},
+	}); err != nil {
+		return err
+	}
+	return ensureIndex(ctx, s.db, "nft_assets", "KEY", "idx_nft_assets_token", "chain_id", "nft_address", "token_id")
 }
 
 // Insert creates a new nft_assets row. It returns the auto-incremented ID.
 func (s *NftAssetStore) Insert(ctx context.Context, a *NftAsset) (int64, error) {
 	const q = `
 INSERT INTO nft_assets (
-  name, owner, cid, url, token_id, nft_address, amount, deleted
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+  name, owner, cid, url, chain_id, token_id, nft_address, amount, deleted
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	res, err := s.db.ExecContext(ctx, q,
 		a.Name,
 		a.Owner,
 		a.CID,
 		a.URL,
-		// token_id, nft_address, amount can be nil in DB; here we treat zero/empty as "not set".
-		sql.NullInt64{Int64: a.TokenID, Valid: a.TokenID != 0},
+		// chain_id, token_id, nft_address, amount can be nil in DB; here we treat zero/empty as "not set".
+		sql.NullInt64{Int64: a.ChainID, Valid: a.ChainID != 0},
+		sql.NullString{String: a.TokenID, Valid: a.TokenID != ""},
 		sql.NullString{String: a.NFTAddress, Valid: a.NFTAddress != ""},
-		sql.NullInt64{Int64: a.Amount, Valid: a.Amount != 0},
+		sql.NullString{String: a.Amount, Valid: a.Amount != ""},
 		a.Deleted,
 	)
 	if err != nil {
@@ -80,9 +111,12 @@ SELECT
   owner,
   cid,
   url,
-  IFNULL(token_id, 0)    AS token_id,
+  IFNULL(chain_id, 0)    AS chain_id,
+  IFNULL(token_id, '')   AS token_id,
   IFNULL(nft_address, '') AS nft_address,
-  IFNULL(amount, 0)      AS amount,
+  IFNULL(amount, '')     AS amount,
+  IFNULL(token_uri, '')  AS token_uri,
+  metadata,
   deleted,
   created_at,
   updated_at
@@ -97,9 +131,12 @@ WHERE id = ?`
 		&a.Owner,
 		&a.CID,
 		&a.URL,
+		&a.ChainID,
 		&a.TokenID,
 		&a.NFTAddress,
 		&a.Amount,
+		&a.TokenURI,
+		(*[]byte)(&a.Metadata), // NULL scans as nil
 		&a.Deleted,
 		&a.CreatedAt,
 		&a.UpdatedAt,
@@ -110,29 +147,68 @@ WHERE id = ?`
 }
 
 // ListByOwner returns recent, undeleted assets owned by the specified address.
-func (s *NftAssetStore) ListByOwner(ctx context.Context, owner string, limit int) ([]*NftAsset, error) {
+//
+// ERC1155 tokens tracked in nft_token_balances are listed per holder instead:
+// every asset the address holds a positive balance of is returned with the
+// holder as owner and its balance as amount. Such an asset is still hidden
+// from its nft_assets owner while deleted (i.e. listed), as for ERC721.
+//
+// A non-zero chainID restricts the result to assets minted on that chain.
+func (s *NftAssetStore) ListByOwner(ctx context.Context, chainID int64, owner string, limit int) ([]*NftAsset, error) {
 	if limit <= 0 {
 		limit = 50
 	}
 	const q = `
-SELECT
-  id,
-  name,
-  owner,
-  cid,
-  url,
-  IFNULL(token_id, 0)    AS token_id,
-  IFNULL(nft_address, '') AS nft_address,
-  IFNULL(amount, 0)      AS amount,
-  deleted,
-  created_at,
-  updated_at
-FROM nft_assets
-WHERE owner = ? AND deleted = 0
+SELECT * FROM (
+  SELECT
+    a.id,
+    a.name,
+    a.owner,
+    a.cid,
+    a.url,
+    IFNULL(a.chain_id, 0)    AS chain_id,
+    IFNULL(a.token_id, '')   AS token_id,
+    IFNULL(a.nft_address, '') AS nft_address,
+    IFNULL(a.amount, '')     AS amount,
+    IFNULL(a.token_uri, '')  AS token_uri,
+    a.metadata,
+    a.deleted,
+    a.created_at,
+    a.updated_at
+  FROM nft_assets a
+  WHERE a.owner = ? AND a.deleted = 0
+    AND (? = 0 OR a.chain_id = ?)
+    AND NOT EXISTS (
+      SELECT 1 FROM nft_token_balances b
+      WHERE b.chain_id = a.chain_id AND b.nft_address = a.nft_address AND b.token_id = a.token_id
+    )
+  UNION ALL
+  SELECT
+    a.id,
+    a.name,
+    b.holder                  AS owner,
+    a.cid,
+    a.url,
+    b.chain_id,
+    b.token_id,
+    b.nft_address,
+    b.balance                 AS amount,
+    IFNULL(a.token_uri, '')  AS token_uri,
+    a.metadata,
+    a.deleted,
+    a.created_at,
+    GREATEST(a.updated_at, b.updated_at) AS updated_at
+  FROM nft_token_balances b
+  JOIN nft_assets a
+    ON a.chain_id = b.chain_id AND a.nft_address = b.nft_address AND a.token_id = b.token_id
+  WHERE b.holder = ? AND b.balance <> '0' AND b.balance NOT LIKE '-%'
+    AND (? = 0 OR b.chain_id = ?)
+    AND (a.deleted = 0 OR a.owner <> b.holder)
+) t
 ORDER BY updated_at DESC
 LIMIT ?`
 
-	rows, err := s.db.QueryContext(ctx, q, owner, limit)
+	rows, err := s.db.QueryContext(ctx, q, owner, chainID, chainID, owner, chainID, chainID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -147,9 +223,12 @@ LIMIT ?`
 			&a.Owner,
 			&a.CID,
 			&a.URL,
+			&a.ChainID,
 			&a.TokenID,
 			&a.NFTAddress,
 			&a.Amount,
+			&a.TokenURI,
+			(*[]byte)(&a.Metadata), // NULL scans as nil
 			&a.Deleted,
 			&a.CreatedAt,
 			&a.UpdatedAt,
@@ -180,82 +259,110 @@ LIMIT 1`
 	return true, nil
 }
 
-// SoftDeleteByNFT marks an asset row as deleted (deleted = 1) by nft_address + token_id.
+// SoftDeleteByNFT marks an asset row as deleted (deleted = 1) by chain_id + nft_address + token_id.
 // This is used when an NFT has been listed and is now由订单管理，不再作为“可用素材”展示。
-func (s *NftAssetStore) SoftDeleteByNFT(ctx context.Context, nftAddress string, tokenID int64) error {
-	return softDeleteByNFT(ctx, s.db, nftAddress, tokenID)
+func (s *NftAssetStore) SoftDeleteByNFT(ctx context.Context, chainID int64, nftAddress, tokenID string) error {
+	return softDeleteByNFT(ctx, s.db, chainID, nftAddress, tokenID)
 }
 
 // SoftDeleteByNFTTx is the transactional variant of SoftDeleteByNFT.
-func (s *NftAssetStore) SoftDeleteByNFTTx(ctx context.Context, tx *sql.Tx, nftAddress string, tokenID int64) error {
-	return softDeleteByNFT(ctx, tx, nftAddress, tokenID)
+func (s *NftAssetStore) SoftDeleteByNFTTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, tokenID string) error {
+	return softDeleteByNFT(ctx, tx, chainID, nftAddress, tokenID)
 }
 
-func softDeleteByNFT(ctx context.Context, exec sqlExecutor, nftAddress string, tokenID int64) error {
+func softDeleteByNFT(ctx context.Context, exec sqlExecutor, chainID int64, nftAddress, tokenID string) error {
 	const q = `
 UPDATE nft_assets
 SET deleted = 1
-WHERE nft_address = ? AND token_id = ?`
+WHERE chain_id = ? AND nft_address = ? AND token_id = ?`
 
-	_, err := exec.ExecContext(ctx, q, nftAddress, tokenID)
+	_, err := exec.ExecContext(ctx, q, chainID, nftAddress, tokenID)
 	return err
 }
 
-// RestoreByNFT cancels logical deletion (deleted = 0) for a row matched by nft_address + token_id.
+// RestoreByNFT cancels logical deletion (deleted = 0) for a row matched by chain_id + nft_address + token_id.
 // 用于挂单取消后恢复到“我的素材”列表。
-func (s *NftAssetStore) RestoreByNFT(ctx context.Context, nftAddress string, tokenID int64) error {
-	return restoreByNFT(ctx, s.db, nftAddress, tokenID)
+func (s *NftAssetStore) RestoreByNFT(ctx context.Context, chainID int64, nftAddress, tokenID string) error {
+	return restoreByNFT(ctx, s.db, chainID, nftAddress, tokenID)
 }
 
 // RestoreByNFTTx is the transactional variant of RestoreByNFT.
-func (s *NftAssetStore) RestoreByNFTTx(ctx context.Context, tx *sql.Tx, nftAddress string, tokenID int64) error {
-	return restoreByNFT(ctx, tx, nftAddress, tokenID)
+func (s *NftAssetStore) RestoreByNFTTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, tokenID string) error {
+	return restoreByNFT(ctx, tx, chainID, nftAddress, tokenID)
 }
 
-func restoreByNFT(ctx context.Context, exec sqlExecutor, nftAddress string, tokenID int64) error {
+func restoreByNFT(ctx context.Context, exec sqlExecutor, chainID int64, nftAddress, tokenID string) error {
 	const q = `
 UPDATE nft_assets
 SET deleted = 0
-WHERE nft_address = ? AND token_id = ?`
+WHERE chain_id = ? AND nft_address = ? AND token_id = ?`
 
-	_, err := exec.ExecContext(ctx, q, nftAddress, tokenID)
+	_, err := exec.ExecContext(ctx, q, chainID, nftAddress, tokenID)
 	return err
 }
 
 // UpdateOwnerByNFT updates the owner of a given on-chain NFT and clears deleted flag.
 // 用于成交后，把 NFT 的归属从卖家切换到买家，并让其出现在买家的素材列表中。
-func (s *NftAssetStore) UpdateOwnerByNFT(ctx context.Context, nftAddress string, tokenID int64, newOwner string) error {
-	return updateOwnerByNFT(ctx, s.db, nftAddress, tokenID, newOwner)
+func (s *NftAssetStore) UpdateOwnerByNFT(ctx context.Context, chainID int64, nftAddress, tokenID string, newOwner string) error {
+	return updateOwnerByNFT(ctx, s.db, chainID, nftAddress, tokenID, newOwner)
 }
 
 // UpdateOwnerByNFTTx is the transactional variant of UpdateOwnerByNFT.
-func (s *NftAssetStore) UpdateOwnerByNFTTx(ctx context.Context, tx *sql.Tx, nftAddress string, tokenID int64, newOwner string) error {
-	return updateOwnerByNFT(ctx, tx, nftAddress, tokenID, newOwner)
+func (s *NftAssetStore) UpdateOwnerByNFTTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, tokenID string, newOwner string) error {
+	return updateOwnerByNFT(ctx, tx, chainID, nftAddress, tokenID, newOwner)
 }
 
-func updateOwnerByNFT(ctx context.Context, exec sqlExecutor, nftAddress string, tokenID int64, newOwner string) error {
+func updateOwnerByNFT(ctx context.Context, exec sqlExecutor, chainID int64, nftAddress, tokenID string, newOwner string) error {
 	const q = `
 UPDATE nft_assets
 SET owner = ?, deleted = 0
-WHERE nft_address = ? AND token_id = ?`
+WHERE chain_id = ? AND nft_address = ? AND token_id = ?`
 
-	_, err := exec.ExecContext(ctx, q, newOwner, nftAddress, tokenID)
+	_, err := exec.ExecContext(ctx, q, newOwner, chainID, nftAddress, tokenID)
 	return err
 }
 
-// UpdateMintInfo updates token_id, nft_address and amount after on-chain mint.
-func (s *NftAssetStore) UpdateMintInfo(ctx context.Context, id int64, tokenID int64, nftAddress string, amount int64) error {
+// UpdateMintInfo updates chain_id, token_id, nft_address and amount after on-chain mint.
+func (s *NftAssetStore) UpdateMintInfo(ctx context.Context, id int64, chainID int64, tokenID, nftAddress, amount string) error {
 	const q = `
 UPDATE nft_assets
-SET token_id = ?, nft_address = ?, amount = ?
+SET chain_id = ?, token_id = ?, nft_address = ?, amount = ?
 WHERE id = ? AND deleted = 0`
 
-	_, err := s.db.ExecContext(ctx, q, tokenID, nftAddress, amount, id)
+	_, err := s.db.ExecContext(ctx, q, chainID, tokenID, nftAddress, amount, id)
+	return err
+}
+
+// UpdateMetadata stores the on-chain token URI of an asset together with the
+// metadata fetched from it (nil when the URI does not point to JSON), and
+// clears recorded fetch failures.
+func (s *NftAssetStore) UpdateMetadata(ctx context.Context, id int64, tokenURI string, metadata []byte) error {
+	const q = `
+UPDATE nft_assets
+SET token_uri = ?, metadata = ?, metadata_updated_at = NOW(),
+  metadata_failed_uri = NULL, metadata_attempts = 0, metadata_next_attempt_at = NULL, metadata_error = ''
+WHERE id = ?`
+
+	_, err := s.db.ExecContext(ctx, q, tokenURI, metadata, id)
+	return err
+}
+
+// RecordMetadataFailure records that fetching the metadata behind tokenURI
+// failed for the attempts-th time in a row, and that it should not be tried
+// again before retryAfter has passed.
+func (s *NftAssetStore) RecordMetadataFailure(ctx context.Context, id int64, tokenURI string, attempts int, retryAfter time.Duration, reason string) error {
+	const q = `
+UPDATE nft_assets
+SET metadata_failed_uri = ?, metadata_attempts = ?,
+  metadata_next_attempt_at = DATE_ADD(NOW(), INTERVAL ? SECOND), metadata_error = ?
+WHERE id = ?`
+
+	_, err := s.db.ExecContext(ctx, q, tokenURI, attempts, int64(retryAfter/time.Second), truncate(reason, 255), id)
 	return err
 }
 
-// GetByNFT returns an asset matched by nft_address + token_id.
-func (s *NftAssetStore) GetByNFT(ctx context.Context, nftAddress string, tokenID int64) (*NftAsset, error) {
+// GetByNFT returns an asset matched by chain_id + nft_address + token_id.
+func (s *NftAssetStore) GetByNFT(ctx context.Context, chainID int64, nftAddress, tokenID string) (*NftAsset, error) {
 	const q = `
 SELECT
   id,
@@ -263,17 +370,20 @@ SELECT
   owner,
   cid,
   url,
-  IFNULL(token_id, 0)     AS token_id,
+  IFNULL(chain_id, 0)     AS chain_id,
+  IFNULL(token_id, '')    AS token_id,
   IFNULL(nft_address, '') AS nft_address,
-  IFNULL(amount, 0)       AS amount,
+  IFNULL(amount, '')      AS amount,
+  IFNULL(token_uri, '')   AS token_uri,
+  metadata,
   deleted,
   created_at,
   updated_at
 FROM nft_assets
-WHERE nft_address = ? AND token_id = ?
+WHERE chain_id = ? AND nft_address = ? AND token_id = ?
 LIMIT 1`
 
-	row := s.db.QueryRowContext(ctx, q, nftAddress, tokenID)
+	row := s.db.QueryRowContext(ctx, q, chainID, nftAddress, tokenID)
 	var a NftAsset
 	if err := row.Scan(
 		&a.ID,
@@ -281,9 +391,12 @@ LIMIT 1`
 		&a.Owner,
 		&a.CID,
 		&a.URL,
+		&a.ChainID,
 		&a.TokenID,
 		&a.NFTAddress,
 		&a.Amount,
+		&a.TokenURI,
+		(*[]byte)(&a.Metadata), // NULL scans as nil
 		&a.Deleted,
 		&a.CreatedAt,
 		&a.UpdatedAt,
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Order event types recorded in order_events.
+const (
+	OrderEventListed           = "Listed"
+	OrderEventCancelled        = "Cancelled"
+	OrderEventSold             = "Sold"
+	OrderEventApprovalRevoked  = "ApprovalRevoked"
+	OrderEventApprovalRestored = "ApprovalRestored"
+
+	// Progress of a buy / cancel transaction followed by the tx tracker.
+	OrderEventTxSubmitted = "TxSubmitted"
+	OrderEventTxMined     = "TxMined"
+	OrderEventTxFailed    = "TxFailed"
+
+	// OrderEventReconciled records fields the listing reconciler copied from
+	// the marketplace's listings() view.
+	OrderEventReconciled = "Reconciled"
+)
+
+// Sources of order events.
+const (
+	OrderEventSourceChain      = "chain"      // applied by a scanner from a contract log
+	OrderEventSourceCallback   = "callback"   // reported by the frontend through the API
+	OrderEventSourceTracker    = "tracker"    // receipt of a transaction the frontend broadcast
+	OrderEventSourceReconciler = "reconciler" // drift repaired from the listings() view
+)
+
+// OrderEvent is one entry of an order's history. Rows are only ever
+// appended, in the same transaction as the order change they describe; the
+// only later change is flagging chain events whose block was reorged away.
+type OrderEvent struct {
+	ID          int64           `json:"id"`
+	ChainID     int64           `json:"chain_id"`
+	Marketplace string          `json:"marketplace"`
+	ListingID   string          `json:"listing_id"`
+	Type        string          `json:"type"`
+	Source      string          `json:"source"`
+	Status      OrderStatus     `json:"status"` // order status after the event
+	Actor       string          `json:"actor,omitempty"`
+	TxHash      string          `json:"tx_hash,omitempty"`
+	Chain       *ChainEvent     `json:"chain,omitempty"` // nil for callbacks
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Removed     bool            `json:"removed"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AppendEventTx records e within the given transaction, which should be the
+// one that applies the change to the order.
+func (s *OrderStore) AppendEventTx(ctx context.Context, tx *sql.Tx, e *OrderEvent) error {
+	const q = `
+INSERT INTO order_events (
+  chain_id, marketplace, listing_id, event_type, source, status, actor,
+  tx_hash, block_number, log_index, block_hash, block_time, payload
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var blockNumber, logIndex, blockHash, blockTime any
+	if c := e.Chain; c != nil {
+		blockNumber, logIndex, blockHash = c.BlockNumber, c.LogIndex, c.BlockHash
+		if !c.BlockTime.IsZero() {
+			blockTime = c.BlockTime.UTC()
+		}
+	}
+	var payload any
+	if len(e.Payload) > 0 {
+		payload = string(e.Payload)
+	}
+
+	_, err := tx.ExecContext(ctx, q,
+		e.ChainID,
+		e.Marketplace,
+		e.ListingID,
+		e.Type,
+		e.Source,
+		e.Status,
+		e.Actor,
+		e.TxHash,
+		blockNumber,
+		logIndex,
+		blockHash,
+		blockTime,
+		payload,
+	)
+	return err
+}
+
+// ListEvents returns the history of a listing, oldest first.
+func (s *OrderStore) ListEvents(ctx context.Context, chainID int64, marketplace, listingID string) ([]*OrderEvent, error) {
+	const q = `
+SELECT
+  id, chain_id, marketplace, listing_id, event_type, source, status,
+  actor, tx_hash,
+  block_number, log_index, IFNULL(block_hash, ''), block_time,
+  payload, removed, created_at
+FROM order_events
+WHERE chain_id = ? AND marketplace = ? AND listing_id = ?
+ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, q, chainID, marketplace, listingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*OrderEvent
+	for rows.Next() {
+		var e OrderEvent
+		var blockNumber, logIndex sql.NullInt64
+		var blockHash string
+		var blockTime sql.NullTime
+		var payload sql.NullString
+		if err := rows.Scan(
+			&e.ID,
+			&e.ChainID,
+			&e.Marketplace,
+			&e.ListingID,
+			&e.Type,
+			&e.Source,
+			&e.Status,
+			&e.Actor,
+			&e.TxHash,
+			&blockNumber,
+			&logIndex,
+			&blockHash,
+			&blockTime,
+			&payload,
+			&e.Removed,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if blockNumber.Valid {
+			e.Chain = &ChainEvent{
+				TxHash:      e.TxHash,
+				BlockNumber: uint64(blockNumber.Int64),
+				LogIndex:    uint(logIndex.Int64),
+				BlockHash:   blockHash,
+				BlockTime:   blockTime.Time,
+			}
+		}
+		if payload.Valid {
+			e.Payload = json.RawMessage(payload.String)
+		}
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
+
+// markOrderEventsRemoved flags the Listed / Cancelled / Sold events of a
+// marketplace contract in blocks above block, after those blocks were reorged
+// away. Approval events come from the NFT scanners, which do not roll back.
+func markOrderEventsRemoved(ctx context.Context, exec sqlExecutor, chainID int64, marketplace string, block uint64) error {
+	const q = `
+UPDATE order_events SET removed = 1
+WHERE chain_id = ? AND marketplace = ? AND event_type IN (?, ?, ?) AND block_number > ?`
+
+	_, err := exec.ExecContext(ctx, q, chainID, marketplace,
+		OrderEventListed, OrderEventCancelled, OrderEventSold, block)
+	return err
+}
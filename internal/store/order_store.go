@@ -3,7 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
-	"os"
+	"strings"
 	"time"
 )
 
@@ -18,25 +18,68 @@ const (
 	OrderStatusSuccess  OrderStatus = "SUCCESS"
 	OrderStatusFailed   OrderStatus = "FAILED"
 	OrderStatusCanceled OrderStatus = "CANCELED"
+
+	// OrderStatusUnfillable marks a listing whose seller no longer approves
+	// the marketplace to transfer the NFT. It returns to LISTED when the
+	// approval is granted again.
+	OrderStatusUnfillable OrderStatus = "UNFILLABLE"
+
+	// OrderStatusPending marks marketplace events that are not confirmed yet.
+	// It is only reported by the pending events API and never stored.
+	OrderStatusPending OrderStatus = "PENDING"
 )
 
 // Order mirrors a listing on-chain and is updated via contract events.
 type Order struct {
-	OrderID    int64       `json:"order_id"`
-	ListingID  int64       `json:"listing_id"`
-	Seller     string      `json:"seller"`
-	Buyer      string      `json:"buyer"`
-	NFTName    string      `json:"nft_name"`
-	NFTAddress string      `json:"nft_address"`
-	URL        string      `json:"url"`
-	TokenID    int64       `json:"token_id"`
-	Amount     int64       `json:"amount"`
-	Price      string      `json:"price"` // wei, matches DECIMAL(36,0)
-	Status     OrderStatus `json:"status"`
-	TxHash     string      `json:"tx_hash"`
-	Deleted    int8        `json:"deleted"`
-	CreatedAt  time.Time   `json:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at"`
+	OrderID     int64       `json:"order_id"`
+	ChainID     int64       `json:"chain_id"`
+	Marketplace string      `json:"marketplace"` // marketplace contract the listing belongs to
+	ListingID   string      `json:"listing_id"`  // canonical decimal string, VARCHAR(78) in DB
+	Seller      string      `json:"seller"`
+	Buyer       string      `json:"buyer"`
+	NFTName     string      `json:"nft_name"`
+	NFTAddress  string      `json:"nft_address"`
+	URL         string      `json:"url"`
+	TokenID     string      `json:"token_id"` // canonical decimal string, VARCHAR(78) in DB
+	Amount      string      `json:"amount"`   // canonical decimal string, VARCHAR(78) in DB
+	Price       string      `json:"price"`    // wei, matches DECIMAL(36,0)
+	Status      OrderStatus `json:"status"`
+	TxHash      string      `json:"tx_hash"`
+	Deleted     int8        `json:"deleted"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+
+	// BlockNumber / LogIndex locate the last marketplace event applied to
+	// the order (0 / 0 before the scanner saw any). Events at or before this
+	// position are not applied again, so replays cannot move an order back.
+	BlockNumber uint64 `json:"block_number"`
+	LogIndex    uint   `json:"log_index"`
+
+	// Listed, Canceled and Sold record where each event happened on-chain.
+	// They are nil until the scanner applied the event.
+	Listed   *ChainEvent `json:"listed,omitempty"`
+	Canceled *ChainEvent `json:"canceled,omitempty"`
+	Sold     *ChainEvent `json:"sold,omitempty"`
+}
+
+// ChainEvent is the on-chain position and time of a marketplace event.
+// BlockTime is the block timestamp, not the time the row was written.
+type ChainEvent struct {
+	TxHash      string    `json:"tx_hash"`
+	BlockNumber uint64    `json:"block_number"`
+	LogIndex    uint      `json:"log_index"`
+	BlockHash   string    `json:"block_hash"`
+	BlockTime   time.Time `json:"block_time"`
+}
+
+// KeepChainEvents copies the chain positions recorded on prev into o, for
+// writers that rebuild an order row without knowing them.
+func (o *Order) KeepChainEvents(prev *Order) {
+	o.BlockNumber = prev.BlockNumber
+	o.LogIndex = prev.LogIndex
+	o.Listed = prev.Listed
+	o.Canceled = prev.Canceled
+	o.Sold = prev.Sold
 }
 
 // OrderStore wraps access to the orders table in MySQL.
@@ -49,15 +92,63 @@ func NewOrderStore(db *sql.DB) *OrderStore {
 	return &OrderStore{db: db}
 }
 
-// InitSchema ensures the orders table exists.
+// InitSchema ensures the orders and order_events tables exist.
 func (s *OrderStore) InitSchema(ctx context.Context) error {
-	// Use the DDL from the provided SQL file to ensure schema matches exactly.
-	content, err := os.ReadFile("sql/create_orders_table.sql")
-	if err != nil {
+	// Use the DDL from the provided SQL files to ensure schema matches exactly.
+	for _, file := range []string{
+		"sql/create_orders_table.sql",
+		"sql/create_order_events_table.sql",
+	} {
+		content, err := schemaFiles.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+			return err
+		}
+	}
+	return s.migrate(ctx)
+}
+
+// migrate upgrades an orders table created by an older version: the chain
+// and marketplace a listing belongs to (rows already stored keep chain 0 and
+// an empty marketplace until AssignLegacyRows), the chain position of the
+// applied events and unique keys scoped to them.
+func (s *OrderStore) migrate(ctx context.Context) error {
+	cols := []columnDef{
+		{"chain_id", "BIGINT NOT NULL DEFAULT 0 COMMENT 'EVM chain ID the listing lives on' AFTER `order_id`"},
+		{"marketplace", "VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'NFTMarketplace contract address the listing belongs to' AFTER `chain_id`"},
+		{"block_number", "BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Block of the last applied marketplace event, 0 = none'"},
+		{"log_index", "INT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Log index of the last applied marketplace event'"},
+	}
+	for _, ev := range []struct{ prefix, name string }{{"listed", "Listed"}, {"canceled", "Cancelled"}, {"sold", "Sold"}} {
+		cols = append(cols,
+			columnDef{ev.prefix + "_tx_hash", "VARCHAR(100) DEFAULT NULL COMMENT '" + ev.name + " event transaction hash'"},
+			columnDef{ev.prefix + "_block_number", "BIGINT UNSIGNED DEFAULT NULL COMMENT '" + ev.name + " event block number'"},
+			columnDef{ev.prefix + "_log_index", "INT UNSIGNED DEFAULT NULL COMMENT '" + ev.name + " event log index in the block'"},
+			columnDef{ev.prefix + "_block_hash", "VARCHAR(66) DEFAULT NULL COMMENT '" + ev.name + " event block hash'"},
+			columnDef{ev.prefix + "_at", "DATETIME DEFAULT NULL COMMENT '" + ev.name + " event block timestamp (UTC)'"},
+		)
+	}
+	if err := addMissingColumns(ctx, s.db, "orders", cols); err != nil {
 		return err
 	}
-	_, err = s.db.ExecContext(ctx, string(content))
-	return err
+	if err := modifyToVarchar(ctx, s.db, "orders", []columnDef{
+		{"listing_id", "VARCHAR(78) DEFAULT NULL COMMENT 'On-chain Marketplace listingId'"},
+		{"token_id", "VARCHAR(78) NOT NULL COMMENT 'NFT tokenId'"},
+		{"amount", "VARCHAR(78) NOT NULL COMMENT 'Amount (ERC1155)'"},
+	}); err != nil {
+		return err
+	}
+	if err := modifyToVarchar(ctx, s.db, "order_events", []columnDef{
+		{"listing_id", "VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId'"},
+	}); err != nil {
+		return err
+	}
+	if err := ensureIndex(ctx, s.db, "orders", "UNIQUE KEY", "uk_orders_listing_id", "chain_id", "marketplace", "listing_id"); err != nil {
+		return err
+	}
+	return ensureIndex(ctx, s.db, "orders", "UNIQUE KEY", "uk_orders_tx_hash", "chain_id", "tx_hash")
 }
 
 // Upsert creates or updates an order row.
@@ -73,9 +164,13 @@ func (s *OrderStore) UpsertTx(ctx context.Context, tx *sql.Tx, o *Order) error {
 func upsertOrder(ctx context.Context, exec sqlExecutor, o *Order) error {
 	const q = `
 INSERT INTO orders (
-  listing_id, seller, buyer, nft_name, nft_address,
-  url, token_id, amount, price, status, tx_hash, deleted
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  chain_id, marketplace, listing_id, seller, buyer, nft_name, nft_address,
+  url, token_id, amount, price, status, tx_hash, deleted,
+  block_number, log_index,
+  listed_tx_hash, listed_block_number, listed_log_index, listed_block_hash, listed_at,
+  canceled_tx_hash, canceled_block_number, canceled_log_index, canceled_block_hash, canceled_at,
+  sold_tx_hash, sold_block_number, sold_log_index, sold_block_hash, sold_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON DUPLICATE KEY UPDATE
   seller = VALUES(seller),
   buyer = VALUES(buyer),
@@ -87,40 +182,109 @@ ON DUPLICATE KEY UPDATE
   price = VALUES(price),
   status = VALUES(status),
   tx_hash = VALUES(tx_hash),
-  deleted = VALUES(deleted);`
+  deleted = VALUES(deleted),
+  block_number = VALUES(block_number),
+  log_index = VALUES(log_index),
+  listed_tx_hash = VALUES(listed_tx_hash),
+  listed_block_number = VALUES(listed_block_number),
+  listed_log_index = VALUES(listed_log_index),
+  listed_block_hash = VALUES(listed_block_hash),
+  listed_at = VALUES(listed_at),
+  canceled_tx_hash = VALUES(canceled_tx_hash),
+  canceled_block_number = VALUES(canceled_block_number),
+  canceled_log_index = VALUES(canceled_log_index),
+  canceled_block_hash = VALUES(canceled_block_hash),
+  canceled_at = VALUES(canceled_at),
+  sold_tx_hash = VALUES(sold_tx_hash),
+  sold_block_number = VALUES(sold_block_number),
+  sold_log_index = VALUES(sold_log_index),
+  sold_block_hash = VALUES(sold_block_hash),
+  sold_at = VALUES(sold_at);`
 
-	_, err := exec.ExecContext(ctx, q,
+	args := []any{
+		o.ChainID,
+		o.Marketplace,
 		o.ListingID,
 		o.Seller,
 		o.Buyer,
 		o.NFTName,
 		o.NFTAddress,
 		o.URL,
-		o.TokenID,
-		o.Amount,
-		o.Price,
+		decimalOrZero(o.TokenID),
+		decimalOrZero(o.Amount),
+		decimalOrZero(o.Price),
 		o.Status,
-		o.TxHash,
+		sql.NullString{String: o.TxHash, Valid: o.TxHash != ""}, // rows without a tx must not collide on uk_orders_tx_hash
 		o.Deleted,
-	)
+		o.BlockNumber,
+		o.LogIndex,
+	}
+	args = append(args, chainEventArgs(o.Listed)...)
+	args = append(args, chainEventArgs(o.Canceled)...)
+	args = append(args, chainEventArgs(o.Sold)...)
+
+	if err := checkFence(ctx, exec); err != nil {
+		return err
+	}
+	_, err := exec.ExecContext(ctx, q, args...)
 	return err
 }
 
-// GetByID returns a single order by listing ID.
-func (s *OrderStore) GetByID(ctx context.Context, listingID int64) (*Order, error) {
-	return getOrderByID(ctx, s.db, listingID, false)
+// decimalOrZero returns v, or "0" when v is empty: rows created from a
+// Cancelled / Sold event alone do not know the listing's token and price.
+func decimalOrZero(v string) string {
+	if v == "" {
+		return "0"
+	}
+	return v
 }
 
-// GetByIDForUpdateTx returns a single order by listing ID and locks the row
-// for update within the given transaction.
-func (s *OrderStore) GetByIDForUpdateTx(ctx context.Context, tx *sql.Tx, listingID int64) (*Order, error) {
-	return getOrderByID(ctx, tx, listingID, true)
+// chainEventArgs returns the five column values of e, all NULL when e is nil.
+func chainEventArgs(e *ChainEvent) []any {
+	if e == nil {
+		return []any{nil, nil, nil, nil, nil}
+	}
+	return []any{e.TxHash, e.BlockNumber, e.LogIndex, e.BlockHash, e.BlockTime.UTC()}
 }
 
-func getOrderByID(ctx context.Context, exec sqlExecutor, listingID int64, forUpdate bool) (*Order, error) {
-	const baseQuery = `
-SELECT
+func deleteOrderByListingID(ctx context.Context, exec sqlExecutor, chainID int64, marketplace, listingID string) error {
+	const q = `DELETE FROM orders WHERE chain_id = ? AND marketplace = ? AND listing_id = ?`
+
+	if err := checkFence(ctx, exec); err != nil {
+		return err
+	}
+	_, err := exec.ExecContext(ctx, q, chainID, marketplace, listingID)
+	return err
+}
+
+// GetByID returns a single order by chain, marketplace contract and listing ID.
+func (s *OrderStore) GetByID(ctx context.Context, chainID int64, marketplace, listingID string) (*Order, error) {
+	return getOrderByID(ctx, s.db, chainID, marketplace, listingID, false)
+}
+
+// GetByIDForUpdateTx returns a single order by chain, marketplace contract and
+// listing ID and locks the row for update within the given transaction.
+func (s *OrderStore) GetByIDForUpdateTx(ctx context.Context, tx *sql.Tx, chainID int64, marketplace, listingID string) (*Order, error) {
+	return getOrderByID(ctx, tx, chainID, marketplace, listingID, true)
+}
+
+func getOrderByID(ctx context.Context, exec sqlExecutor, chainID int64, marketplace, listingID string, forUpdate bool) (*Order, error) {
+	const baseQuery = `SELECT ` + orderColumns + `
+FROM orders WHERE chain_id = ? AND marketplace = ? AND listing_id = ?`
+
+	q := baseQuery
+	if forUpdate {
+		q = q + " FOR UPDATE"
+	}
+
+	return scanOrder(exec.QueryRowContext(ctx, q, chainID, marketplace, listingID))
+}
+
+// orderColumns is the column list read by scanOrder.
+const orderColumns = `
   order_id,
+  chain_id,
+  marketplace,
   IFNULL(listing_id, 0) AS listing_id,
   seller,
   IFNULL(buyer, '') AS buyer,
@@ -134,18 +298,26 @@ SELECT
   IFNULL(tx_hash, '') AS tx_hash,
   deleted,
   created_at,
-  updated_at
-FROM orders WHERE listing_id = ?`
+  updated_at,
+  block_number,
+  log_index,
+  listed_tx_hash, listed_block_number, listed_log_index, listed_block_hash, listed_at,
+  canceled_tx_hash, canceled_block_number, canceled_log_index, canceled_block_hash, canceled_at,
+  sold_tx_hash, sold_block_number, sold_log_index, sold_block_hash, sold_at`
 
-	q := baseQuery
-	if forUpdate {
-		q = q + " FOR UPDATE"
-	}
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	row := exec.QueryRowContext(ctx, q, listingID)
+// scanOrder reads one row selected with orderColumns.
+func scanOrder(row rowScanner) (*Order, error) {
 	var o Order
-	if err := row.Scan(
+	var listed, canceled, sold chainEventColumns
+	dest := []any{
 		&o.OrderID,
+		&o.ChainID,
+		&o.Marketplace,
 		&o.ListingID,
 		&o.Seller,
 		&o.Buyer,
@@ -160,40 +332,98 @@ FROM orders WHERE listing_id = ?`
 		&o.Deleted,
 		&o.CreatedAt,
 		&o.UpdatedAt,
-	); err != nil {
+		&o.BlockNumber,
+		&o.LogIndex,
+	}
+	dest = append(dest, listed.dest()...)
+	dest = append(dest, canceled.dest()...)
+	dest = append(dest, sold.dest()...)
+	if err := row.Scan(dest...); err != nil {
 		return nil, err
 	}
+	o.Listed = listed.event()
+	o.Canceled = canceled.event()
+	o.Sold = sold.event()
 	return &o, nil
 }
 
-// ListRecent returns a small set of recent orders for demo purposes.
-func (s *OrderStore) ListRecent(ctx context.Context, limit int) ([]*Order, error) {
+// chainEventColumns holds the nullable columns of one ChainEvent.
+type chainEventColumns struct {
+	txHash      sql.NullString
+	blockNumber sql.NullInt64
+	logIndex    sql.NullInt64
+	blockHash   sql.NullString
+	blockTime   sql.NullTime
+}
+
+func (c *chainEventColumns) dest() []any {
+	return []any{&c.txHash, &c.blockNumber, &c.logIndex, &c.blockHash, &c.blockTime}
+}
+
+func (c *chainEventColumns) event() *ChainEvent {
+	if !c.blockNumber.Valid {
+		return nil
+	}
+	return &ChainEvent{
+		TxHash:      c.txHash.String,
+		BlockNumber: uint64(c.blockNumber.Int64),
+		LogIndex:    uint(c.logIndex.Int64),
+		BlockHash:   c.blockHash.String,
+		BlockTime:   c.blockTime.Time,
+	}
+}
+
+// ListOpenBySellerForUpdateTx returns the LISTED and UNFILLABLE orders of a
+// seller for an NFT contract on one marketplace contract and locks them
+// within the given transaction.
+func (s *OrderStore) ListOpenBySellerForUpdateTx(ctx context.Context, tx *sql.Tx, chainID int64, marketplace, seller, nftAddress string) ([]*Order, error) {
+	q := `SELECT ` + orderColumns + `
+FROM orders
+WHERE chain_id = ? AND marketplace = ? AND seller = ? AND nft_address = ? AND status IN (?, ?)
+ORDER BY ` + decimalOrder("listing_id") + `
+FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, q, chainID, marketplace, seller, nftAddress, OrderStatusListed, OrderStatusUnfillable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Order
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// UpdateStatusTx sets the status of an order within the given transaction.
+func (s *OrderStore) UpdateStatusTx(ctx context.Context, tx *sql.Tx, chainID int64, marketplace, listingID string, status OrderStatus) error {
+	const q = `UPDATE orders SET status = ? WHERE chain_id = ? AND marketplace = ? AND listing_id = ?`
+
+	if err := checkFence(ctx, tx); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, q, status, chainID, marketplace, listingID)
+	return err
+}
+
+// ListRecent returns a small set of recent orders for demo purposes. A zero
+// chainID lists orders from every chain.
+func (s *OrderStore) ListRecent(ctx context.Context, chainID int64, limit int) ([]*Order, error) {
 	if limit <= 0 {
 		limit = 50
 	}
-	const q = `
-SELECT
-  order_id,
-  IFNULL(listing_id, 0) AS listing_id,
-  seller,
-  IFNULL(buyer, '') AS buyer,
-  IFNULL(nft_name, '') AS nft_name,
-  nft_address,
-  IFNULL(url, '') AS url,
-  token_id,
-  amount,
-  price,
-  status,
-  IFNULL(tx_hash, '') AS tx_hash,
-  deleted,
-  created_at,
-  updated_at
+	const q = `SELECT ` + orderColumns + `
 FROM orders
-WHERE deleted = 0
+WHERE deleted = 0 AND (? = 0 OR chain_id = ?)
 ORDER BY updated_at DESC
 LIMIT ?`
 
-	rows, err := s.db.QueryContext(ctx, q, limit)
+	rows, err := s.db.QueryContext(ctx, q, chainID, chainID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -201,27 +431,118 @@ LIMIT ?`
 
 	var out []*Order
 	for rows.Next() {
-		var o Order
-		if err := rows.Scan(
-			&o.OrderID,
-			&o.ListingID,
-			&o.Seller,
-			&o.Buyer,
-			&o.NFTName,
-			&o.NFTAddress,
-			&o.URL,
-			&o.TokenID,
-			&o.Amount,
-			&o.Price,
-			&o.Status,
-			&o.TxHash,
-			&o.Deleted,
-			&o.CreatedAt,
-			&o.UpdatedAt,
-		); err != nil {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// ListByStatusAfter returns up to limit orders of a marketplace contract
+// whose status is one of statuses and whose listing ID is greater than
+// afterListingID, ordered by listing ID. Callers page through all matching
+// orders by passing "" for the first page and the last listing ID of the
+// previous page after that.
+func (s *OrderStore) ListByStatusAfter(ctx context.Context, chainID int64, marketplace string, statuses []OrderStatus, afterListingID string, limit int) ([]*Order, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	args := []any{chainID, marketplace}
+	cursor := ""
+	if afterListingID != "" {
+		cursor = " AND " + decimalCompare("listing_id", ">")
+		args = append(args, decimalArgs(afterListingID)...)
+	}
+	q := `SELECT ` + orderColumns + `
+FROM orders
+WHERE chain_id = ? AND marketplace = ?` + cursor + ` AND deleted = 0
+  AND status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)
+ORDER BY ` + decimalOrder("listing_id") + `
+LIMIT ?`
+
+	for _, st := range statuses {
+		args = append(args, st)
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Order
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// ListedBlockBefore returns the Listed block of the highest listing ID below
+// listingID of a marketplace contract whose Listed event was applied from a
+// log. Listing IDs are assigned in chain order, so a lower ID was listed no
+// later than listingID. It returns sql.ErrNoRows when there is none.
+func (s *OrderStore) ListedBlockBefore(ctx context.Context, chainID int64, marketplace, listingID string) (uint64, error) {
+	return s.listedBlockNear(ctx, chainID, marketplace, listingID, "<", " DESC")
+}
+
+// ListedBlockAfter is like ListedBlockBefore for the lowest listing ID above
+// listingID, which was listed no earlier than listingID.
+func (s *OrderStore) ListedBlockAfter(ctx context.Context, chainID int64, marketplace, listingID string) (uint64, error) {
+	return s.listedBlockNear(ctx, chainID, marketplace, listingID, ">", "")
+}
+
+func (s *OrderStore) listedBlockNear(ctx context.Context, chainID int64, marketplace, listingID, op, dir string) (uint64, error) {
+	q := `SELECT listed_block_number
+FROM orders
+WHERE chain_id = ? AND marketplace = ?
+  AND ` + decimalCompare("listing_id", op) + `
+  AND listed_block_number IS NOT NULL
+ORDER BY LENGTH(listing_id)` + dir + `, listing_id` + dir + `
+LIMIT 1`
+
+	args := append([]any{chainID, marketplace}, decimalArgs(listingID)...)
+	var block uint64
+	if err := s.db.QueryRowContext(ctx, q, args...).Scan(&block); err != nil {
+		return 0, err
+	}
+	return block, nil
+}
+
+// ListListingIDs returns, in order, the listing IDs in [from, to) of a
+// marketplace contract whose Listed event has been applied (seller set).
+// Rows created from a Cancelled / Sold event alone are not included.
+func (s *OrderStore) ListListingIDs(ctx context.Context, chainID int64, marketplace, from, to string) ([]string, error) {
+	q := `SELECT listing_id
+FROM orders
+WHERE chain_id = ? AND marketplace = ?
+  AND ` + decimalCompare("listing_id", ">=") + ` AND ` + decimalCompare("listing_id", "<") + `
+  AND seller <> ''
+ORDER BY ` + decimalOrder("listing_id")
+
+	args := append([]any{chainID, marketplace}, decimalArgs(from)...)
+	rows, err := s.db.QueryContext(ctx, q, append(args, decimalArgs(to)...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
-		out = append(out, &o)
+		out = append(out, id)
 	}
 	return out, rows.Err()
 }
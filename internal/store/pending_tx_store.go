@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TxStatus is the state of a tracked transaction.
+type TxStatus string
+
+const (
+	TxStatusPending TxStatus = "PENDING" // no receipt yet
+	TxStatusMined   TxStatus = "MINED"   // succeeded, waiting for confirmations
+	TxStatusSuccess TxStatus = "SUCCESS" // succeeded and confirmed
+	TxStatusFailed  TxStatus = "FAILED"  // reverted, or not mined before the timeout
+)
+
+// PendingTx is an order transaction (buy or cancel) the frontend broadcast
+// and the backend follows until it is final.
+type PendingTx struct {
+	ID          int64       `json:"id"`
+	ChainID     int64       `json:"chain_id"`
+	TxHash      string      `json:"tx_hash"`
+	Marketplace string      `json:"marketplace"`
+	ListingID   string      `json:"listing_id"`
+	Type        string      `json:"type"`  // OrderEventSold or OrderEventCancelled
+	Actor       string      `json:"actor"` // buyer for Sold, seller for Cancelled
+	Status      TxStatus    `json:"status"`
+	PrevStatus  OrderStatus `json:"prev_status,omitempty"` // order status before this transaction locked it, empty when it did not
+	BlockNumber uint64      `json:"block_number,omitempty"`
+	Reason      string      `json:"reason,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// PendingTxStore wraps access to the pending_txs table.
+type PendingTxStore struct {
+	db *sql.DB
+}
+
+// NewPendingTxStore creates a new PendingTxStore.
+func NewPendingTxStore(db *sql.DB) *PendingTxStore {
+	return &PendingTxStore{db: db}
+}
+
+// InitSchema ensures the pending_txs table exists.
+func (s *PendingTxStore) InitSchema(ctx context.Context) error {
+	content, err := schemaFiles.ReadFile("sql/create_pending_txs_table.sql")
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+		return err
+	}
+	if err := modifyToVarchar(ctx, s.db, "pending_txs", []columnDef{
+		{"listing_id", "VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId'"},
+	}); err != nil {
+		return err
+	}
+	return addMissingColumns(ctx, s.db, "pending_txs", []columnDef{
+		{"prev_status", "VARCHAR(20) NOT NULL DEFAULT '' COMMENT 'Order status before this transaction locked it, empty while it does not hold the lock' AFTER `status`"},
+	})
+}
+
+// InsertTx starts tracking t as PENDING and fills in its ID. It reports
+// false when the transaction is already tracked.
+func (s *PendingTxStore) InsertTx(ctx context.Context, tx *sql.Tx, t *PendingTx) (bool, error) {
+	const q = `
+INSERT IGNORE INTO pending_txs (
+  chain_id, tx_hash, marketplace, listing_id, event_type, actor, status
+) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	t.Status = TxStatusPending
+	res, err := tx.ExecContext(ctx, q, t.ChainID, t.TxHash, t.Marketplace, t.ListingID, t.Type, t.Actor, t.Status)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	t.ID, err = res.LastInsertId()
+	return true, err
+}
+
+// ListOpen returns up to limit PENDING / MINED transactions of a chain,
+// oldest first.
+func (s *PendingTxStore) ListOpen(ctx context.Context, chainID int64, limit int) ([]*PendingTx, error) {
+	const q = `
+SELECT
+  id, chain_id, tx_hash, marketplace, listing_id, event_type, actor,
+  status, prev_status, IFNULL(block_number, 0), reason, created_at, updated_at
+FROM pending_txs
+WHERE chain_id = ? AND status IN (?, ?)
+ORDER BY id
+LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, q, chainID, TxStatusPending, TxStatusMined, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*PendingTx
+	for rows.Next() {
+		var t PendingTx
+		if err := rows.Scan(
+			&t.ID,
+			&t.ChainID,
+			&t.TxHash,
+			&t.Marketplace,
+			&t.ListingID,
+			&t.Type,
+			&t.Actor,
+			&t.Status,
+			&t.PrevStatus,
+			&t.BlockNumber,
+			&t.Reason,
+			&t.CreatedAt,
+			&t.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, &t)
+	}
+	return out, rows.Err()
+}
+
+// UpdateStatusTx moves a tracked transaction to status, recording the block
+// of its receipt (0 when not mined) and a failure reason.
+func (s *PendingTxStore) UpdateStatusTx(ctx context.Context, tx *sql.Tx, id int64, status TxStatus, blockNumber uint64, reason string) error {
+	const q = `
+UPDATE pending_txs
+SET status = ?, block_number = ?, reason = ?
+WHERE id = ?`
+
+	var block any
+	if blockNumber > 0 {
+		block = blockNumber
+	}
+	_, err := tx.ExecContext(ctx, q, status, block, truncate(reason, 255), id)
+	return err
+}
+
+// SetPrevStatusTx records that a tracked transaction locked its order, which
+// was in status before.
+func (s *PendingTxStore) SetPrevStatusTx(ctx context.Context, tx *sql.Tx, id int64, status OrderStatus) error {
+	const q = `
+UPDATE pending_txs
+SET prev_status = ?
+WHERE id = ?`
+
+	_, err := tx.ExecContext(ctx, q, status, id)
+	return err
+}
+
+// HasOtherLockingTx reports whether another PENDING / MINED transaction
+// tracked for the same listing holds the order's lock.
+func (s *PendingTxStore) HasOtherLockingTx(ctx context.Context, tx *sql.Tx, t *PendingTx) (bool, error) {
+	const q = `
+SELECT COUNT(*)
+FROM pending_txs
+WHERE chain_id = ? AND marketplace = ? AND listing_id = ? AND id <> ? AND status IN (?, ?) AND prev_status <> ''`
+
+	var n int
+	err := tx.QueryRowContext(ctx, q, t.ChainID, t.Marketplace, t.ListingID, t.ID, TxStatusPending, TxStatusMined).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// ScannedBlock is a processed block hash recorded for reorg detection.
+type ScannedBlock struct {
+	Number uint64
+	Hash   string
+}
+
+// ReorgStore wraps access to the scanner_blocks and order_undo_log tables.
+// scanner_blocks remembers which block hashes a scanner has processed, and
+// order_undo_log keeps the order row as it was before each block changed it,
+// so orders derived from orphaned blocks can be restored after a reorg.
+type ReorgStore struct {
+	db *sql.DB
+}
+
+// NewReorgStore creates a new ReorgStore.
+func NewReorgStore(db *sql.DB) *ReorgStore {
+	return &ReorgStore{db: db}
+}
+
+// InitSchema ensures the scanner_blocks and order_undo_log tables exist.
+func (s *ReorgStore) InitSchema(ctx context.Context) error {
+	for _, file := range []string{
+		"sql/create_scanner_blocks_table.sql",
+		"sql/create_order_undo_log_table.sql",
+	} {
+		content, err := schemaFiles.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+			return err
+		}
+	}
+	return modifyToVarchar(ctx, s.db, "order_undo_log", []columnDef{
+		{"listing_id", "VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId'"},
+	})
+}
+
+// SaveBlock records the hash of a processed block.
+func (s *ReorgStore) SaveBlock(ctx context.Context, chainID int64, contract string, number uint64, hash string) error {
+	const q = `
+INSERT INTO scanner_blocks (chain_id, contract, block_number, block_hash)
+VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  block_hash = VALUES(block_hash);`
+
+	_, err := s.db.ExecContext(ctx, q, chainID, contract, number, hash)
+	return err
+}
+
+// GetBlockHash returns the recorded hash of a processed block.
+// It returns sql.ErrNoRows when the block was not recorded.
+func (s *ReorgStore) GetBlockHash(ctx context.Context, chainID int64, contract string, number uint64) (string, error) {
+	const q = `
+SELECT block_hash
+FROM scanner_blocks
+WHERE chain_id = ? AND contract = ? AND block_number = ?`
+
+	var hash string
+	if err := s.db.QueryRowContext(ctx, q, chainID, contract, number).Scan(&hash); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// ListBlocksBefore returns up to limit recorded blocks below number, newest first.
+func (s *ReorgStore) ListBlocksBefore(ctx context.Context, chainID int64, contract string, number uint64, limit int) ([]ScannedBlock, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	const q = `
+SELECT block_number, block_hash
+FROM scanner_blocks
+WHERE chain_id = ? AND contract = ? AND block_number < ?
+ORDER BY block_number DESC
+LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, q, chainID, contract, number, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ScannedBlock
+	for rows.Next() {
+		var b ScannedBlock
+		if err := rows.Scan(&b.Number, &b.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// SaveOrderUndoTx records the state of an order before an event in the given
+// block is applied to it. prev is nil when the order did not exist yet.
+// Only the first snapshot per block + listing is kept, which is the state
+// to restore if the whole block is orphaned.
+func (s *ReorgStore) SaveOrderUndoTx(ctx context.Context, tx *sql.Tx, chainID int64, contract string, blockNumber uint64, blockHash string, listingID string, prev *Order) error {
+	const q = `
+INSERT IGNORE INTO order_undo_log (
+  chain_id, contract, block_number, block_hash, listing_id, prev_order
+) VALUES (?, ?, ?, ?, ?, ?)`
+
+	var snapshot sql.NullString
+	if prev != nil {
+		data, err := json.Marshal(prev)
+		if err != nil {
+			return err
+		}
+		snapshot = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := tx.ExecContext(ctx, q, chainID, contract, blockNumber, blockHash, listingID, snapshot)
+	return err
+}
+
+// RollbackAfter restores every order changed by blocks above the given block
+// to its recorded previous state (deleting orders that did not exist before),
+// flags the order_events of those blocks as removed, and forgets the recorded
+// blocks and undo entries above it. Everything runs in one transaction. It
+// returns the number of undo entries applied.
+func (s *ReorgStore) RollbackAfter(ctx context.Context, chainID int64, contract string, block uint64) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	const selectQ = `
+SELECT listing_id, prev_order
+FROM order_undo_log
+WHERE chain_id = ? AND contract = ? AND block_number > ?
+ORDER BY block_number DESC, id DESC
+FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, selectQ, chainID, contract, block)
+	if err != nil {
+		return 0, err
+	}
+
+	type undoEntry struct {
+		listingID string
+		prev      sql.NullString
+	}
+	var entries []undoEntry
+	for rows.Next() {
+		var e undoEntry
+		if err := rows.Scan(&e.listingID, &e.prev); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Newest blocks first, so each listing ends up in the state it had
+	// before the oldest orphaned block touched it.
+	for _, e := range entries {
+		if !e.prev.Valid {
+			if err := deleteOrderByListingID(ctx, tx, chainID, contract, e.listingID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		var prev Order
+		if err := json.Unmarshal([]byte(e.prev.String), &prev); err != nil {
+			return 0, err
+		}
+		if err := upsertOrder(ctx, tx, &prev); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM order_undo_log
+WHERE chain_id = ? AND contract = ? AND block_number > ?`, chainID, contract, block); err != nil {
+		return 0, err
+	}
+	if err := markOrderEventsRemoved(ctx, tx, chainID, contract, block); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM scanner_blocks
+WHERE chain_id = ? AND contract = ? AND block_number > ?`, chainID, contract, block); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	committed = true
+	return len(entries), nil
+}
+
+// PruneBefore deletes recorded blocks and undo entries below the given block.
+// Blocks that deep are considered final and no longer need to be tracked.
+func (s *ReorgStore) PruneBefore(ctx context.Context, chainID int64, contract string, block uint64) error {
+	if _, err := s.db.ExecContext(ctx, `
+DELETE FROM order_undo_log
+WHERE chain_id = ? AND contract = ? AND block_number < ?`, chainID, contract, block); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+DELETE FROM scanner_blocks
+WHERE chain_id = ? AND contract = ? AND block_number < ?`, chainID, contract, block)
+	return err
+}
@@ -0,0 +1,9 @@
+package store
+
+import "embed"
+
+// schemaFiles holds the table DDL under sql/. It is embedded so InitSchema
+// works regardless of the working directory the server is started from.
+//
+//go:embed sql/*.sql
+var schemaFiles embed.FS
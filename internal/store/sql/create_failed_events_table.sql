@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS `failed_events` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID',
+  `contract` VARCHAR(64) NOT NULL COMMENT 'Address of the contract that emitted the log',
+  `handler` VARCHAR(64) NOT NULL COMMENT 'Scanner that failed to handle the log, e.g. marketplace scanner',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the log',
+  `block_hash` VARCHAR(100) NOT NULL COMMENT 'Hash of that block; the log is discarded once it is no longer canonical',
+  `tx_hash` VARCHAR(100) NOT NULL COMMENT 'Transaction that emitted the log',
+  `tx_index` INT UNSIGNED NOT NULL COMMENT 'Index of the transaction in the block',
+  `log_index` INT UNSIGNED NOT NULL COMMENT 'Index of the log in the block',
+  `topics` JSON NOT NULL COMMENT 'Raw log topics (hex strings)',
+  `data` MEDIUMTEXT NOT NULL COMMENT 'Raw log data (hex)',
+  `status` VARCHAR(20) NOT NULL COMMENT 'PENDING, RESOLVED, DEAD or DISCARDED',
+  `attempts` INT NOT NULL DEFAULT 0 COMMENT 'Retries so far',
+  `last_error` VARCHAR(512) NOT NULL DEFAULT '' COMMENT 'Error of the latest failed attempt',
+  `next_retry_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Earliest time of the next retry (PENDING only)',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Time of the first failure',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_failed_events_log` (`chain_id`, `block_hash`, `log_index`),
+  KEY `idx_failed_events_due` (`status`, `next_retry_at`),
+  KEY `idx_failed_events_chain` (`chain_id`, `status`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Contract logs whose handler failed, retried in the background';
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS `leader_fences` (
+  `name` VARCHAR(64) NOT NULL COMMENT 'Leader election name',
+  `token` BIGINT NOT NULL DEFAULT 0 COMMENT 'Fencing token of the current leader term',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Fencing tokens checked by writes made on behalf of a leader';
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS `nft_assets` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'Primary key',
+  `name` VARCHAR(255) NOT NULL COMMENT 'NFT display name',
+  `owner` VARCHAR(64) NOT NULL COMMENT 'Owner wallet address',
+  `cid` VARCHAR(128) NOT NULL COMMENT 'IPFS CID',
+  `url` VARCHAR(512) NOT NULL COMMENT 'IPFS gateway URL',
+  `chain_id` BIGINT DEFAULT NULL COMMENT 'EVM chain ID the token was minted on',
+  `token_id` VARCHAR(78) DEFAULT NULL COMMENT 'Minted tokenId (ERC721 tokenId or ERC1155 id)',
+  `nft_address` VARCHAR(64) DEFAULT NULL COMMENT 'NFT contract address (ERC721 or ERC1155)',
+  `amount` VARCHAR(78) DEFAULT NULL COMMENT 'Minted amount (1 for ERC721, >=1 for ERC1155)',
+  `token_uri` VARCHAR(512) DEFAULT NULL COMMENT 'Metadata URI from the on-chain URI event',
+  `metadata` JSON DEFAULT NULL COMMENT 'Metadata fetched from token_uri (NULL if not JSON)',
+  `metadata_updated_at` DATETIME DEFAULT NULL COMMENT 'Last time token_uri / metadata were refreshed',
+  `metadata_failed_uri` VARCHAR(512) DEFAULT NULL COMMENT 'Token URI whose metadata fetch last failed',
+  `metadata_attempts` INT NOT NULL DEFAULT 0 COMMENT 'Failed fetches of metadata_failed_uri in a row',
+  `metadata_next_attempt_at` DATETIME DEFAULT NULL COMMENT 'No fetch of metadata_failed_uri before this time',
+  `metadata_error` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Last metadata fetch error',
+  `deleted` TINYINT NOT NULL DEFAULT 0 COMMENT 'Logical delete flag, 0=normal, 1=deleted',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`id`),
+  KEY `idx_nft_assets_token` (`chain_id`, `nft_address`, `token_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Uploaded NFT assets (images on IPFS)';
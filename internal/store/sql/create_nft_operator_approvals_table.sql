@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `nft_operator_approvals` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID of the contract',
+  `nft_address` VARCHAR(64) NOT NULL COMMENT 'ERC721 / ERC1155 contract address',
+  `owner` VARCHAR(64) NOT NULL COMMENT 'Token owner granting the approval',
+  `operator` VARCHAR(64) NOT NULL COMMENT 'Approved operator (e.g. the marketplace)',
+  `approved` TINYINT NOT NULL COMMENT '1=approved, 0=revoked',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the last applied ApprovalForAll',
+  `log_index` INT UNSIGNED NOT NULL COMMENT 'Log index of the last applied ApprovalForAll',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `nft_address`, `owner`, `operator`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Operator approvals indexed from ApprovalForAll events';
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `nft_token_approvals` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID of the contract',
+  `nft_address` VARCHAR(64) NOT NULL COMMENT 'ERC721 contract address',
+  `token_id` VARCHAR(78) NOT NULL COMMENT 'ERC721 tokenId',
+  `owner` VARCHAR(64) NOT NULL COMMENT 'Token owner granting the approval',
+  `approved` VARCHAR(64) NOT NULL COMMENT 'Approved address, zero address when cleared',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the last applied Approval / Transfer',
+  `log_index` INT UNSIGNED NOT NULL COMMENT 'Log index of the last applied Approval / Transfer',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `nft_address`, `token_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ERC721 single-token approvals indexed from Approval events';
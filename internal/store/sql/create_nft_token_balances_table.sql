@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `nft_token_balances` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID of the contract',
+  `nft_address` VARCHAR(64) NOT NULL COMMENT 'ERC1155 contract address',
+  `token_id` VARCHAR(78) NOT NULL COMMENT 'ERC1155 id',
+  `holder` VARCHAR(64) NOT NULL COMMENT 'Holder wallet address',
+  `balance` VARCHAR(78) NOT NULL DEFAULT '0' COMMENT 'Current balance of holder for this id, negative while earlier transfers are still missing',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the newest applied transfer',
+  `log_index` INT UNSIGNED NOT NULL COMMENT 'Log index of the newest applied transfer',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `nft_address`, `token_id`, `holder`),
+  KEY `idx_nft_token_balances_holder` (`holder`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ERC1155 balances indexed from TransferSingle / TransferBatch events';
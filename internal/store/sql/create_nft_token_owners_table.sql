@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `nft_token_owners` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID of the contract',
+  `nft_address` VARCHAR(64) NOT NULL COMMENT 'ERC721 contract address',
+  `token_id` VARCHAR(78) NOT NULL COMMENT 'ERC721 tokenId',
+  `owner` VARCHAR(64) NOT NULL COMMENT 'Current owner, zero address once burned',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the last applied Transfer',
+  `log_index` INT UNSIGNED NOT NULL COMMENT 'Log index of the last applied Transfer',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `nft_address`, `token_id`),
+  KEY `idx_nft_token_owners_owner` (`owner`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ERC721 token ownership indexed from Transfer events';
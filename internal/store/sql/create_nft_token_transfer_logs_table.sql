@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `nft_token_transfer_logs` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID of the contract',
+  `nft_address` VARCHAR(64) NOT NULL COMMENT 'ERC1155 contract address',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the transfer log',
+  `log_index` INT UNSIGNED NOT NULL COMMENT 'Log index of the transfer log in the block',
+  `tx_hash` VARCHAR(100) NOT NULL COMMENT 'Transaction hash',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Time the log was applied',
+  PRIMARY KEY (`chain_id`, `nft_address`, `block_number`, `log_index`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='TransferSingle / TransferBatch logs already applied to nft_token_balances';
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `nft_token_uris` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID of the contract',
+  `nft_address` VARCHAR(64) NOT NULL COMMENT 'ERC1155 contract address',
+  `token_id` VARCHAR(78) NOT NULL COMMENT 'ERC1155 id',
+  `uri` VARCHAR(512) NOT NULL COMMENT 'Latest URI emitted for this id',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the last applied URI event',
+  `log_index` INT UNSIGNED NOT NULL COMMENT 'Log index of the last applied URI event',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `nft_address`, `token_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ERC1155 metadata URIs indexed from URI events';
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `order_callbacks` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID the listing lives on',
+  `marketplace` VARCHAR(64) NOT NULL COMMENT 'NFTMarketplace contract address',
+  `listing_id` VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId',
+  `event_type` VARCHAR(32) NOT NULL COMMENT 'Listed, Cancelled or Sold',
+  `tx_hash` VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'Transaction hash sent by the client, may be empty',
+  `payload` JSON NOT NULL COMMENT 'Callback request body',
+  `status` VARCHAR(20) NOT NULL COMMENT 'PENDING, CONFIRMED, REJECTED or EXPIRED',
+  `attempts` INT NOT NULL DEFAULT 0 COMMENT 'Verification attempts after the request',
+  `last_error` VARCHAR(512) NOT NULL DEFAULT '' COMMENT 'Why the last verification did not succeed',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`id`),
+  KEY `idx_order_callbacks_status` (`status`, `id`),
+  KEY `idx_order_callbacks_listing` (`chain_id`, `marketplace`, `listing_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Frontend order callbacks not yet verified against the chain';
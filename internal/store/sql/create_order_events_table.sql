@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS `order_events` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID the listing lives on',
+  `marketplace` VARCHAR(64) NOT NULL COMMENT 'NFTMarketplace contract address',
+  `listing_id` VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId',
+  `event_type` VARCHAR(32) NOT NULL COMMENT 'Listed, Cancelled, Sold, ApprovalRevoked, ApprovalRestored, TxSubmitted, TxMined, TxFailed, Reconciled',
+  `source` VARCHAR(16) NOT NULL COMMENT 'chain = indexed log, callback = frontend API call, tracker = tx receipt, reconciler = listings() view',
+  `status` VARCHAR(20) NOT NULL COMMENT 'Order status after the event',
+  `actor` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'Address that caused the event (seller / buyer)',
+  `tx_hash` VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'Transaction hash',
+  `block_number` BIGINT UNSIGNED DEFAULT NULL COMMENT 'Block number, chain events only',
+  `log_index` INT UNSIGNED DEFAULT NULL COMMENT 'Log index in the block, chain events only',
+  `block_hash` VARCHAR(66) DEFAULT NULL COMMENT 'Block hash, chain events only',
+  `block_time` DATETIME DEFAULT NULL COMMENT 'Block timestamp (UTC), chain events only',
+  `payload` JSON DEFAULT NULL COMMENT 'Event data or callback request body',
+  `removed` TINYINT NOT NULL DEFAULT 0 COMMENT '1 = the block of this chain event was reorged away',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Time the row was written',
+  PRIMARY KEY (`id`),
+  KEY `idx_order_events_listing` (`chain_id`, `marketplace`, `listing_id`, `id`),
+  KEY `idx_order_events_block` (`chain_id`, `marketplace`, `block_number`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Append-only history of order changes';
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `order_undo_log` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'Primary key',
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID',
+  `contract` VARCHAR(64) NOT NULL COMMENT 'Marketplace contract address',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Block of the event that changed the order',
+  `block_hash` VARCHAR(66) NOT NULL COMMENT 'Hash of that block',
+  `listing_id` VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId',
+  `prev_order` JSON DEFAULT NULL COMMENT 'Order row before the block was applied, NULL if it did not exist',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_order_undo_block_listing` (`chain_id`, `contract`, `block_number`, `listing_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Order snapshots taken before applying chain events, used to roll back reorged blocks';
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS `orders` (
+  `order_id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'System unique order ID',
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID the listing lives on',
+  `marketplace` VARCHAR(64) NOT NULL COMMENT 'NFTMarketplace contract address the listing belongs to',
+  `listing_id` VARCHAR(78) DEFAULT NULL COMMENT 'On-chain Marketplace listingId',
+  `seller` VARCHAR(64) NOT NULL COMMENT 'Seller address',
+  `buyer` VARCHAR(64) DEFAULT NULL COMMENT 'Buyer address',
+  `nft_name` VARCHAR(255) DEFAULT NULL COMMENT 'Human readable NFT name',
+  `nft_address` VARCHAR(64) NOT NULL COMMENT 'NFT contract address',
+  `url` VARCHAR(512) DEFAULT NULL COMMENT 'NFT image URL',
+  `token_id` VARCHAR(78) NOT NULL COMMENT 'NFT tokenId',
+  `amount` VARCHAR(78) NOT NULL COMMENT 'Amount (ERC1155)',
+  `price` DECIMAL(36,0) NOT NULL COMMENT 'Price in wei',
+  `status` VARCHAR(20) NOT NULL COMMENT 'INIT, LISTED, UNFILLABLE, LOCKED, SETTLING, SUCCESS, FAILED, CANCELED',
+  `tx_hash` VARCHAR(100) DEFAULT NULL COMMENT 'On-chain transaction hash',
+  `deleted` TINYINT NOT NULL DEFAULT 0 COMMENT 'Logical delete flag, 0=normal, 1=deleted',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  `block_number` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Block of the last applied marketplace event, 0 = none',
+  `log_index` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Log index of the last applied marketplace event',
+  `listed_tx_hash` VARCHAR(100) DEFAULT NULL COMMENT 'Listed event transaction hash',
+  `listed_block_number` BIGINT UNSIGNED DEFAULT NULL COMMENT 'Listed event block number',
+  `listed_log_index` INT UNSIGNED DEFAULT NULL COMMENT 'Listed event log index in the block',
+  `listed_block_hash` VARCHAR(66) DEFAULT NULL COMMENT 'Listed event block hash',
+  `listed_at` DATETIME DEFAULT NULL COMMENT 'Listed event block timestamp (UTC)',
+  `canceled_tx_hash` VARCHAR(100) DEFAULT NULL COMMENT 'Cancelled event transaction hash',
+  `canceled_block_number` BIGINT UNSIGNED DEFAULT NULL COMMENT 'Cancelled event block number',
+  `canceled_log_index` INT UNSIGNED DEFAULT NULL COMMENT 'Cancelled event log index in the block',
+  `canceled_block_hash` VARCHAR(66) DEFAULT NULL COMMENT 'Cancelled event block hash',
+  `canceled_at` DATETIME DEFAULT NULL COMMENT 'Cancelled event block timestamp (UTC)',
+  `sold_tx_hash` VARCHAR(100) DEFAULT NULL COMMENT 'Sold event transaction hash',
+  `sold_block_number` BIGINT UNSIGNED DEFAULT NULL COMMENT 'Sold event block number',
+  `sold_log_index` INT UNSIGNED DEFAULT NULL COMMENT 'Sold event log index in the block',
+  `sold_block_hash` VARCHAR(66) DEFAULT NULL COMMENT 'Sold event block hash',
+  `sold_at` DATETIME DEFAULT NULL COMMENT 'Sold event block timestamp (UTC)',
+  PRIMARY KEY (`order_id`),
+  UNIQUE KEY `uk_orders_listing_id` (`chain_id`, `marketplace`, `listing_id`),
+  UNIQUE KEY `uk_orders_tx_hash` (`chain_id`, `tx_hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='NFT trading orders';
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `pending_txs` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID',
+  `tx_hash` VARCHAR(100) NOT NULL COMMENT 'Tracked transaction hash',
+  `marketplace` VARCHAR(64) NOT NULL COMMENT 'NFTMarketplace contract address',
+  `listing_id` VARCHAR(78) NOT NULL COMMENT 'On-chain Marketplace listingId',
+  `event_type` VARCHAR(32) NOT NULL COMMENT 'Sold or Cancelled: what the transaction is expected to do',
+  `actor` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'Buyer for Sold, seller for Cancelled',
+  `status` VARCHAR(20) NOT NULL COMMENT 'PENDING, MINED, SUCCESS or FAILED',
+  `prev_status` VARCHAR(20) NOT NULL DEFAULT '' COMMENT 'Order status before this transaction locked it, empty while it does not hold the lock',
+  `block_number` BIGINT UNSIGNED DEFAULT NULL COMMENT 'Block of the receipt once mined',
+  `reason` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Why the transaction failed',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Time tracking started',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_pending_txs_hash` (`chain_id`, `tx_hash`),
+  KEY `idx_pending_txs_status` (`chain_id`, `status`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Order transactions broadcast by the frontend, tracked until final';
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `scanner_backfills` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID',
+  `contract` VARCHAR(64) NOT NULL COMMENT 'Scanned contract address',
+  `from_block` BIGINT UNSIGNED NOT NULL COMMENT 'First block of the backfill range',
+  `to_block` BIGINT UNSIGNED NOT NULL COMMENT 'Last block of the backfill range',
+  `last_block` BIGINT UNSIGNED NOT NULL COMMENT 'Last fully processed block',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `contract`, `from_block`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Historical backfill progress (per chain + contract + start block)';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `scanner_blocks` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID',
+  `contract` VARCHAR(64) NOT NULL COMMENT 'Scanned contract address',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Processed block number',
+  `block_hash` VARCHAR(66) NOT NULL COMMENT 'Block hash as reported by the RPC node',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  PRIMARY KEY (`chain_id`, `contract`, `block_number`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Hashes of recently processed blocks, used for reorg detection';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `scanner_checkpoints` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID',
+  `contract` VARCHAR(64) NOT NULL COMMENT 'Scanned contract address',
+  `block_number` BIGINT UNSIGNED NOT NULL COMMENT 'Last fully processed block number',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `contract`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Event scanner resume points (per chain + contract)';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `scanner_listing_gaps` (
+  `chain_id` BIGINT NOT NULL COMMENT 'EVM chain ID',
+  `contract` VARCHAR(64) NOT NULL COMMENT 'Marketplace contract address',
+  `checked_below` BIGINT UNSIGNED NOT NULL COMMENT 'Listing IDs below this have been checked for gaps',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Create time',
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Update time',
+  PRIMARY KEY (`chain_id`, `contract`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Listing gap check progress (per chain + marketplace contract)';
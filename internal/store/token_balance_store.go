@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TokenBalance represents a row in the nft_token_balances table: how many
+// units of an ERC1155 id a holder owns, as derived from transfer events.
+// Transfers are applied as deltas in whatever order they are handled, so a
+// balance is negative while a debit was applied before the credit preceding
+// it on chain (e.g. a retried or backfilled log).
+type TokenBalance struct {
+	ChainID     int64     `json:"chain_id"`
+	NFTAddress  string    `json:"nft_address"`
+	TokenID     string    `json:"token_id"`
+	Holder      string    `json:"holder"`
+	Balance     string    `json:"balance"` // decimal string, VARCHAR(78) in DB; may be negative, see above
+	BlockNumber uint64    `json:"block_number"`
+	LogIndex    uint      `json:"log_index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TokenBalanceStore wraps access to the nft_token_balances table in MySQL.
+type TokenBalanceStore struct {
+	db *sql.DB
+}
+
+// NewTokenBalanceStore creates a new TokenBalanceStore.
+func NewTokenBalanceStore(db *sql.DB) *TokenBalanceStore {
+	return &TokenBalanceStore{db: db}
+}
+
+// InitSchema ensures the nft_token_balances and nft_token_transfer_logs
+// tables exist.
+func (s *TokenBalanceStore) InitSchema(ctx context.Context) error {
+	for _, file := range []string{
+		"sql/create_nft_token_balances_table.sql",
+		"sql/create_nft_token_transfer_logs_table.sql",
+	} {
+		content, err := schemaFiles.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+			return err
+		}
+	}
+	if err := addChainID(ctx, s.db, "nft_token_balances", "chain_id", "nft_address", "token_id", "holder"); err != nil {
+		return err
+	}
+	return modifyToVarchar(ctx, s.db, "nft_token_balances", []columnDef{
+		{"token_id", "VARCHAR(78) NOT NULL COMMENT 'ERC1155 id'"},
+		{"balance", "VARCHAR(78) NOT NULL DEFAULT '0' COMMENT 'Current balance of holder for this id, negative while earlier transfers are still missing'"},
+	})
+}
+
+// GetForUpdateTx returns the balance row of a holder for a token and locks it
+// within the given transaction.
+func (s *TokenBalanceStore) GetForUpdateTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, tokenID string, holder string) (*TokenBalance, error) {
+	const q = `
+SELECT chain_id, nft_address, token_id, holder, balance, block_number, log_index, created_at, updated_at
+FROM nft_token_balances
+WHERE chain_id = ? AND nft_address = ? AND token_id = ? AND holder = ?
+FOR UPDATE`
+
+	var b TokenBalance
+	if err := tx.QueryRowContext(ctx, q, chainID, nftAddress, tokenID, holder).Scan(
+		&b.ChainID,
+		&b.NFTAddress,
+		&b.TokenID,
+		&b.Holder,
+		&b.Balance,
+		&b.BlockNumber,
+		&b.LogIndex,
+		&b.CreatedAt,
+		&b.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// UpsertTx creates or updates the balance row of a holder for a token.
+func (s *TokenBalanceStore) UpsertTx(ctx context.Context, tx *sql.Tx, b *TokenBalance) error {
+	const q = `
+INSERT INTO nft_token_balances (chain_id, nft_address, token_id, holder, balance, block_number, log_index)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  balance = VALUES(balance),
+  block_number = VALUES(block_number),
+  log_index = VALUES(log_index);`
+
+	_, err := tx.ExecContext(ctx, q, b.ChainID, b.NFTAddress, b.TokenID, b.Holder, b.Balance, b.BlockNumber, b.LogIndex)
+	return err
+}
+
+// MarkAppliedTx records that the transfer log at (blockNumber, logIndex) of
+// nftAddress is being applied to the balances within tx. It returns false,
+// without error, when the log was applied before.
+func (s *TokenBalanceStore) MarkAppliedTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress string, blockNumber uint64, logIndex uint, txHash string) (bool, error) {
+	const q = `
+INSERT IGNORE INTO nft_token_transfer_logs (chain_id, nft_address, block_number, log_index, tx_hash)
+VALUES (?, ?, ?, ?, ?)`
+
+	res, err := tx.ExecContext(ctx, q, chainID, nftAddress, blockNumber, logIndex, txHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TokenOwner represents a row in the nft_token_owners table: the current
+// owner of an ERC721 token as derived from Transfer events.
+type TokenOwner struct {
+	ChainID     int64     `json:"chain_id"`
+	NFTAddress  string    `json:"nft_address"`
+	TokenID     string    `json:"token_id"`
+	Owner       string    `json:"owner"`
+	BlockNumber uint64    `json:"block_number"`
+	LogIndex    uint      `json:"log_index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TokenOwnerStore wraps access to the nft_token_owners table in MySQL.
+type TokenOwnerStore struct {
+	db *sql.DB
+}
+
+// NewTokenOwnerStore creates a new TokenOwnerStore.
+func NewTokenOwnerStore(db *sql.DB) *TokenOwnerStore {
+	return &TokenOwnerStore{db: db}
+}
+
+// InitSchema ensures the nft_token_owners table exists.
+func (s *TokenOwnerStore) InitSchema(ctx context.Context) error {
+	content, err := schemaFiles.ReadFile("sql/create_nft_token_owners_table.sql")
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+		return err
+	}
+	if err := addChainID(ctx, s.db, "nft_token_owners", "chain_id", "nft_address", "token_id"); err != nil {
+		return err
+	}
+	return modifyToVarchar(ctx, s.db, "nft_token_owners", []columnDef{
+		{"token_id", "VARCHAR(78) NOT NULL COMMENT 'ERC721 tokenId'"},
+	})
+}
+
+// GetForUpdateTx returns the ownership row of a token and locks it within
+// the given transaction.
+func (s *TokenOwnerStore) GetForUpdateTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, tokenID string) (*TokenOwner, error) {
+	const q = `
+SELECT chain_id, nft_address, token_id, owner, block_number, log_index, created_at, updated_at
+FROM nft_token_owners
+WHERE chain_id = ? AND nft_address = ? AND token_id = ?
+FOR UPDATE`
+
+	var o TokenOwner
+	if err := tx.QueryRowContext(ctx, q, chainID, nftAddress, tokenID).Scan(
+		&o.ChainID,
+		&o.NFTAddress,
+		&o.TokenID,
+		&o.Owner,
+		&o.BlockNumber,
+		&o.LogIndex,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// UpsertTx creates or updates the ownership row of a token.
+func (s *TokenOwnerStore) UpsertTx(ctx context.Context, tx *sql.Tx, o *TokenOwner) error {
+	const q = `
+INSERT INTO nft_token_owners (chain_id, nft_address, token_id, owner, block_number, log_index)
+VALUES (?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  owner = VALUES(owner),
+  block_number = VALUES(block_number),
+  log_index = VALUES(log_index);`
+
+	_, err := tx.ExecContext(ctx, q, o.ChainID, o.NFTAddress, o.TokenID, o.Owner, o.BlockNumber, o.LogIndex)
+	return err
+}
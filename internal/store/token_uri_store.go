@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TokenURI represents a row in the nft_token_uris table: the latest URI
+// emitted by the ERC1155 URI event for a token.
+type TokenURI struct {
+	ChainID     int64     `json:"chain_id"`
+	NFTAddress  string    `json:"nft_address"`
+	TokenID     string    `json:"token_id"`
+	URI         string    `json:"uri"`
+	BlockNumber uint64    `json:"block_number"`
+	LogIndex    uint      `json:"log_index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// StaleAssetURI is an asset whose token_uri / metadata are older than the
+// URI indexed for its token.
+type StaleAssetURI struct {
+	AssetID    int64
+	ChainID    int64
+	NFTAddress string
+	TokenID    string
+	URI        string
+	Attempts   int // failed fetches of URI so far
+}
+
+// TokenURIStore wraps access to the nft_token_uris table in MySQL.
+type TokenURIStore struct {
+	db *sql.DB
+}
+
+// NewTokenURIStore creates a new TokenURIStore.
+func NewTokenURIStore(db *sql.DB) *TokenURIStore {
+	return &TokenURIStore{db: db}
+}
+
+// InitSchema ensures the nft_token_uris table exists.
+func (s *TokenURIStore) InitSchema(ctx context.Context) error {
+	content, err := schemaFiles.ReadFile("sql/create_nft_token_uris_table.sql")
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, string(content)); err != nil {
+		return err
+	}
+	if err := addChainID(ctx, s.db, "nft_token_uris", "chain_id", "nft_address", "token_id"); err != nil {
+		return err
+	}
+	return modifyToVarchar(ctx, s.db, "nft_token_uris", []columnDef{
+		{"token_id", "VARCHAR(78) NOT NULL COMMENT 'ERC1155 id'"},
+	})
+}
+
+// GetForUpdateTx returns the URI row of a token and locks it within the
+// given transaction.
+func (s *TokenURIStore) GetForUpdateTx(ctx context.Context, tx *sql.Tx, chainID int64, nftAddress, tokenID string) (*TokenURI, error) {
+	const q = `
+SELECT chain_id, nft_address, token_id, uri, block_number, log_index, created_at, updated_at
+FROM nft_token_uris
+WHERE chain_id = ? AND nft_address = ? AND token_id = ?
+FOR UPDATE`
+
+	var u TokenURI
+	if err := tx.QueryRowContext(ctx, q, chainID, nftAddress, tokenID).Scan(
+		&u.ChainID,
+		&u.NFTAddress,
+		&u.TokenID,
+		&u.URI,
+		&u.BlockNumber,
+		&u.LogIndex,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpsertTx creates or updates the URI row of a token.
+func (s *TokenURIStore) UpsertTx(ctx context.Context, tx *sql.Tx, u *TokenURI) error {
+	const q = `
+INSERT INTO nft_token_uris (chain_id, nft_address, token_id, uri, block_number, log_index)
+VALUES (?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  uri = VALUES(uri),
+  block_number = VALUES(block_number),
+  log_index = VALUES(log_index);`
+
+	_, err := tx.ExecContext(ctx, q, u.ChainID, u.NFTAddress, u.TokenID, u.URI, u.BlockNumber, u.LogIndex)
+	return err
+}
+
+// ListStaleAssets returns assets whose metadata has not been refreshed since
+// the URI of their token was last indexed. This also picks up assets whose
+// mint-info was recorded after the URI event was seen. Assets whose fetch of
+// the current URI failed before (see NftAssetStore.RecordMetadataFailure)
+// are only returned once their retry time has come and while they failed
+// fewer than maxAttempts times, after those that never failed.
+func (s *TokenURIStore) ListStaleAssets(ctx context.Context, limit, maxAttempts int) ([]*StaleAssetURI, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	const q = `
+SELECT a.id, u.chain_id, u.nft_address, u.token_id, u.uri,
+  IF(a.metadata_failed_uri <=> u.uri, a.metadata_attempts, 0) AS attempts
+FROM nft_token_uris u
+JOIN nft_assets a
+  ON a.chain_id = u.chain_id AND a.nft_address = u.nft_address AND a.token_id = u.token_id
+WHERE (a.token_uri IS NULL
+    OR a.token_uri <> u.uri
+    OR a.metadata_updated_at IS NULL
+    OR a.metadata_updated_at < u.updated_at)
+  AND (NOT (a.metadata_failed_uri <=> u.uri)
+    OR (a.metadata_attempts < ? AND a.metadata_next_attempt_at <= NOW()))
+ORDER BY attempts, u.updated_at
+LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, q, maxAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*StaleAssetURI
+	for rows.Next() {
+		var a StaleAssetURI
+		if err := rows.Scan(&a.AssetID, &a.ChainID, &a.NFTAddress, &a.TokenID, &a.URI, &a.Attempts); err != nil {
+			return nil, err
+		}
+		out = append(out, &a)
+	}
+	return out, rows.Err()
+}